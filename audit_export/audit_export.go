@@ -0,0 +1,355 @@
+package audit_export
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/constants"
+	commonpb "go.temporal.io/api/common/v1"
+	enumspb "go.temporal.io/api/enums/v1"
+	historypb "go.temporal.io/api/history/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"io"
+	"strconv"
+	"time"
+)
+
+const (
+	KindContinuedAsNew = "continued_as_new"
+	KindRunClosed      = "run_closed"
+	KindRunStarted     = "run_started"
+	KindTimerCanceled  = "timer_canceled"
+	KindTimerFired     = "timer_fired"
+	KindTimerStarted   = "timer_started"
+	KindUpdate         = "update"
+)
+
+// usersQuery matches every user entity, open or closed, leaving out other entities such as the permission catalog.
+const usersQuery = "`WorkflowType`=\"Orchestration\""
+
+// csvHeader lists the CSV columns in the order Record fields are written.
+var csvHeader = []string{"workflow_id", "run_id", "event_id", "at", "kind", "action", "actor", "permission",
+	"role", "outcome", "reason", "update_id", "timer_id", "details"}
+
+// Record is one normalized audit record derived from a workflow history event. Updates are reported once, when they
+// are accepted, with the outcome of their completion. An update still in flight at the end of the history has no
+// outcome.
+type Record struct {
+	Action     string    `json:"action,omitempty"`
+	Actor      string    `json:"actor,omitempty"`
+	At         time.Time `json:"at"`
+	Details    string    `json:"details,omitempty"`
+	EventID    int64     `json:"event_id"`
+	Kind       string    `json:"kind"`
+	Outcome    string    `json:"outcome,omitempty"`
+	Permission string    `json:"permission,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	Role       string    `json:"role,omitempty"`
+	RunID      string    `json:"run_id"`
+	TimerID    string    `json:"timer_id,omitempty"`
+	UpdateID   string    `json:"update_id,omitempty"`
+	WorkflowID string    `json:"workflow_id"`
+}
+
+// Writer writes records in an export format. Flush must be called once every record is written.
+type Writer interface {
+	Flush() error
+	Write(record Record) error
+}
+
+// updateArgs holds the request fields common to update handlers that identify the actor, permission and role.
+type updateArgs struct {
+	ApproverID  string
+	Permission  string
+	RequestedBy string
+	Role        string
+}
+
+// FromHistory converts the events of a single run into records. workflowID and runID label the records when the
+// history does not carry them. The run ID the run continued as new into is returned, or an empty string.
+func FromHistory(workflowID string, runID string, events client.HistoryEventIterator) ([]Record, string, error) {
+	records := make([]Record, 0)
+	updates := make(map[string]int)
+	nextRunID := ""
+	for events.HasNext() {
+		event, err := events.Next()
+		if err != nil {
+			return nil, "", err
+		}
+		record := Record{
+			At:         event.GetEventTime().AsTime(),
+			EventID:    event.GetEventId(),
+			RunID:      runID,
+			WorkflowID: workflowID,
+		}
+		switch event.GetEventType() {
+		case enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_STARTED:
+			attributes := event.GetWorkflowExecutionStartedEventAttributes()
+			if workflowID == "" {
+				workflowID = attributes.GetWorkflowId()
+			}
+			if runID == "" {
+				runID = attributes.GetOriginalExecutionRunId()
+			}
+			record.WorkflowID = workflowID
+			record.RunID = runID
+			record.Kind = KindRunStarted
+			record.Details = attributes.GetContinuedExecutionRunId()
+		case enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_UPDATE_ACCEPTED:
+			request := event.GetWorkflowExecutionUpdateAcceptedEventAttributes().GetAcceptedRequest()
+			record.Kind = KindUpdate
+			record.Action = request.GetInput().GetName()
+			record.UpdateID = request.GetMeta().GetUpdateId()
+			details, args, err := decodeArgs(request.GetInput().GetArgs())
+			if err != nil {
+				return nil, "", errors.Join(errors.New(fmt.Sprintf("unable to decode update %s", record.UpdateID)), err)
+			}
+			record.Details = details
+			record.Permission = args.Permission
+			record.Role = args.Role
+			record.Actor = actor(workflowID, record.Action, args)
+			updates[record.UpdateID] = len(records)
+		case enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_UPDATE_COMPLETED:
+			attributes := event.GetWorkflowExecutionUpdateCompletedEventAttributes()
+			i, ok := updates[attributes.GetMeta().GetUpdateId()]
+			if !ok {
+				continue
+			}
+			records[i].Outcome = constants.AuditOutcomeSucceeded
+			if attributes.GetOutcome().GetFailure() != nil {
+				records[i].Outcome = constants.AuditOutcomeFailed
+				records[i].Reason = attributes.GetOutcome().GetFailure().GetMessage()
+			}
+			continue
+		case enumspb.EVENT_TYPE_TIMER_STARTED:
+			attributes := event.GetTimerStartedEventAttributes()
+			record.Kind = KindTimerStarted
+			record.TimerID = attributes.GetTimerId()
+			record.Details = attributes.GetStartToFireTimeout().AsDuration().String()
+		case enumspb.EVENT_TYPE_TIMER_FIRED:
+			record.Kind = KindTimerFired
+			record.TimerID = event.GetTimerFiredEventAttributes().GetTimerId()
+		case enumspb.EVENT_TYPE_TIMER_CANCELED:
+			record.Kind = KindTimerCanceled
+			record.TimerID = event.GetTimerCanceledEventAttributes().GetTimerId()
+		case enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_CONTINUED_AS_NEW:
+			nextRunID = event.GetWorkflowExecutionContinuedAsNewEventAttributes().GetNewExecutionRunId()
+			record.Kind = KindContinuedAsNew
+			record.Details = nextRunID
+		case enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_COMPLETED:
+			record.Kind = KindRunClosed
+			record.Outcome = "completed"
+		case enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_FAILED:
+			record.Kind = KindRunClosed
+			record.Outcome = "failed"
+			record.Reason = event.GetWorkflowExecutionFailedEventAttributes().GetFailure().GetMessage()
+		case enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_TERMINATED:
+			record.Kind = KindRunClosed
+			record.Outcome = "terminated"
+			record.Reason = event.GetWorkflowExecutionTerminatedEventAttributes().GetReason()
+		case enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_CANCELED:
+			record.Kind = KindRunClosed
+			record.Outcome = "canceled"
+		case enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_TIMED_OUT:
+			record.Kind = KindRunClosed
+			record.Outcome = "timed_out"
+		default:
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nextRunID, nil
+}
+
+// ExportWorkflow writes the records of every run of workflowID, starting at its first run and following
+// continue-as-new into the current one.
+func ExportWorkflow(ctx context.Context, c client.Client, workflowID string, w Writer) error {
+	resp, err := c.DescribeWorkflowExecution(ctx, workflowID, "")
+	if err != nil {
+		return err
+	}
+	runID := resp.GetWorkflowExecutionInfo().GetFirstRunId()
+	if runID == "" {
+		runID = resp.GetWorkflowExecutionInfo().GetExecution().GetRunId()
+	}
+	return exportRuns(ctx, c, workflowID, runID, w)
+}
+
+// ExportAll writes the records of every user entity visible in namespace, open or closed.
+func ExportAll(ctx context.Context, c client.Client, namespace string, w Writer) error {
+	exported := make(map[string]bool)
+	var nextPageToken []byte
+	for {
+		resp, err := c.ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{
+			Namespace:     namespace,
+			NextPageToken: nextPageToken,
+			Query:         usersQuery,
+		})
+		if err != nil {
+			return err
+		}
+		for _, e := range resp.GetExecutions() {
+			// Runs of the same chain share a first run, so each chain is exported once
+			workflowID := e.GetExecution().GetWorkflowId()
+			firstRunID := e.GetFirstRunId()
+			if firstRunID == "" {
+				firstRunID = e.GetExecution().GetRunId()
+			}
+			if exported[workflowID+"/"+firstRunID] {
+				continue
+			}
+			exported[workflowID+"/"+firstRunID] = true
+			err = exportRuns(ctx, c, workflowID, firstRunID, w)
+			if err != nil {
+				return err
+			}
+		}
+		nextPageToken = resp.GetNextPageToken()
+		if len(nextPageToken) == 0 {
+			return nil
+		}
+	}
+}
+
+// IterateHistory adapts a history, such as one loaded with client.HistoryFromJSON, to FromHistory.
+func IterateHistory(h *historypb.History) client.HistoryEventIterator {
+	return &historyIterator{events: h.GetEvents()}
+}
+
+// NewCSVWriter returns a Writer that writes records as CSV with a header row.
+func NewCSVWriter(w io.Writer) Writer {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+// NewJSONLinesWriter returns a Writer that writes each record as a JSON object on its own line.
+func NewJSONLinesWriter(w io.Writer) Writer {
+	return &jsonLinesWriter{e: json.NewEncoder(w)}
+}
+
+// actor returns who made an update. Users request permissions and roles for themselves.
+func actor(workflowID string, update string, args updateArgs) string {
+	if args.ApproverID != "" {
+		return args.ApproverID
+	}
+	if args.RequestedBy != "" {
+		return args.RequestedBy
+	}
+	if update == constants.AddUserPermissionUpdateHandlerName || update == constants.AddUserRoleUpdateHandlerName {
+		return workflowID
+	}
+	return ""
+}
+
+// decodeArgs returns the first update argument as JSON along with the fields that identify its actor, permission and
+// role.
+func decodeArgs(payloads *commonpb.Payloads) (string, updateArgs, error) {
+	args := updateArgs{}
+	if len(payloads.GetPayloads()) == 0 {
+		return "", args, nil
+	}
+	var raw json.RawMessage
+	err := converter.GetDefaultDataConverter().FromPayload(payloads.GetPayloads()[0], &raw)
+	if err != nil {
+		return "", args, err
+	}
+	// Arguments that are not objects carry no actor or permission
+	_ = json.Unmarshal(raw, &args)
+	return string(raw), args, nil
+}
+
+// exportRuns writes the records of runID and of every run it continued as new into.
+func exportRuns(ctx context.Context, c client.Client, workflowID string, runID string, w Writer) error {
+	for runID != "" {
+		events := c.GetWorkflowHistory(ctx, workflowID, runID, false, enumspb.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT)
+		records, nextRunID, err := FromHistory(workflowID, runID, events)
+		if err != nil {
+			return errors.Join(errors.New(fmt.Sprintf("unable to export run %s of %s", runID, workflowID)), err)
+		}
+		for _, record := range records {
+			err = w.Write(record)
+			if err != nil {
+				return err
+			}
+		}
+		runID = nextRunID
+	}
+	return nil
+}
+
+type csvWriter struct {
+	headerWritten bool
+	w             *csv.Writer
+}
+
+func (cw *csvWriter) Flush() error {
+	err := cw.writeHeader()
+	if err != nil {
+		return err
+	}
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+func (cw *csvWriter) Write(record Record) error {
+	err := cw.writeHeader()
+	if err != nil {
+		return err
+	}
+	return cw.w.Write([]string{
+		record.WorkflowID,
+		record.RunID,
+		strconv.FormatInt(record.EventID, 10),
+		record.At.Format(time.RFC3339Nano),
+		record.Kind,
+		record.Action,
+		record.Actor,
+		record.Permission,
+		record.Role,
+		record.Outcome,
+		record.Reason,
+		record.UpdateID,
+		record.TimerID,
+		record.Details,
+	})
+}
+
+func (cw *csvWriter) writeHeader() error {
+	if cw.headerWritten {
+		return nil
+	}
+	cw.headerWritten = true
+	return cw.w.Write(csvHeader)
+}
+
+type historyIterator struct {
+	events []*historypb.HistoryEvent
+	next   int
+}
+
+func (hi *historyIterator) HasNext() bool {
+	return hi.next < len(hi.events)
+}
+
+func (hi *historyIterator) Next() (*historypb.HistoryEvent, error) {
+	if !hi.HasNext() {
+		return nil, errors.New("no more events")
+	}
+	hi.next++
+	return hi.events[hi.next-1], nil
+}
+
+type jsonLinesWriter struct {
+	e *json.Encoder
+}
+
+func (jw *jsonLinesWriter) Flush() error {
+	return nil
+}
+
+func (jw *jsonLinesWriter) Write(record Record) error {
+	return jw.e.Encode(record)
+}
@@ -0,0 +1,180 @@
+package audit_export
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/constants"
+	commonpb "go.temporal.io/api/common/v1"
+	enumspb "go.temporal.io/api/enums/v1"
+	failurepb "go.temporal.io/api/failure/v1"
+	historypb "go.temporal.io/api/history/v1"
+	updatepb "go.temporal.io/api/update/v1"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/mocks"
+	"os"
+	"strings"
+	"testing"
+)
+
+type AuditExportTestSuite struct {
+	suite.Suite
+
+	c *mocks.Client
+}
+
+func (s *AuditExportTestSuite) SetupTest() {
+	s.c = &mocks.Client{}
+}
+
+func (s *AuditExportTestSuite) AfterTest(suiteName, testName string) {
+	s.c.AssertExpectations(s.T())
+}
+
+func TestAuditExportTestSuite(t *testing.T) {
+	suite.Run(t, new(AuditExportTestSuite))
+}
+
+// recordingWriter keeps every record written to it.
+type recordingWriter struct {
+	records []Record
+}
+
+func (rw *recordingWriter) Flush() error {
+	return nil
+}
+
+func (rw *recordingWriter) Write(record Record) error {
+	rw.records = append(rw.records, record)
+	return nil
+}
+
+// updateEvents returns the accepted and completed events of an update. A non-empty failure fails the update.
+func (s *AuditExportTestSuite) updateEvents(eventID int64, name string, arg interface{}, failure string) []*historypb.HistoryEvent {
+	payload, err := converter.GetDefaultDataConverter().ToPayload(arg)
+	s.Nil(err)
+	updateID := name + "-id"
+	outcome := &updatepb.Outcome{Value: &updatepb.Outcome_Success{Success: &commonpb.Payloads{}}}
+	if failure != "" {
+		outcome = &updatepb.Outcome{Value: &updatepb.Outcome_Failure{Failure: &failurepb.Failure{Message: failure}}}
+	}
+	return []*historypb.HistoryEvent{{
+		EventId:   eventID,
+		EventType: enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_UPDATE_ACCEPTED,
+		Attributes: &historypb.HistoryEvent_WorkflowExecutionUpdateAcceptedEventAttributes{
+			WorkflowExecutionUpdateAcceptedEventAttributes: &historypb.WorkflowExecutionUpdateAcceptedEventAttributes{
+				AcceptedRequest: &updatepb.Request{
+					Meta: &updatepb.Meta{UpdateId: updateID},
+					Input: &updatepb.Input{
+						Name: name,
+						Args: &commonpb.Payloads{Payloads: []*commonpb.Payload{payload}},
+					},
+				},
+			},
+		},
+	}, {
+		EventId:   eventID + 1,
+		EventType: enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_UPDATE_COMPLETED,
+		Attributes: &historypb.HistoryEvent_WorkflowExecutionUpdateCompletedEventAttributes{
+			WorkflowExecutionUpdateCompletedEventAttributes: &historypb.WorkflowExecutionUpdateCompletedEventAttributes{
+				Meta:    &updatepb.Meta{UpdateId: updateID},
+				Outcome: outcome,
+			},
+		},
+	}}
+}
+
+func (s *AuditExportTestSuite) Test_FromHistory_Fixture() {
+	f, err := os.Open("../fixtures/event_history.json")
+	s.Nil(err)
+	defer f.Close()
+	h, err := client.HistoryFromJSON(f, client.HistoryJSONOptions{})
+	s.Nil(err)
+
+	records, nextRunID, err := FromHistory("", "", IterateHistory(h))
+	s.Nil(err)
+	s.Empty(nextRunID)
+	kinds := make([]string, 0)
+	for _, record := range records {
+		s.Equal("b@ai.io", record.WorkflowID)
+		s.Equal("943387d7-ab5d-475e-9740-d55964db17ce", record.RunID)
+		kinds = append(kinds, record.Kind+":"+record.Action)
+	}
+	s.Equal([]string{
+		KindRunStarted + ":",
+		KindUpdate + ":" + constants.AddUserPermissionUpdateHandlerName,
+		KindUpdate + ":" + constants.ApproveUserPermissionUpdateHandlerName,
+		KindUpdate + ":" + constants.DeleteUserAccountUpdateHandlerName,
+		KindTimerStarted + ":",
+		KindTimerFired + ":",
+		KindRunClosed + ":",
+	}, kinds)
+	s.Equal("b@ai.io", records[1].Actor)
+	s.Equal(constants.PermissionTypeReadFiles, records[1].Permission)
+	s.Equal(constants.AuditOutcomeSucceeded, records[1].Outcome)
+	s.Equal("a@ai.io", records[2].Actor)
+	s.Equal("1m0s", records[4].Details)
+	s.Equal(records[4].TimerID, records[5].TimerID)
+}
+
+func (s *AuditExportTestSuite) Test_ExportWorkflow_FollowsContinueAsNew() {
+	first := s.updateEvents(1, constants.SuspendUserAccountUpdateHandlerName,
+		map[string]string{"Reason": "leave", "RequestedBy": "admin@ai.io"}, "")
+	first = append(first, &historypb.HistoryEvent{
+		EventId:   3,
+		EventType: enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_CONTINUED_AS_NEW,
+		Attributes: &historypb.HistoryEvent_WorkflowExecutionContinuedAsNewEventAttributes{
+			WorkflowExecutionContinuedAsNewEventAttributes: &historypb.WorkflowExecutionContinuedAsNewEventAttributes{
+				NewExecutionRunId: "run-2",
+			},
+		},
+	})
+	second := s.updateEvents(1, constants.RevokeUserPermissionUpdateHandlerName,
+		map[string]string{"ApproverID": "a@ai.io", "Permission": constants.PermissionTypeReadFiles},
+		"revoke_permission not allowed while user is deleted")
+	s.c.On("DescribeWorkflowExecution", mock.Anything, "b@ai.io", "").Return(
+		&workflowservice.DescribeWorkflowExecutionResponse{
+			WorkflowExecutionInfo: &workflowpb.WorkflowExecutionInfo{
+				Execution:  &commonpb.WorkflowExecution{WorkflowId: "b@ai.io", RunId: "run-2"},
+				FirstRunId: "run-1",
+			},
+		}, nil).Once()
+	s.c.On("GetWorkflowHistory", mock.Anything, "b@ai.io", "run-1", false,
+		enumspb.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT).Return(IterateHistory(&historypb.History{Events: first})).Once()
+	s.c.On("GetWorkflowHistory", mock.Anything, "b@ai.io", "run-2", false,
+		enumspb.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT).Return(IterateHistory(&historypb.History{Events: second})).Once()
+
+	w := &recordingWriter{}
+	err := ExportWorkflow(context.Background(), s.c, "b@ai.io", w)
+	s.Nil(err)
+	s.Len(w.records, 3)
+	s.Equal(constants.SuspendUserAccountUpdateHandlerName, w.records[0].Action)
+	s.Equal("admin@ai.io", w.records[0].Actor)
+	s.Equal("run-1", w.records[0].RunID)
+	s.Equal(KindContinuedAsNew, w.records[1].Kind)
+	s.Equal("run-2", w.records[1].Details)
+	s.Equal(constants.RevokeUserPermissionUpdateHandlerName, w.records[2].Action)
+	s.Equal("run-2", w.records[2].RunID)
+	s.Equal(constants.AuditOutcomeFailed, w.records[2].Outcome)
+	s.Equal("revoke_permission not allowed while user is deleted", w.records[2].Reason)
+}
+
+func (s *AuditExportTestSuite) Test_CSVWriter() {
+	var b bytes.Buffer
+	w := NewCSVWriter(&b)
+	s.Nil(w.Flush())
+	s.Equal(strings.Join(csvHeader, ",")+"\n", b.String())
+
+	b.Reset()
+	w = NewCSVWriter(&b)
+	s.Nil(w.Write(Record{Details: `{"Permission":"read_files"}`, EventID: 8, Kind: KindUpdate, WorkflowID: "b@ai.io"}))
+	s.Nil(w.Flush())
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	s.Len(lines, 2)
+	s.True(strings.HasPrefix(lines[1], "b@ai.io,,8,"))
+	s.True(strings.HasSuffix(lines[1], `"{""Permission"":""read_files""}"`))
+}
@@ -0,0 +1,327 @@
+package campaign_state
+
+import (
+	"errors"
+	"fmt"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/constants"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/messages"
+	"go.temporal.io/sdk/log"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+	"slices"
+	"strings"
+	"time"
+)
+
+type Option func(*CampaignState)
+
+type CampaignState struct {
+	closedAt    time.Time
+	ctx         workflow.Context
+	deadline    time.Time
+	enumerated  bool
+	items       []messages.CampaignItem
+	logger      log.Logger
+	name        string
+	permissions []string
+	requestedBy string
+	reviewerID  string
+}
+
+func New(ctx workflow.Context, opts ...Option) (*CampaignState, error) {
+	state := &CampaignState{
+		ctx:         ctx,
+		items:       make([]messages.CampaignItem, 0),
+		permissions: make([]string, 0),
+	}
+	for _, o := range opts {
+		o(state)
+	}
+	if state.ctx == nil {
+		return nil, errors.New("context required and missing")
+	}
+	state.logger = workflow.GetLogger(state.ctx)
+	state.name = Name(workflow.GetInfo(state.ctx).WorkflowExecution.ID)
+	return state, nil
+}
+
+func WithSnapshot(input messages.CampaignOrchestrationInput) Option {
+	return func(state *CampaignState) {
+		state.deadline = input.Deadline
+		state.enumerated = input.Enumerated
+		state.items = append(state.items, input.Items...)
+		state.permissions = appendUnique(state.permissions, input.Permissions...)
+		state.requestedBy = input.RequestedBy
+		state.reviewerID = input.ReviewerID
+	}
+}
+
+// Name returns the name of the campaign run under workflowID.
+func Name(workflowID string) string {
+	return strings.TrimPrefix(workflowID, constants.CampaignWorkflowIDPrefix)
+}
+
+// WorkflowID returns the ID of the workflow of campaign. Campaigns are prefixed so that they cannot collide with user
+// entities.
+func WorkflowID(campaign string) string {
+	return constants.CampaignWorkflowIDPrefix + campaign
+}
+
+// decide records the decision on the grant of item i. Decisions are recorded before they are applied so that a grant
+// cannot be decided twice while a revocation is in flight.
+func (state *CampaignState) decide(i int, decision string, reviewerID string, reason string) {
+	state.items[i].DecidedAt = workflow.Now(state.ctx)
+	state.items[i].DecidedBy = reviewerID
+	state.items[i].Decision = decision
+	state.items[i].Reason = strings.TrimSpace(reason)
+}
+
+func (state *CampaignState) item(userID string, permission string) int {
+	return slices.IndexFunc(state.items, func(item messages.CampaignItem) bool {
+		return item.UserID == userID && item.Permission == permission
+	})
+}
+
+// notify tells approverID about the campaign. Failures are logged: the campaign goes on regardless.
+func (state *CampaignState) notify(ctx workflow.Context, notificationType string, approverID string) {
+	err := workflow.ExecuteActivity(ctx, "SendNotifications", &messages.SendNotificationsRequest{
+		ApproverID:       approverID,
+		NotificationType: notificationType,
+		PermissionType:   strings.Join(state.permissions, ","),
+		RequesterID:      state.requestedBy,
+	}).Get(ctx, nil)
+	if err != nil {
+		state.logger.Error("unable to send notifications", err)
+	}
+}
+
+// revoke asks the user entity to take away the grant of item i as decided. ctx must already carry activity options.
+func (state *CampaignState) revoke(ctx workflow.Context, i int) workflow.Future {
+	item := state.items[i]
+	return workflow.ExecuteActivity(ctx, "RevokeCampaignGrant", &messages.RevokeCampaignGrantRequest{
+		CampaignID: workflow.GetInfo(ctx).WorkflowExecution.ID,
+		Permission: item.Permission,
+		Reason:     item.Reason,
+		ReviewerID: item.DecidedBy,
+		UserID:     item.UserID,
+	})
+}
+
+// revoked keeps why the revocation of the grant of item i failed, e.g. because the user was deleted in the meantime.
+func (state *CampaignState) revoked(i int, err error) {
+	if err == nil {
+		return
+	}
+	state.logger.Error("unable to revoke grant", "UserID", state.items[i].UserID,
+		"Permission", state.items[i].Permission, "Error", err)
+	var appErr *temporal.ApplicationError
+	if errors.As(err, &appErr) {
+		state.items[i].Error = appErr.Message()
+		return
+	}
+	state.items[i].Error = err.Error()
+}
+
+// reviewerFor returns who reviews grant: the campaign's designated reviewer, else the user's manager. Nobody reviews
+// their own access, so the owner of the campaign steps in when neither applies.
+func (state *CampaignState) reviewerFor(grant messages.PermissionGrant) string {
+	for _, reviewerID := range []string{state.reviewerID, grant.ManagerID} {
+		if reviewerID != "" && reviewerID != grant.UserID {
+			return reviewerID
+		}
+	}
+	return state.requestedBy
+}
+
+func (state *CampaignState) validateDecision(userID string, permission string, reviewerID string) error {
+	if !state.closedAt.IsZero() {
+		return errors.New("campaign closed")
+	}
+	if reviewerID == "" {
+		return errors.New("reviewer required and missing")
+	}
+	i := state.item(userID, permission)
+	if i < 0 {
+		return errors.New(fmt.Sprintf("%s's grant of %s is not under review", userID, permission))
+	}
+	item := state.items[i]
+	if item.Decision != "" {
+		return errors.New(fmt.Sprintf("%s's grant of %s already %s by %s", userID, permission, item.Decision,
+			item.DecidedBy))
+	}
+	if item.ReviewerID != reviewerID {
+		return errors.New(fmt.Sprintf("%s is not the reviewer of %s's grant of %s", reviewerID, userID, permission))
+	}
+	return nil
+}
+
+func (state *CampaignState) Certify(req messages.CertifyGrantRequest) error {
+	err := state.ValidateCertify(req)
+	if err != nil {
+		return err
+	}
+	state.decide(state.item(req.UserID, req.Permission), constants.CampaignDecisionCertified, req.ReviewerID, "")
+	return nil
+}
+
+// Close ends the campaign: grants that were neither certified nor revoked by the deadline are revoked, and the owner
+// of the campaign is sent the summary.
+func (state *CampaignState) Close(ctx workflow.Context) {
+	state.closedAt = workflow.Now(ctx)
+	actCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 1 * time.Minute,
+	})
+	pending := make([]int, 0)
+	futures := make([]workflow.Future, 0)
+	for i := range state.items {
+		if state.items[i].Decision != "" {
+			continue
+		}
+		state.decide(i, constants.CampaignDecisionAutoRevoked, constants.SystemActorID,
+			constants.CertificationDeadlineExceededReason)
+		pending = append(pending, i)
+		futures = append(futures, state.revoke(actCtx, i))
+	}
+	for j, f := range futures {
+		state.revoked(pending[j], f.Get(actCtx, nil))
+	}
+	state.notify(actCtx, constants.NotificationTypeRecertificationReport, state.requestedBy)
+}
+
+func (state *CampaignState) Deadline() time.Time {
+	return state.deadline
+}
+
+// Enumerate puts every direct grant of the campaign's permissions under review and notifies each reviewer.
+func (state *CampaignState) Enumerate(ctx workflow.Context) error {
+	actCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 5 * time.Minute,
+	})
+	resp := messages.ListGrantsResponse{}
+	err := workflow.ExecuteActivity(actCtx, "ListGrants", &messages.ListGrantsRequest{
+		Permissions: state.permissions,
+	}).Get(actCtx, &resp)
+	if err != nil {
+		return err
+	}
+	reviewers := make([]string, 0)
+	for _, grant := range resp.Grants {
+		if state.item(grant.UserID, grant.Permission) >= 0 {
+			continue
+		}
+		reviewerID := state.reviewerFor(grant)
+		state.items = append(state.items, messages.CampaignItem{
+			Permission: grant.Permission,
+			ReviewerID: reviewerID,
+			UserID:     grant.UserID,
+		})
+		reviewers = appendUnique(reviewers, reviewerID)
+	}
+	state.enumerated = true
+	for _, reviewerID := range reviewers {
+		state.notify(actCtx, constants.NotificationTypeRecertificationReview, reviewerID)
+	}
+	return nil
+}
+
+func (state *CampaignState) Pending() int {
+	pending := 0
+	for _, item := range state.items {
+		if item.Decision == "" {
+			pending++
+		}
+	}
+	return pending
+}
+
+// Revoke records the decision to take away a grant and asks the user entity to apply it. A revocation the user entity
+// refuses, e.g. because the grant was revoked in the meantime, stays decided and is reported with its error.
+func (state *CampaignState) Revoke(ctx workflow.Context, req messages.RevokeGrantRequest) error {
+	err := state.ValidateRevoke(req)
+	if err != nil {
+		return err
+	}
+	i := state.item(req.UserID, req.Permission)
+	state.decide(i, constants.CampaignDecisionRevoked, req.ReviewerID, req.Reason)
+	actCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 1 * time.Minute,
+	})
+	state.revoked(i, state.revoke(actCtx, i).Get(actCtx, nil))
+	return nil
+}
+
+func (state *CampaignState) Snapshot() messages.CampaignOrchestrationInput {
+	return messages.CampaignOrchestrationInput{
+		Deadline:    state.deadline,
+		Enumerated:  state.enumerated,
+		Items:       state.items,
+		Permissions: state.permissions,
+		RequestedBy: state.requestedBy,
+		ReviewerID:  state.reviewerID,
+	}
+}
+
+// Summary reports the progress of the campaign, and once it is closed, its outcome.
+func (state *CampaignState) Summary() messages.CampaignSummary {
+	summary := messages.CampaignSummary{
+		ClosedAt:    state.closedAt,
+		Deadline:    state.deadline,
+		Items:       state.items,
+		Name:        state.name,
+		Permissions: state.permissions,
+		RequestedBy: state.requestedBy,
+		ReviewerID:  state.reviewerID,
+		Total:       len(state.items),
+	}
+	for _, item := range state.items {
+		switch item.Decision {
+		case constants.CampaignDecisionAutoRevoked:
+			summary.AutoRevoked++
+		case constants.CampaignDecisionCertified:
+			summary.Certified++
+		case constants.CampaignDecisionRevoked:
+			summary.Revoked++
+		default:
+			summary.Pending++
+		}
+		if item.Error != "" {
+			summary.Failed++
+		}
+	}
+	return summary
+}
+
+// Validate reports why the campaign cannot start.
+func (state *CampaignState) Validate() error {
+	if len(state.permissions) == 0 {
+		return errors.New("permissions required and missing")
+	}
+	if state.requestedBy == "" {
+		return errors.New("requester required and missing")
+	}
+	if state.deadline.IsZero() {
+		return errors.New("deadline required and missing")
+	}
+	if !state.deadline.After(workflow.Now(state.ctx)) {
+		return errors.New(fmt.Sprintf("deadline %s has already passed", state.deadline.Format(time.RFC3339)))
+	}
+	return nil
+}
+
+func (state *CampaignState) ValidateCertify(req messages.CertifyGrantRequest) error {
+	return state.validateDecision(req.UserID, req.Permission, req.ReviewerID)
+}
+
+func (state *CampaignState) ValidateRevoke(req messages.RevokeGrantRequest) error {
+	return state.validateDecision(req.UserID, req.Permission, req.ReviewerID)
+}
+
+// appendUnique appends each of additions to values unless values already holds it.
+func appendUnique(values []string, additions ...string) []string {
+	for _, addition := range additions {
+		if !slices.Contains(values, addition) {
+			values = append(values, addition)
+		}
+	}
+	return values
+}
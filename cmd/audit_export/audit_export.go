@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/audit_export"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/config"
+	"go.temporal.io/sdk/client"
+	"log"
+	"os"
+)
+
+// Exports the audit trail of user entities from workflow event history. With history files as arguments it runs
+// offline, otherwise it reads the history of -workflow_id, or of every user entity, from the configured namespace.
+func main() {
+	format := flag.String("format", "jsonl", "output format, jsonl or csv")
+	out := flag.String("out", "", "file to write to instead of standard output")
+	workflowID := flag.String("workflow_id", "", "user entity to export, every user entity when empty")
+	flag.Parse()
+
+	f := os.Stdout
+	if *out != "" {
+		var err error
+		f, err = os.Create(*out)
+		if err != nil {
+			log.Fatalln("unable to create output file", err)
+		}
+		defer f.Close()
+	}
+	var w audit_export.Writer
+	switch *format {
+	case "csv":
+		w = audit_export.NewCSVWriter(f)
+	case "jsonl":
+		w = audit_export.NewJSONLinesWriter(f)
+	default:
+		log.Fatalln("unsupported format", *format)
+	}
+
+	if flag.NArg() > 0 {
+		for _, path := range flag.Args() {
+			exportFile(path, *workflowID, w)
+		}
+	} else {
+		c := config.MustGetClient()
+		defer c.Close()
+		var err error
+		if *workflowID != "" {
+			err = audit_export.ExportWorkflow(context.Background(), c, *workflowID, w)
+		} else {
+			err = audit_export.ExportAll(context.Background(), c, os.Getenv("TEMPORAL_CLIENT_NAMESPACE"), w)
+		}
+		if err != nil {
+			log.Fatalln("unable to export audit records", err)
+		}
+	}
+	err := w.Flush()
+	if err != nil {
+		log.Fatalln("unable to write audit records", err)
+	}
+}
+
+// exportFile writes the records of a history exported as JSON, such as with `temporal workflow show --output json`.
+func exportFile(path string, workflowID string, w audit_export.Writer) {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalln("unable to open history file", err)
+	}
+	defer f.Close()
+	h, err := client.HistoryFromJSON(f, client.HistoryJSONOptions{})
+	if err != nil {
+		log.Fatalln("unable to read history file", path, err)
+	}
+	records, _, err := audit_export.FromHistory(workflowID, "", audit_export.IterateHistory(h))
+	if err != nil {
+		log.Fatalln("unable to export history file", path, err)
+	}
+	for _, record := range records {
+		err = w.Write(record)
+		if err != nil {
+			log.Fatalln("unable to write audit records", err)
+		}
+	}
+}
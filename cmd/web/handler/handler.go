@@ -5,17 +5,33 @@ import (
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/campaign_state"
 	"github.com/temporal-sa/temporal-entity-lifecycle-go/constants"
 	"github.com/temporal-sa/temporal-entity-lifecycle-go/messages"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/role_state"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/user_account_state"
+	enumspb "go.temporal.io/api/enums/v1"
 	"go.temporal.io/api/serviceerror"
 	"go.temporal.io/api/workflowservice/v1"
 	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
 	"go.uber.org/zap"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// campaignsQuery matches every recertification campaign that is running or has closed, leaving out runs that continued
+// as new and campaigns that failed to start.
+const campaignsQuery = "`WorkflowType`=\"CampaignOrchestration\" AND `ExecutionStatus` IN (\"Running\",\"Completed\")"
+
+// runningUsersQuery matches every live user entity, leaving out other entities such as the permission catalog.
+const runningUsersQuery = "`ExecutionStatus`=\"Running\" AND `WorkflowType`=\"Orchestration\""
+
+// runningRolesQuery matches every live role entity.
+const runningRolesQuery = "`ExecutionStatus`=\"Running\" AND `WorkflowType`=\"RoleOrchestration\""
+
 type Logger interface {
 	Debug(msg string, fields ...zap.Field)
 	Info(msg string, fields ...zap.Field)
@@ -48,7 +64,51 @@ func New(c client.Client, namespace string, opts ...Option) (*Handler, error) {
 }
 
 func (h Handler) GETApprovePermission(gc *gin.Context) {
-	gc.HTML(http.StatusOK, "approve_permission.html", nil)
+	definitions, err := h.permissionDefinitions(gc)
+	if err != nil {
+		_ = gc.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	gc.HTML(http.StatusOK, "approve_permission.html", gin.H{"Permissions": definitions})
+}
+
+func (h Handler) GETCampaign(gc *gin.Context) {
+	if gc.Query("id") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "id required and missing")
+		return
+	}
+	summary, err := h.campaignSummary(gc, campaign_state.WorkflowID(gc.Query("id")))
+	if err != nil {
+		_ = gc.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	gc.HTML(http.StatusOK, "campaign.html", summary)
+}
+
+func (h Handler) GETCampaigns(gc *gin.Context) {
+	listResp, err := h.c.ListWorkflow(gc.Request.Context(), &workflowservice.ListWorkflowExecutionsRequest{
+		Namespace: h.ns,
+		Query:     campaignsQuery,
+	})
+	if err != nil {
+		_ = gc.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	campaigns := make([]messages.CampaignSummary, 0)
+	for _, e := range listResp.GetExecutions() {
+		summary, err := h.campaignSummary(gc, e.GetExecution().GetWorkflowId())
+		if err != nil {
+			_ = gc.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		campaigns = append(campaigns, summary)
+	}
+	definitions, err := h.permissionDefinitions(gc)
+	if err != nil {
+		_ = gc.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	gc.HTML(http.StatusOK, "campaigns.html", gin.H{"Campaigns": campaigns, "Permissions": definitions})
 }
 
 func (h Handler) GETCreateUser(gc *gin.Context) {
@@ -58,7 +118,7 @@ func (h Handler) GETCreateUser(gc *gin.Context) {
 func (h Handler) GETRequestPermission(gc *gin.Context) {
 	listWorkflowReq := &workflowservice.ListWorkflowExecutionsRequest{
 		Namespace: h.ns,
-		Query:     "`ExecutionStatus`=\"Running\"",
+		Query:     runningUsersQuery,
 	}
 	listResp, err := h.c.ListWorkflow(gc.Request.Context(), listWorkflowReq)
 	if err != nil {
@@ -69,7 +129,38 @@ func (h Handler) GETRequestPermission(gc *gin.Context) {
 	for _, e := range listResp.GetExecutions() {
 		users = append(users, e.GetExecution().GetWorkflowId())
 	}
-	gc.HTML(http.StatusOK, "request_permission.html", users)
+	definitions, err := h.permissionDefinitions(gc)
+	if err != nil {
+		_ = gc.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	gc.HTML(http.StatusOK, "request_permission.html", gin.H{"Permissions": definitions, "Users": users})
+}
+
+func (h Handler) GETPermissions(gc *gin.Context) {
+	definitions, err := h.permissionDefinitions(gc)
+	if err != nil {
+		_ = gc.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	gc.HTML(http.StatusOK, "permissions.html", gin.H{
+		"Permissions": definitions,
+		"RiskLevels":  []string{constants.RiskLevelLow, constants.RiskLevelMedium, constants.RiskLevelHigh},
+	})
+}
+
+func (h Handler) GETRoles(gc *gin.Context) {
+	roles, err := h.roles(gc)
+	if err != nil {
+		_ = gc.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	definitions, err := h.permissionDefinitions(gc)
+	if err != nil {
+		_ = gc.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	gc.HTML(http.StatusOK, "roles.html", gin.H{"Permissions": definitions, "Roles": roles})
 }
 
 func (h Handler) GETUser(gc *gin.Context) {
@@ -89,13 +180,69 @@ func (h Handler) GETUser(gc *gin.Context) {
 		_ = gc.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
-	gc.HTML(http.StatusOK, "user.html", messages.GETUserResponse{
-		AwaitingApproval:   ud.AwaitingApproval,
-		DeletionRequested:  ud.DeletionRequested,
-		DeletionUndoWindow: ud.DeletionScheduledFor.Sub(time.Now().UTC()).String(),
-		Permissions:        ud.Permissions,
-		Username:           gc.Query("id"),
-	})
+	activityOffset := 0
+	if gc.Query("activity_offset") != "" {
+		activityOffset, err = strconv.Atoi(gc.Query("activity_offset"))
+		if err != nil || activityOffset < 0 {
+			gc.AbortWithStatusJSON(http.StatusBadRequest, "activity_offset must be a positive number")
+			return
+		}
+	}
+	ev, err = h.c.QueryWorkflow(gc.Request.Context(), gc.Query("id"), "",
+		constants.AuditLogQueryHandlerName, messages.AuditLogRequest{Limit: 20, Offset: activityOffset})
+	if err != nil {
+		_ = gc.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	activity := messages.AuditLogResponse{}
+	err = ev.Get(&activity)
+	if err != nil {
+		_ = gc.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	adminUsername, err := h.findAdminUsername(gc)
+	if err != nil {
+		_ = gc.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	roles, err := h.roles(gc)
+	if err != nil {
+		_ = gc.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	definitions, err := h.permissionDefinitions(gc)
+	if err != nil {
+		_ = gc.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	permissionExpiresIn := make(map[string]string)
+	for _, e := range ud.PermissionExpirations {
+		permissionExpiresIn[e.Permission] = e.ExpiresAt.Sub(time.Now().UTC()).Round(time.Second).String()
+	}
+	resp := messages.GETUserResponse{
+		Activity:            activity,
+		AdminUsername:       adminUsername,
+		AwaitingApproval:    ud.AwaitingApproval,
+		BreakGlass:          ud.BreakGlass,
+		Definitions:         definitions,
+		DeletionRequested:   ud.DeletionRequested,
+		DeletionUndoWindow:  ud.DeletionScheduledFor.Sub(time.Now().UTC()).Round(time.Second).String(),
+		PendingApprovals:    ud.PendingApprovals,
+		PendingRoles:        ud.PendingRoles,
+		PermissionExpiresIn: permissionExpiresIn,
+		Permissions:         ud.Permissions,
+		Profile:             ud.Profile,
+		Rejections:          ud.Rejections,
+		Roles:               roles,
+		Status:              ud.Status,
+		SuspensionReason:    ud.Suspension.Reason,
+		Username:            gc.Query("id"),
+	}
+	if ud.DeletionScheduledAt.After(time.Now()) {
+		resp.DeletionScheduledAt = ud.DeletionScheduledAt.Local().Format("2006-01-02 15:04 MST")
+		resp.DeletionStartsIn = ud.DeletionScheduledAt.Sub(time.Now().UTC()).Round(time.Second).String()
+	}
+	gc.HTML(http.StatusOK, "user.html", resp)
 }
 
 func (h Handler) GETUsers(gc *gin.Context) {
@@ -103,12 +250,20 @@ func (h Handler) GETUsers(gc *gin.Context) {
 	// number of open executions will fit in a single request.
 	listWorkflowReq := &workflowservice.ListWorkflowExecutionsRequest{
 		Namespace: h.ns,
-		Query:     "`ExecutionStatus`=\"Running\"",
+		Query:     runningUsersQuery,
+	}
+	if gc.Query("scheduled_deletion") == "upcoming" {
+		listWorkflowReq.Query += " AND `scheduled_deletion` IS NOT NULL"
 	}
+	// Filter values are quoted so that they cannot break out of the query
 	if gc.Query("permission") != "" {
-		queryString := "`ExecutionStatus`=\"Running\" AND `permissions`=\"{NAME}\""
-		queryString = strings.Replace(queryString, "{NAME}", gc.Query("permission"), 1)
-		listWorkflowReq.Query = queryString
+		listWorkflowReq.Query += fmt.Sprintf(" AND `permissions`=%q", gc.Query("permission"))
+	}
+	if gc.Query("department") != "" {
+		listWorkflowReq.Query += fmt.Sprintf(" AND `department`=%q", gc.Query("department"))
+	}
+	if gc.Query("manager_id") != "" {
+		listWorkflowReq.Query += fmt.Sprintf(" AND `manager_id`=%q", gc.Query("manager_id"))
 	}
 	var listResponse *workflowservice.ListWorkflowExecutionsResponse
 	for listResponse == nil {
@@ -159,15 +314,25 @@ func (h Handler) GETUsers(gc *gin.Context) {
 	type User struct {
 		Username          string
 		AwaitingApprovals []string
+		BreakGlassActive  bool
+		Department        string
+		ScheduledDeletion string
 	}
 	type UsersResponse struct {
 		AdminUsername                  string
+		Permissions                    []messages.PermissionDefinition
 		Users                          []User
 		FlashUserCreatedMessage        string
 		FlashUserAlreadyCreatedMessage string
 	}
+	definitions, err := h.permissionDefinitions(gc)
+	if err != nil {
+		_ = gc.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
 	response := UsersResponse{
-		Users: make([]User, 0),
+		Permissions: definitions,
+		Users:       make([]User, 0),
 	}
 	if gc.Query("flashUserCreated") != "" {
 		response.FlashUserCreatedMessage = "Created user " + gc.Query("flashUserCreated")
@@ -206,6 +371,34 @@ func (h Handler) GETUsers(gc *gin.Context) {
 		u := User{
 			Username: e.GetExecution().GetWorkflowId(),
 		}
+		breakGlassActiveBytes := e.GetSearchAttributes().
+			GetIndexedFields()[constants.BreakGlassActiveSearchAttributeKey].GetData()
+		if len(breakGlassActiveBytes) > 0 {
+			err := json.Unmarshal(breakGlassActiveBytes, &u.BreakGlassActive)
+			if err != nil {
+				_ = gc.AbortWithError(http.StatusInternalServerError, err)
+				return
+			}
+		}
+		departmentBytes := e.GetSearchAttributes().GetIndexedFields()[constants.DepartmentSearchAttributeKey].GetData()
+		if len(departmentBytes) > 0 {
+			err := json.Unmarshal(departmentBytes, &u.Department)
+			if err != nil {
+				_ = gc.AbortWithError(http.StatusInternalServerError, err)
+				return
+			}
+		}
+		scheduledDeletionBytes := e.GetSearchAttributes().
+			GetIndexedFields()[constants.ScheduledDeletionSearchAttributeKey].GetData()
+		if len(scheduledDeletionBytes) > 0 {
+			var scheduledDeletion time.Time
+			err := json.Unmarshal(scheduledDeletionBytes, &scheduledDeletion)
+			if err != nil {
+				_ = gc.AbortWithError(http.StatusInternalServerError, err)
+				return
+			}
+			u.ScheduledDeletion = scheduledDeletion.Local().Format("2006-01-02 15:04 MST")
+		}
 		if len(awaitingApprovals) > 0 {
 			u.AwaitingApprovals = awaitingApprovals
 		} else {
@@ -216,126 +409,174 @@ func (h Handler) GETUsers(gc *gin.Context) {
 	gc.HTML(http.StatusOK, "users.html", response)
 }
 
-func (h Handler) POSTApprovePermission(gc *gin.Context) {
-	if gc.PostForm("requester_username") == "" {
-		gc.AbortWithStatusJSON(http.StatusBadRequest, "requester_username required and missing")
+func (h Handler) POSTAcknowledgeBreakGlass(gc *gin.Context) {
+	if gc.PostForm("username") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "username required and missing")
 		return
 	}
 	if gc.PostForm("approver_username") == "" {
 		gc.AbortWithStatusJSON(http.StatusBadRequest, "approver_username required and missing")
 		return
 	}
-	if gc.PostForm("permission_type") == "" {
-		gc.AbortWithStatusJSON(http.StatusBadRequest, "permission_type required and missing")
+	if gc.PostForm("id") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "id required and missing")
 		return
 	}
 	updateOptions := client.UpdateWorkflowOptions{
-		WorkflowID: gc.PostForm("requester_username"),
-		UpdateName: constants.ApproveUserPermissionUpdateHandlerName,
+		WorkflowID: gc.PostForm("username"),
+		UpdateID:   gc.PostForm("idempotency_key"),
+		UpdateName: constants.AcknowledgeBreakGlassUpdateHandlerName,
 		Args: []interface{}{
-			&messages.ApproveUserPermissionRequest{
+			&messages.AcknowledgeBreakGlassRequest{
 				ApproverID: gc.PostForm("approver_username"),
-				Permission: gc.PostForm("permission_type"),
+				ID:         gc.PostForm("id"),
+				Notes:      gc.PostForm("notes"),
 			},
 		},
 		WaitForStage: client.WorkflowUpdateStageCompleted,
 	}
 	updateHandle, err := h.c.UpdateWorkflow(gc.Request.Context(), updateOptions)
 	if err != nil {
-		_ = gc.AbortWithError(http.StatusInternalServerError, err)
+		h.abortWithUpdateError(gc, err)
 		return
 	}
-	updateResponse := messages.ApproveUserPermissionResponse{}
+	updateResponse := &messages.AcknowledgeBreakGlassResponse{}
 	err = updateHandle.Get(gc.Request.Context(), &updateResponse)
 	if err != nil {
-		_ = gc.AbortWithError(http.StatusInternalServerError, err)
+		h.abortWithUpdateError(gc, err)
 		return
 	}
-	redirectRoute := "/user?id=" + gc.PostForm("requester_username")
+	redirectRoute := "/user?id=" + gc.PostForm("username")
 	gc.Redirect(http.StatusSeeOther, redirectRoute)
 }
 
-func (h Handler) POSTCreateUser(gc *gin.Context) {
-	if gc.Request.FormValue("username") == "" {
-		gc.String(http.StatusBadRequest, "username required and missing")
+func (h Handler) POSTAddRole(gc *gin.Context) {
+	if gc.PostForm("username") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "username required and missing")
 		return
 	}
-	workflowID := gc.Request.FormValue("username")
-	opts := client.StartWorkflowOptions{
-		ID:                                       workflowID,
-		TaskQueue:                                constants.EntityTaskQueueName,
-		WorkflowExecutionErrorWhenAlreadyStarted: true,
+	if gc.PostForm("role") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "role required and missing")
+		return
 	}
-	workflowInput := messages.UserAccountOrchestrationInput{
-		Permissions:      make([]string, 0),
-		AwaitingApproval: make([]string, 0),
+	updateOptions := client.UpdateWorkflowOptions{
+		WorkflowID: gc.PostForm("username"),
+		UpdateID:   gc.PostForm("idempotency_key"),
+		UpdateName: constants.AddUserRoleUpdateHandlerName,
+		Args: []interface{}{
+			&messages.AddUserRoleRequest{
+				RequestedBy: gc.PostForm("requested_by"),
+				Role:        gc.PostForm("role"),
+			},
+		},
+		WaitForStage: client.WorkflowUpdateStageCompleted,
 	}
-
-	run, err := h.c.ExecuteWorkflow(gc.Request.Context(), opts, "Orchestration", workflowInput)
+	updateHandle, err := h.c.UpdateWorkflow(gc.Request.Context(), updateOptions)
 	if err != nil {
-		var ser *serviceerror.WorkflowExecutionAlreadyStarted
-		if errors.As(err, &ser) {
-			gc.Redirect(http.StatusSeeOther, "/users?flashUserAlreadyCreated="+workflowID)
-			return
-		}
-		_ = gc.AbortWithError(http.StatusInternalServerError, err)
+		h.abortWithUpdateError(gc, err)
 		return
 	}
-	if gc.Request.FormValue("make_user_approver") == "on" {
-		updateOptions := client.UpdateWorkflowOptions{
-			WorkflowID: run.GetID(),
-			UpdateName: constants.CreateUserAccountUpdateHandlerName,
-			Args: []interface{}{
-				&messages.CreateUserAccountRequest{
-					Permissions: []string{constants.PermissionTypeGrantPermissions},
-				},
-			},
-			WaitForStage: client.WorkflowUpdateStageCompleted,
-		}
-		updateHandle, err := h.c.UpdateWorkflow(gc.Request.Context(), updateOptions)
-		if err != nil {
-			_ = gc.AbortWithError(http.StatusInternalServerError, err)
-			return
-		}
-		updateResponse := &messages.CreateUserAccountResponse{}
-		err = updateHandle.Get(gc.Request.Context(), &updateResponse)
-		if err != nil {
-			_ = gc.AbortWithError(http.StatusInternalServerError, err)
+	updateResponse := &messages.AddUserRoleResponse{}
+	err = updateHandle.Get(gc.Request.Context(), &updateResponse)
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	redirectRoute := "/user?id=" + gc.PostForm("username")
+	gc.Redirect(http.StatusSeeOther, redirectRoute)
+}
+
+func (h Handler) POSTApprovePermission(gc *gin.Context) {
+	if gc.PostForm("requester_username") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "requester_username required and missing")
+		return
+	}
+	if gc.PostForm("approver_username") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "approver_username required and missing")
+		return
+	}
+	if gc.PostForm("permission_type") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "permission_type required and missing")
+		return
+	}
+	var expiresAfter time.Duration
+	if gc.PostForm("expires_after") != "" {
+		var err error
+		expiresAfter, err = time.ParseDuration(gc.PostForm("expires_after"))
+		if err != nil || expiresAfter < 0 {
+			gc.AbortWithStatusJSON(http.StatusBadRequest, "expires_after must be a positive duration such as 8h")
 			return
 		}
 	}
-	gc.Redirect(http.StatusSeeOther, "/users?flashUserCreated="+workflowID)
+	updateOptions := client.UpdateWorkflowOptions{
+		WorkflowID: gc.PostForm("requester_username"),
+		UpdateID:   gc.PostForm("idempotency_key"),
+		UpdateName: constants.ApproveUserPermissionUpdateHandlerName,
+		Args: []interface{}{
+			&messages.ApproveUserPermissionRequest{
+				ApproverID:   gc.PostForm("approver_username"),
+				ExpiresAfter: expiresAfter,
+				Permission:   gc.PostForm("permission_type"),
+			},
+		},
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+	}
+	updateHandle, err := h.c.UpdateWorkflow(gc.Request.Context(), updateOptions)
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	updateResponse := messages.ApproveUserPermissionResponse{}
+	err = updateHandle.Get(gc.Request.Context(), &updateResponse)
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	redirectRoute := "/user?id=" + gc.PostForm("requester_username")
+	gc.Redirect(http.StatusSeeOther, redirectRoute)
 }
 
-func (h Handler) POSTDeleteUser(gc *gin.Context) {
+func (h Handler) POSTApproveRole(gc *gin.Context) {
 	if gc.PostForm("username") == "" {
 		gc.AbortWithStatusJSON(http.StatusBadRequest, "username required and missing")
 		return
 	}
+	if gc.PostForm("approver_username") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "approver_username required and missing")
+		return
+	}
+	if gc.PostForm("role") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "role required and missing")
+		return
+	}
 	updateOptions := client.UpdateWorkflowOptions{
 		WorkflowID: gc.PostForm("username"),
-		UpdateName: constants.DeleteUserAccountUpdateHandlerName,
+		UpdateID:   gc.PostForm("idempotency_key"),
+		UpdateName: constants.ApproveUserRoleUpdateHandlerName,
 		Args: []interface{}{
-			&messages.DeleteUserAccountRequest{},
+			&messages.ApproveUserRoleRequest{
+				ApproverID: gc.PostForm("approver_username"),
+				Role:       gc.PostForm("role"),
+			},
 		},
 		WaitForStage: client.WorkflowUpdateStageCompleted,
 	}
 	updateHandle, err := h.c.UpdateWorkflow(gc.Request.Context(), updateOptions)
 	if err != nil {
-		_ = gc.AbortWithError(http.StatusInternalServerError, err)
+		h.abortWithUpdateError(gc, err)
 		return
 	}
-	updateResponse := &messages.DeleteUserAccountResponse{}
+	updateResponse := &messages.ApproveUserRoleResponse{}
 	err = updateHandle.Get(gc.Request.Context(), &updateResponse)
 	if err != nil {
-		_ = gc.AbortWithError(http.StatusInternalServerError, err)
+		h.abortWithUpdateError(gc, err)
 		return
 	}
 	redirectRoute := "/user?id=" + gc.PostForm("username")
 	gc.Redirect(http.StatusSeeOther, redirectRoute)
 }
 
-func (h Handler) POSTRequestPermission(gc *gin.Context) {
+func (h Handler) POSTBreakGlass(gc *gin.Context) {
 	if gc.PostForm("username") == "" {
 		gc.AbortWithStatusJSON(http.StatusBadRequest, "username required and missing")
 		return
@@ -346,54 +587,809 @@ func (h Handler) POSTRequestPermission(gc *gin.Context) {
 	}
 	updateOptions := client.UpdateWorkflowOptions{
 		WorkflowID: gc.PostForm("username"),
-		UpdateName: constants.AddUserPermissionUpdateHandlerName,
+		UpdateID:   gc.PostForm("idempotency_key"),
+		UpdateName: constants.BreakGlassUpdateHandlerName,
 		Args: []interface{}{
-			&messages.AddUserPermissionRequest{
-				Permission: gc.PostForm("permission_type"),
+			&messages.BreakGlassRequest{
+				Justification: gc.PostForm("justification"),
+				Permission:    gc.PostForm("permission_type"),
+				RequestedBy:   gc.PostForm("username"),
 			},
 		},
 		WaitForStage: client.WorkflowUpdateStageCompleted,
 	}
 	updateHandle, err := h.c.UpdateWorkflow(gc.Request.Context(), updateOptions)
 	if err != nil {
-		_ = gc.AbortWithError(http.StatusInternalServerError, err)
+		h.abortWithUpdateError(gc, err)
 		return
 	}
-	updateResponse := &messages.AddUserPermissionResponse{}
+	updateResponse := &messages.BreakGlassResponse{}
 	err = updateHandle.Get(gc.Request.Context(), &updateResponse)
 	if err != nil {
-		_ = gc.AbortWithError(http.StatusInternalServerError, err)
+		h.abortWithUpdateError(gc, err)
 		return
 	}
-	redirectRoute := fmt.Sprintf("/users?permissionRequested=%s&username=%s", gc.PostForm("permission_type"),
-		gc.PostForm("username"))
+	redirectRoute := "/user?id=" + gc.PostForm("username")
 	gc.Redirect(http.StatusSeeOther, redirectRoute)
 }
 
-func (h Handler) POSTUndoDeleteUser(gc *gin.Context) {
-	if gc.PostForm("username") == "" {
-		gc.AbortWithStatusJSON(http.StatusBadRequest, "username required and missing")
+func (h Handler) POSTCampaign(gc *gin.Context) {
+	if gc.PostForm("name") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "name required and missing")
+		return
+	}
+	if len(gc.PostFormArray("permissions")) == 0 {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "permissions required and missing")
+		return
+	}
+	if gc.PostForm("requested_by") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "requested_by required and missing")
+		return
+	}
+	deadlineAfter, err := time.ParseDuration(gc.PostForm("deadline_after"))
+	if err != nil || deadlineAfter <= 0 {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "deadline_after must be a positive duration such as 336h")
+		return
+	}
+	_, err = h.c.ExecuteWorkflow(gc.Request.Context(), client.StartWorkflowOptions{
+		ID:                                       campaign_state.WorkflowID(gc.PostForm("name")),
+		TaskQueue:                                constants.EntityTaskQueueName,
+		WorkflowExecutionErrorWhenAlreadyStarted: true,
+	}, "CampaignOrchestration", messages.CampaignOrchestrationInput{
+		Deadline:    time.Now().Add(deadlineAfter),
+		Permissions: gc.PostFormArray("permissions"),
+		RequestedBy: gc.PostForm("requested_by"),
+		ReviewerID:  strings.TrimSpace(gc.PostForm("reviewer_id")),
+	})
+	if err != nil {
+		var ser *serviceerror.WorkflowExecutionAlreadyStarted
+		if errors.As(err, &ser) {
+			gc.AbortWithStatusJSON(http.StatusConflict,
+				fmt.Sprintf("campaign %s already running", gc.PostForm("name")))
+			return
+		}
+		_ = gc.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	gc.Redirect(http.StatusSeeOther, "/campaign?id="+gc.PostForm("name"))
+}
+
+func (h Handler) POSTCertifyGrant(gc *gin.Context) {
+	if gc.PostForm("campaign") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "campaign required and missing")
 		return
 	}
 	updateOptions := client.UpdateWorkflowOptions{
-		WorkflowID: gc.PostForm("username"),
-		UpdateName: constants.UndoDeleteUserAccountUpdateHandlerName,
+		WorkflowID: campaign_state.WorkflowID(gc.PostForm("campaign")),
+		UpdateID:   gc.PostForm("idempotency_key"),
+		UpdateName: constants.CertifyGrantUpdateHandlerName,
 		Args: []interface{}{
-			&messages.UndoDeleteUserAccountRequest{},
+			&messages.CertifyGrantRequest{
+				Permission: gc.PostForm("permission"),
+				ReviewerID: gc.PostForm("reviewer_id"),
+				UserID:     gc.PostForm("username"),
+			},
 		},
 		WaitForStage: client.WorkflowUpdateStageCompleted,
 	}
 	updateHandle, err := h.c.UpdateWorkflow(gc.Request.Context(), updateOptions)
 	if err != nil {
-		_ = gc.AbortWithError(http.StatusInternalServerError, err)
+		h.abortWithUpdateError(gc, err)
 		return
 	}
-	updateResponse := &messages.UndoDeleteUserAccountResponse{}
+	updateResponse := &messages.CertifyGrantResponse{}
 	err = updateHandle.Get(gc.Request.Context(), &updateResponse)
 	if err != nil {
-		_ = gc.AbortWithError(http.StatusInternalServerError, err)
+		h.abortWithUpdateError(gc, err)
 		return
 	}
-	redirectRoute := "/user?id=" + gc.PostForm("username")
-	gc.Redirect(http.StatusSeeOther, redirectRoute)
+	gc.Redirect(http.StatusSeeOther, "/campaign?id="+gc.PostForm("campaign"))
+}
+
+func (h Handler) POSTCreateUser(gc *gin.Context) {
+	if gc.Request.FormValue("username") == "" {
+		gc.String(http.StatusBadRequest, "username required and missing")
+		return
+	}
+	workflowID := gc.Request.FormValue("username")
+	permissions := make([]string, 0)
+	if gc.Request.FormValue("make_user_approver") == "on" {
+		permissions = append(permissions, constants.PermissionTypeGrantPermissions)
+	}
+	// Every user starts out pending and becomes active once created. The create update is sent with the start so
+	// that a user is never left running without it.
+	updateOperation := client.NewUpdateWithStartWorkflowOperation(client.UpdateWorkflowOptions{
+		UpdateID:   gc.Request.FormValue("idempotency_key"),
+		UpdateName: constants.CreateUserAccountUpdateHandlerName,
+		Args: []interface{}{
+			&messages.CreateUserAccountRequest{
+				Permissions: permissions,
+				Profile:     profileFromForm(gc),
+			},
+		},
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+	})
+	opts := client.StartWorkflowOptions{
+		ID:        workflowID,
+		TaskQueue: constants.EntityTaskQueueName,
+		// A running user answers the create update itself: a retried request completes, anything else is refused
+		WorkflowIDConflictPolicy: enumspb.WORKFLOW_ID_CONFLICT_POLICY_USE_EXISTING,
+		WithStartOperation:       updateOperation,
+	}
+	workflowInput := messages.UserAccountOrchestrationInput{
+		Permissions:      make([]string, 0),
+		AwaitingApproval: make([]string, 0),
+		SchemaVersion:    user_account_state.SnapshotSchemaVersion,
+	}
+	_, err := h.c.ExecuteWorkflow(gc.Request.Context(), opts, "Orchestration", workflowInput)
+	if err != nil {
+		_ = gc.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	updateHandle, err := updateOperation.Get(gc.Request.Context())
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	updateResponse := &messages.CreateUserAccountResponse{}
+	err = updateHandle.Get(gc.Request.Context(), &updateResponse)
+	var appErr *temporal.ApplicationError
+	if errors.As(err, &appErr) && appErr.Type() == constants.UserAlreadyExistsErrorType {
+		gc.Redirect(http.StatusSeeOther, "/users?flashUserAlreadyCreated="+workflowID)
+		return
+	}
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	gc.Redirect(http.StatusSeeOther, "/users?flashUserCreated="+workflowID)
+}
+
+func (h Handler) POSTDelegateApproval(gc *gin.Context) {
+	if gc.PostForm("username") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "username required and missing")
+		return
+	}
+	if gc.PostForm("delegate_username") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "delegate_username required and missing")
+		return
+	}
+	expiresAfter, err := time.ParseDuration(gc.PostForm("expires_after"))
+	if err != nil || expiresAfter <= 0 {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "expires_after must be a positive duration such as 72h")
+		return
+	}
+	updateOptions := client.UpdateWorkflowOptions{
+		WorkflowID: gc.PostForm("username"),
+		UpdateID:   gc.PostForm("idempotency_key"),
+		UpdateName: constants.DelegateApprovalUpdateHandlerName,
+		Args: []interface{}{
+			&messages.DelegateApprovalRequest{
+				DelegateID:   gc.PostForm("delegate_username"),
+				ExpiresAfter: expiresAfter,
+				RequestedBy:  gc.PostForm("username"),
+			},
+		},
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+	}
+	updateHandle, err := h.c.UpdateWorkflow(gc.Request.Context(), updateOptions)
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	updateResponse := &messages.DelegateApprovalResponse{}
+	err = updateHandle.Get(gc.Request.Context(), &updateResponse)
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	redirectRoute := "/user?id=" + gc.PostForm("username")
+	gc.Redirect(http.StatusSeeOther, redirectRoute)
+}
+
+func (h Handler) POSTDeleteUser(gc *gin.Context) {
+	if gc.PostForm("username") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "username required and missing")
+		return
+	}
+	var scheduledAt time.Time
+	if gc.PostForm("scheduled_at") != "" {
+		var err error
+		scheduledAt, err = time.ParseInLocation("2006-01-02T15:04", gc.PostForm("scheduled_at"), time.Local)
+		if err != nil {
+			gc.AbortWithStatusJSON(http.StatusBadRequest, "scheduled_at must be a date and time such as 2024-06-30T17:00")
+			return
+		}
+	}
+	var undoWindow time.Duration
+	if gc.PostForm("undo_window") != "" {
+		var err error
+		undoWindow, err = time.ParseDuration(gc.PostForm("undo_window"))
+		if err != nil || undoWindow < 0 {
+			gc.AbortWithStatusJSON(http.StatusBadRequest, "undo_window must be a positive duration such as 24h")
+			return
+		}
+	}
+	updateOptions := client.UpdateWorkflowOptions{
+		WorkflowID: gc.PostForm("username"),
+		UpdateID:   gc.PostForm("idempotency_key"),
+		UpdateName: constants.DeleteUserAccountUpdateHandlerName,
+		Args: []interface{}{
+			&messages.DeleteUserAccountRequest{
+				DeletionScheduledAt: scheduledAt,
+				RequestedBy:         gc.PostForm("requested_by"),
+				UndoWindow:          undoWindow,
+			},
+		},
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+	}
+	updateHandle, err := h.c.UpdateWorkflow(gc.Request.Context(), updateOptions)
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	updateResponse := &messages.DeleteUserAccountResponse{}
+	err = updateHandle.Get(gc.Request.Context(), &updateResponse)
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	redirectRoute := "/user?id=" + gc.PostForm("username")
+	gc.Redirect(http.StatusSeeOther, redirectRoute)
+}
+
+func (h Handler) POSTRequestPermission(gc *gin.Context) {
+	if gc.PostForm("username") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "username required and missing")
+		return
+	}
+	if gc.PostForm("permission_type") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "permission_type required and missing")
+		return
+	}
+	updateOptions := client.UpdateWorkflowOptions{
+		WorkflowID: gc.PostForm("username"),
+		UpdateID:   gc.PostForm("idempotency_key"),
+		UpdateName: constants.AddUserPermissionUpdateHandlerName,
+		Args: []interface{}{
+			&messages.AddUserPermissionRequest{
+				Permission: gc.PostForm("permission_type"),
+			},
+		},
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+	}
+	updateHandle, err := h.c.UpdateWorkflow(gc.Request.Context(), updateOptions)
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	updateResponse := &messages.AddUserPermissionResponse{}
+	err = updateHandle.Get(gc.Request.Context(), &updateResponse)
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	redirectRoute := fmt.Sprintf("/users?permissionRequested=%s&username=%s", gc.PostForm("permission_type"),
+		gc.PostForm("username"))
+	gc.Redirect(http.StatusSeeOther, redirectRoute)
+}
+
+func (h Handler) POSTPermission(gc *gin.Context) {
+	if gc.PostForm("name") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "name required and missing")
+		return
+	}
+	requiredApprovals := 1
+	if gc.PostForm("required_approvals") != "" {
+		var err error
+		requiredApprovals, err = strconv.Atoi(gc.PostForm("required_approvals"))
+		if err != nil || requiredApprovals < 1 {
+			gc.AbortWithStatusJSON(http.StatusBadRequest, "required_approvals must be a positive number")
+			return
+		}
+	}
+	updateOptions := client.UpdateWorkflowOptions{
+		WorkflowID: constants.PermissionCatalogWorkflowID,
+		UpdateID:   gc.PostForm("idempotency_key"),
+		UpdateName: constants.DefinePermissionUpdateHandlerName,
+		Args: []interface{}{
+			&messages.DefinePermissionRequest{
+				ApproverID: gc.PostForm("approver_username"),
+				Definition: messages.PermissionDefinition{
+					AdminPermission:   gc.PostForm("admin_permission"),
+					ApprovalPolicy:    messages.ApprovalPolicy{RequiredApprovals: requiredApprovals},
+					ApproverAuthority: gc.PostForm("approver_authority"),
+					Description:       gc.PostForm("description"),
+					Name:              gc.PostForm("name"),
+					RiskLevel:         gc.PostForm("risk_level"),
+				},
+			},
+		},
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+	}
+	updateHandle, err := h.c.UpdateWorkflow(gc.Request.Context(), updateOptions)
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	updateResponse := &messages.DefinePermissionResponse{}
+	err = updateHandle.Get(gc.Request.Context(), &updateResponse)
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	gc.Redirect(http.StatusSeeOther, "/permissions")
+}
+
+func (h Handler) POSTRejectPermission(gc *gin.Context) {
+	if gc.PostForm("requester_username") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "requester_username required and missing")
+		return
+	}
+	if gc.PostForm("approver_username") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "approver_username required and missing")
+		return
+	}
+	if gc.PostForm("permission_type") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "permission_type required and missing")
+		return
+	}
+	updateOptions := client.UpdateWorkflowOptions{
+		WorkflowID: gc.PostForm("requester_username"),
+		UpdateID:   gc.PostForm("idempotency_key"),
+		UpdateName: constants.RejectUserPermissionUpdateHandlerName,
+		Args: []interface{}{
+			&messages.RejectUserPermissionRequest{
+				ApproverID: gc.PostForm("approver_username"),
+				Permission: gc.PostForm("permission_type"),
+				Reason:     gc.PostForm("reason"),
+			},
+		},
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+	}
+	updateHandle, err := h.c.UpdateWorkflow(gc.Request.Context(), updateOptions)
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	updateResponse := &messages.RejectUserPermissionResponse{}
+	err = updateHandle.Get(gc.Request.Context(), &updateResponse)
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	redirectRoute := "/user?id=" + gc.PostForm("requester_username")
+	gc.Redirect(http.StatusSeeOther, redirectRoute)
+}
+
+func (h Handler) POSTReinstateUser(gc *gin.Context) {
+	if gc.PostForm("username") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "username required and missing")
+		return
+	}
+	if gc.PostForm("approver_username") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "approver_username required and missing")
+		return
+	}
+	updateOptions := client.UpdateWorkflowOptions{
+		WorkflowID: gc.PostForm("username"),
+		UpdateID:   gc.PostForm("idempotency_key"),
+		UpdateName: constants.ReinstateUserAccountUpdateHandlerName,
+		Args: []interface{}{
+			&messages.ReinstateUserAccountRequest{
+				ApproverID: gc.PostForm("approver_username"),
+			},
+		},
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+	}
+	updateHandle, err := h.c.UpdateWorkflow(gc.Request.Context(), updateOptions)
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	updateResponse := &messages.ReinstateUserAccountResponse{}
+	err = updateHandle.Get(gc.Request.Context(), &updateResponse)
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	redirectRoute := "/user?id=" + gc.PostForm("username")
+	gc.Redirect(http.StatusSeeOther, redirectRoute)
+}
+
+func (h Handler) POSTRemoveRole(gc *gin.Context) {
+	if gc.PostForm("username") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "username required and missing")
+		return
+	}
+	if gc.PostForm("approver_username") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "approver_username required and missing")
+		return
+	}
+	if gc.PostForm("role") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "role required and missing")
+		return
+	}
+	updateOptions := client.UpdateWorkflowOptions{
+		WorkflowID: gc.PostForm("username"),
+		UpdateID:   gc.PostForm("idempotency_key"),
+		UpdateName: constants.RemoveUserRoleUpdateHandlerName,
+		Args: []interface{}{
+			&messages.RemoveUserRoleRequest{
+				ApproverID: gc.PostForm("approver_username"),
+				Role:       gc.PostForm("role"),
+			},
+		},
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+	}
+	updateHandle, err := h.c.UpdateWorkflow(gc.Request.Context(), updateOptions)
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	updateResponse := &messages.RemoveUserRoleResponse{}
+	err = updateHandle.Get(gc.Request.Context(), &updateResponse)
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	redirectRoute := "/user?id=" + gc.PostForm("username")
+	gc.Redirect(http.StatusSeeOther, redirectRoute)
+}
+
+func (h Handler) POSTRevokeDelegation(gc *gin.Context) {
+	if gc.PostForm("username") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "username required and missing")
+		return
+	}
+	if gc.PostForm("delegate_username") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "delegate_username required and missing")
+		return
+	}
+	updateOptions := client.UpdateWorkflowOptions{
+		WorkflowID: gc.PostForm("username"),
+		UpdateID:   gc.PostForm("idempotency_key"),
+		UpdateName: constants.RevokeDelegationUpdateHandlerName,
+		Args: []interface{}{
+			&messages.RevokeDelegationRequest{
+				DelegateID:  gc.PostForm("delegate_username"),
+				RequestedBy: gc.PostForm("username"),
+			},
+		},
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+	}
+	updateHandle, err := h.c.UpdateWorkflow(gc.Request.Context(), updateOptions)
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	updateResponse := &messages.RevokeDelegationResponse{}
+	err = updateHandle.Get(gc.Request.Context(), &updateResponse)
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	redirectRoute := "/user?id=" + gc.PostForm("username")
+	gc.Redirect(http.StatusSeeOther, redirectRoute)
+}
+
+func (h Handler) POSTRevokeGrant(gc *gin.Context) {
+	if gc.PostForm("campaign") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "campaign required and missing")
+		return
+	}
+	updateOptions := client.UpdateWorkflowOptions{
+		WorkflowID: campaign_state.WorkflowID(gc.PostForm("campaign")),
+		UpdateID:   gc.PostForm("idempotency_key"),
+		UpdateName: constants.RevokeGrantUpdateHandlerName,
+		Args: []interface{}{
+			&messages.RevokeGrantRequest{
+				Permission: gc.PostForm("permission"),
+				Reason:     gc.PostForm("reason"),
+				ReviewerID: gc.PostForm("reviewer_id"),
+				UserID:     gc.PostForm("username"),
+			},
+		},
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+	}
+	updateHandle, err := h.c.UpdateWorkflow(gc.Request.Context(), updateOptions)
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	updateResponse := &messages.RevokeGrantResponse{}
+	err = updateHandle.Get(gc.Request.Context(), &updateResponse)
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	gc.Redirect(http.StatusSeeOther, "/campaign?id="+gc.PostForm("campaign"))
+}
+
+func (h Handler) POSTRevokePermission(gc *gin.Context) {
+	if gc.PostForm("username") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "username required and missing")
+		return
+	}
+	if gc.PostForm("approver_username") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "approver_username required and missing")
+		return
+	}
+	if gc.PostForm("permission_type") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "permission_type required and missing")
+		return
+	}
+	updateOptions := client.UpdateWorkflowOptions{
+		WorkflowID: gc.PostForm("username"),
+		UpdateID:   gc.PostForm("idempotency_key"),
+		UpdateName: constants.RevokeUserPermissionUpdateHandlerName,
+		Args: []interface{}{
+			&messages.RevokeUserPermissionRequest{
+				ApproverID: gc.PostForm("approver_username"),
+				Permission: gc.PostForm("permission_type"),
+			},
+		},
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+	}
+	updateHandle, err := h.c.UpdateWorkflow(gc.Request.Context(), updateOptions)
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	updateResponse := &messages.RevokeUserPermissionResponse{}
+	err = updateHandle.Get(gc.Request.Context(), &updateResponse)
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	redirectRoute := "/user?id=" + gc.PostForm("username")
+	gc.Redirect(http.StatusSeeOther, redirectRoute)
+}
+
+func (h Handler) POSTRole(gc *gin.Context) {
+	if gc.PostForm("name") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "name required and missing")
+		return
+	}
+	// Each role is its own entity: start it unless it is running, then (re)define it
+	_, err := h.c.ExecuteWorkflow(gc.Request.Context(), client.StartWorkflowOptions{
+		ID:                       role_state.WorkflowID(gc.PostForm("name")),
+		TaskQueue:                constants.EntityTaskQueueName,
+		WorkflowIDConflictPolicy: enumspb.WORKFLOW_ID_CONFLICT_POLICY_USE_EXISTING,
+	}, "RoleOrchestration", messages.RoleOrchestrationInput{})
+	if err != nil {
+		_ = gc.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	updateOptions := client.UpdateWorkflowOptions{
+		WorkflowID: role_state.WorkflowID(gc.PostForm("name")),
+		UpdateID:   gc.PostForm("idempotency_key"),
+		UpdateName: constants.DefineRoleUpdateHandlerName,
+		Args: []interface{}{
+			&messages.DefineRoleRequest{
+				ApproverID:  gc.PostForm("approver_username"),
+				Description: gc.PostForm("description"),
+				Permissions: gc.PostFormArray("permissions"),
+			},
+		},
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+	}
+	updateHandle, err := h.c.UpdateWorkflow(gc.Request.Context(), updateOptions)
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	updateResponse := &messages.DefineRoleResponse{}
+	err = updateHandle.Get(gc.Request.Context(), &updateResponse)
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	gc.Redirect(http.StatusSeeOther, "/roles")
+}
+
+func (h Handler) POSTSuspendUser(gc *gin.Context) {
+	if gc.PostForm("username") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "username required and missing")
+		return
+	}
+	updateOptions := client.UpdateWorkflowOptions{
+		WorkflowID: gc.PostForm("username"),
+		UpdateID:   gc.PostForm("idempotency_key"),
+		UpdateName: constants.SuspendUserAccountUpdateHandlerName,
+		Args: []interface{}{
+			&messages.SuspendUserAccountRequest{
+				Reason:      gc.PostForm("reason"),
+				RequestedBy: gc.PostForm("requested_by"),
+			},
+		},
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+	}
+	updateHandle, err := h.c.UpdateWorkflow(gc.Request.Context(), updateOptions)
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	updateResponse := &messages.SuspendUserAccountResponse{}
+	err = updateHandle.Get(gc.Request.Context(), &updateResponse)
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	redirectRoute := "/user?id=" + gc.PostForm("username")
+	gc.Redirect(http.StatusSeeOther, redirectRoute)
+}
+
+func (h Handler) POSTUndoDeleteUser(gc *gin.Context) {
+	if gc.PostForm("username") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "username required and missing")
+		return
+	}
+	updateOptions := client.UpdateWorkflowOptions{
+		WorkflowID: gc.PostForm("username"),
+		UpdateID:   gc.PostForm("idempotency_key"),
+		UpdateName: constants.UndoDeleteUserAccountUpdateHandlerName,
+		Args: []interface{}{
+			&messages.UndoDeleteUserAccountRequest{
+				RequestedBy: gc.PostForm("requested_by"),
+			},
+		},
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+	}
+	updateHandle, err := h.c.UpdateWorkflow(gc.Request.Context(), updateOptions)
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	updateResponse := &messages.UndoDeleteUserAccountResponse{}
+	err = updateHandle.Get(gc.Request.Context(), &updateResponse)
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	redirectRoute := "/user?id=" + gc.PostForm("username")
+	gc.Redirect(http.StatusSeeOther, redirectRoute)
+}
+
+func (h Handler) POSTUpdateProfile(gc *gin.Context) {
+	if gc.PostForm("username") == "" {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, "username required and missing")
+		return
+	}
+	updateOptions := client.UpdateWorkflowOptions{
+		WorkflowID: gc.PostForm("username"),
+		UpdateID:   gc.PostForm("idempotency_key"),
+		UpdateName: constants.UpdateUserProfileUpdateHandlerName,
+		Args: []interface{}{
+			&messages.UpdateUserProfileRequest{
+				Profile:     profileFromForm(gc),
+				RequestedBy: gc.PostForm("requested_by"),
+			},
+		},
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+	}
+	updateHandle, err := h.c.UpdateWorkflow(gc.Request.Context(), updateOptions)
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	updateResponse := &messages.UpdateUserProfileResponse{}
+	err = updateHandle.Get(gc.Request.Context(), &updateResponse)
+	if err != nil {
+		h.abortWithUpdateError(gc, err)
+		return
+	}
+	redirectRoute := "/user?id=" + gc.PostForm("username")
+	gc.Redirect(http.StatusSeeOther, redirectRoute)
+}
+
+// abortWithUpdateError answers a failed update. Requests refused by an update validator are the caller's fault and
+// get a 400, or a 409 when the user's lifecycle state does not allow them. Requests refused while the entity finishes
+// its in-flight updates get a 503 and may be retried, anything else is a 500.
+func (h Handler) abortWithUpdateError(gc *gin.Context, err error) {
+	var appErr *temporal.ApplicationError
+	if errors.As(err, &appErr) && appErr.Type() == constants.DrainingErrorType {
+		gc.Header("Retry-After", "1")
+		gc.AbortWithStatusJSON(http.StatusServiceUnavailable, appErr.Message())
+		return
+	}
+	if errors.As(err, &appErr) && appErr.Type() == constants.InvalidRequestErrorType {
+		gc.AbortWithStatusJSON(http.StatusBadRequest, appErr.Message())
+		return
+	}
+	if errors.As(err, &appErr) && appErr.Type() == constants.IllegalTransitionErrorType {
+		gc.AbortWithStatusJSON(http.StatusConflict, appErr.Message())
+		return
+	}
+	_ = gc.AbortWithError(http.StatusInternalServerError, err)
+}
+
+// campaignSummary returns the progress of a running campaign, or the summary a closed campaign returned.
+func (h Handler) campaignSummary(gc *gin.Context, workflowID string) (messages.CampaignSummary, error) {
+	summary := messages.CampaignSummary{}
+	desc, err := h.c.DescribeWorkflowExecution(gc.Request.Context(), workflowID, "")
+	if err != nil {
+		return summary, err
+	}
+	if desc.GetWorkflowExecutionInfo().GetStatus() == enumspb.WORKFLOW_EXECUTION_STATUS_RUNNING {
+		ev, err := h.c.QueryWorkflow(gc.Request.Context(), workflowID, "", constants.CampaignProgressQueryHandlerName)
+		if err != nil {
+			return summary, err
+		}
+		err = ev.Get(&summary)
+		return summary, err
+	}
+	err = h.c.GetWorkflow(gc.Request.Context(), workflowID, "").Get(gc.Request.Context(), &summary)
+	return summary, err
+}
+
+// findAdminUsername returns the ID of a running user entity that holds grant_permissions, or an empty string if there
+// is none. Like the users view this is a demo affordance standing in for the signed-in approver.
+func (h Handler) findAdminUsername(gc *gin.Context) (string, error) {
+	queryString := runningUsersQuery + " AND `permissions`=\"{NAME}\""
+	queryString = strings.Replace(queryString, "{NAME}", constants.PermissionTypeGrantPermissions, 1)
+	listResp, err := h.c.ListWorkflow(gc.Request.Context(), &workflowservice.ListWorkflowExecutionsRequest{
+		Namespace: h.ns,
+		PageSize:  1,
+		Query:     queryString,
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, e := range listResp.GetExecutions() {
+		return e.GetExecution().GetWorkflowId(), nil
+	}
+	return "", nil
+}
+
+// permissionDefinitions returns every permission type in the permission catalog entity.
+func (h Handler) permissionDefinitions(gc *gin.Context) ([]messages.PermissionDefinition, error) {
+	ev, err := h.c.QueryWorkflow(gc.Request.Context(), constants.PermissionCatalogWorkflowID, "",
+		constants.PermissionDefinitionsQueryHandlerName)
+	if err != nil {
+		return nil, err
+	}
+	resp := messages.PermissionDefinitionsResponse{}
+	err = ev.Get(&resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Definitions, nil
+}
+
+// roles returns the details of every running role entity.
+func (h Handler) roles(gc *gin.Context) ([]messages.RoleDetailsResponse, error) {
+	listResp, err := h.c.ListWorkflow(gc.Request.Context(), &workflowservice.ListWorkflowExecutionsRequest{
+		Namespace: h.ns,
+		Query:     runningRolesQuery,
+	})
+	if err != nil {
+		return nil, err
+	}
+	roles := make([]messages.RoleDetailsResponse, 0)
+	for _, e := range listResp.GetExecutions() {
+		ev, err := h.c.QueryWorkflow(gc.Request.Context(), e.GetExecution().GetWorkflowId(), "",
+			constants.RoleDetailsQueryHandlerName)
+		if err != nil {
+			return nil, err
+		}
+		role := messages.RoleDetailsResponse{}
+		err = ev.Get(&role)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
+
+// profileFromForm reads the profile fields shared by the create user and user pages.
+func profileFromForm(gc *gin.Context) messages.UserProfile {
+	return messages.UserProfile{
+		Department:   strings.TrimSpace(gc.Request.FormValue("department")),
+		DisplayName:  strings.TrimSpace(gc.Request.FormValue("display_name")),
+		Email:        strings.TrimSpace(gc.Request.FormValue("email")),
+		EmployeeType: gc.Request.FormValue("employee_type"),
+		ManagerID:    strings.TrimSpace(gc.Request.FormValue("manager_id")),
+	}
 }
@@ -3,8 +3,10 @@ package router
 import (
 	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/temporal-sa/temporal-entity-lifecycle-go/cmd/web/handler"
 	"go.temporal.io/sdk/client"
+	"html/template"
 	"os"
 )
 
@@ -26,16 +28,41 @@ func New(c client.Client) (*Router, error) {
 	if err != nil {
 		return nil, err
 	}
+	r.SetFuncMap(template.FuncMap{
+		// idempotencyKey gives each rendered form a fresh update ID so that resubmitting it is deduplicated by Temporal
+		"idempotencyKey": uuid.NewString,
+	})
 	r.LoadHTMLGlob("templates/*.html")
 	r.GET("/approve_permission", rh.GETApprovePermission)
+	r.GET("/campaign", rh.GETCampaign)
+	r.GET("/campaigns", rh.GETCampaigns)
 	r.GET("/create_user", rh.GETCreateUser)
+	r.GET("/permissions", rh.GETPermissions)
+	r.GET("/roles", rh.GETRoles)
 	r.GET("/user", rh.GETUser)
 	r.GET("/users", rh.GETUsers)
 	r.GET("/request_permission", rh.GETRequestPermission)
+	r.POST("/acknowledge_break_glass", rh.POSTAcknowledgeBreakGlass)
+	r.POST("/add_role", rh.POSTAddRole)
 	r.POST("/approve_permission", rh.POSTApprovePermission)
+	r.POST("/approve_role", rh.POSTApproveRole)
+	r.POST("/break_glass", rh.POSTBreakGlass)
+	r.POST("/campaigns", rh.POSTCampaign)
+	r.POST("/certify_grant", rh.POSTCertifyGrant)
 	r.POST("/create_user", rh.POSTCreateUser)
+	r.POST("/delegate_approval", rh.POSTDelegateApproval)
 	r.POST("/delete_user", rh.POSTDeleteUser)
+	r.POST("/permissions", rh.POSTPermission)
+	r.POST("/reinstate_user", rh.POSTReinstateUser)
+	r.POST("/reject_permission", rh.POSTRejectPermission)
+	r.POST("/remove_role", rh.POSTRemoveRole)
+	r.POST("/revoke_delegation", rh.POSTRevokeDelegation)
+	r.POST("/revoke_grant", rh.POSTRevokeGrant)
+	r.POST("/revoke_permission", rh.POSTRevokePermission)
+	r.POST("/roles", rh.POSTRole)
+	r.POST("/suspend_user", rh.POSTSuspendUser)
 	r.POST("/undo_delete_user", rh.POSTUndoDeleteUser)
+	r.POST("/update_profile", rh.POSTUpdateProfile)
 	r.POST("/request_permission", rh.POSTRequestPermission)
 	return r, nil
 }
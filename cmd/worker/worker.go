@@ -1,10 +1,15 @@
 package main
 
 import (
+	"context"
 	"github.com/temporal-sa/temporal-entity-lifecycle-go/config"
 	"github.com/temporal-sa/temporal-entity-lifecycle-go/constants"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/messages"
 	"github.com/temporal-sa/temporal-entity-lifecycle-go/orchestrations"
 	"github.com/temporal-sa/temporal-entity-lifecycle-go/orchestrations/activity_handler"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/permission_catalog_state"
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/worker"
 	"log"
 )
@@ -16,14 +21,63 @@ func main() {
 	if err != nil {
 		log.Fatalln("Unable to initialize activity handler", err)
 	}
+	approvalSLA, err := config.GetApprovalSLA()
+	if err != nil {
+		log.Fatalln("Unable to load approval SLA", err)
+	}
+	deletionPolicy, err := config.GetDeletionPolicy()
+	if err != nil {
+		log.Fatalln("Unable to load deletion policy", err)
+	}
+	separationOfDutiesRules, err := config.GetSeparationOfDutiesRules()
+	if err != nil {
+		log.Fatalln("Unable to load separation of duties rules", err)
+	}
 	w := worker.New(c, constants.EntityTaskQueueName, worker.Options{})
-	oh, err := orchestrations.New()
+	oh, err := orchestrations.New(
+		orchestrations.WithApprovalPolicies(config.GetApprovalPolicies()),
+		orchestrations.WithApprovalSLA(approvalSLA),
+		orchestrations.WithDeletionPolicy(deletionPolicy),
+		orchestrations.WithSeparationOfDutiesRules(separationOfDutiesRules))
 	if err != nil {
 		log.Fatalln("unable to init orchestrations handler", err)
 	}
+	ch, err := orchestrations.NewPermissionCatalog()
+	if err != nil {
+		log.Fatalln("unable to init permission catalog handler", err)
+	}
+	rh, err := orchestrations.NewRole()
+	if err != nil {
+		log.Fatalln("unable to init role handler", err)
+	}
+	cah, err := orchestrations.NewCampaign()
+	if err != nil {
+		log.Fatalln("unable to init campaign handler", err)
+	}
 	w.RegisterWorkflow(oh.Orchestration)
+	w.RegisterWorkflow(cah.CampaignOrchestration)
+	w.RegisterWorkflow(ch.PermissionCatalogOrchestration)
+	w.RegisterWorkflow(rh.RoleOrchestration)
 	w.RegisterActivity(ah.VerifyApprover)
+	w.RegisterActivity(ah.VerifyCampaignReviewer)
+	w.RegisterActivity(ah.ValidatePermission)
 	w.RegisterActivity(ah.SendNotifications)
+	w.RegisterActivity(ah.GetRole)
+	w.RegisterActivity(ah.JoinRole)
+	w.RegisterActivity(ah.LeaveRole)
+	w.RegisterActivity(ah.ListGrants)
+	w.RegisterActivity(ah.RevokeCampaignGrant)
+	// The catalog is a singleton entity: start it seeded with the default permission types unless it is running
+	_, err = c.ExecuteWorkflow(context.Background(), client.StartWorkflowOptions{
+		ID:                       constants.PermissionCatalogWorkflowID,
+		TaskQueue:                constants.EntityTaskQueueName,
+		WorkflowIDConflictPolicy: enumspb.WORKFLOW_ID_CONFLICT_POLICY_USE_EXISTING,
+	}, ch.PermissionCatalogOrchestration, messages.PermissionCatalogOrchestrationInput{
+		Definitions: permission_catalog_state.DefaultDefinitions(),
+	})
+	if err != nil {
+		log.Fatalln("Unable to start permission catalog", err)
+	}
 	err = w.Run(worker.InterruptCh())
 	if err != nil {
 		log.Fatalln("Unable to start worker", err)
@@ -2,10 +2,16 @@ package config
 
 import (
 	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/messages"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/permission_catalog_state"
 	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/converter"
 	"os"
 	"strings"
+	"time"
 )
 
 var c client.Client
@@ -53,3 +59,102 @@ func MustGetClient() client.Client {
 	c = tmp
 	return c
 }
+
+// GetApprovalPolicies returns how many distinct approvers each permission type requires before it is granted. The
+// permission catalog is authoritative; these policies, taken from the catalog's seed definitions, only apply to
+// requests that were not validated against it.
+func GetApprovalPolicies() map[string]messages.ApprovalPolicy {
+	policies := make(map[string]messages.ApprovalPolicy)
+	for _, d := range permission_catalog_state.DefaultDefinitions() {
+		policies[d.Name] = d.ApprovalPolicy
+	}
+	return policies
+}
+
+// GetApprovalSLA returns the reminder, escalation and auto-deny schedule for pending permission requests. Each stage
+// can be overridden with a Go duration string (e.g. "36h") in APPROVAL_REMIND_AFTER, APPROVAL_ESCALATE_AFTER and
+// APPROVAL_DENY_AFTER; "0" disables a stage. APPROVAL_APPROVER_POOL and APPROVAL_ESCALATION_POOL name who is notified.
+func GetApprovalSLA() (messages.ApprovalSLA, error) {
+	sla := messages.ApprovalSLA{
+		ApproverPool:           "approvers",
+		DenyAfter:              7 * 24 * time.Hour,
+		EscalateAfter:          72 * time.Hour,
+		EscalationApproverPool: "escalation_approvers",
+		RemindAfter:            24 * time.Hour,
+	}
+	for key, d := range map[string]*time.Duration{
+		"APPROVAL_DENY_AFTER":     &sla.DenyAfter,
+		"APPROVAL_ESCALATE_AFTER": &sla.EscalateAfter,
+		"APPROVAL_REMIND_AFTER":   &sla.RemindAfter,
+	} {
+		if strings.TrimSpace(os.Getenv(key)) == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(os.Getenv(key))
+		if err != nil {
+			return messages.ApprovalSLA{}, errors.Join(errors.New(fmt.Sprintf("%s must be a duration", key)), err)
+		}
+		*d = parsed
+	}
+	if pool := strings.TrimSpace(os.Getenv("APPROVAL_APPROVER_POOL")); pool != "" {
+		sla.ApproverPool = pool
+	}
+	if pool := strings.TrimSpace(os.Getenv("APPROVAL_ESCALATION_POOL")); pool != "" {
+		sla.EscalationApproverPool = pool
+	}
+	return sla, nil
+}
+
+// GetDeletionPolicy returns how long a deletion can be undone. DELETION_UNDO_WINDOW sets the default window, and
+// DELETION_UNDO_WINDOW_MIN and DELETION_UNDO_WINDOW_MAX bound the window a delete request may ask for instead. Each
+// is a Go duration string; a maximum of "0" leaves the window unbounded.
+func GetDeletionPolicy() (messages.DeletionPolicy, error) {
+	policy := messages.DeletionPolicy{
+		DefaultUndoWindow: 60 * time.Second,
+		MaxUndoWindow:     30 * 24 * time.Hour,
+		MinUndoWindow:     0,
+	}
+	for key, d := range map[string]*time.Duration{
+		"DELETION_UNDO_WINDOW":     &policy.DefaultUndoWindow,
+		"DELETION_UNDO_WINDOW_MAX": &policy.MaxUndoWindow,
+		"DELETION_UNDO_WINDOW_MIN": &policy.MinUndoWindow,
+	} {
+		if strings.TrimSpace(os.Getenv(key)) == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(os.Getenv(key))
+		if err != nil {
+			return messages.DeletionPolicy{}, errors.Join(errors.New(fmt.Sprintf("%s must be a duration", key)), err)
+		}
+		*d = parsed
+	}
+	if policy.DefaultUndoWindow <= 0 || policy.DefaultUndoWindow < policy.MinUndoWindow ||
+		(policy.MaxUndoWindow > 0 && policy.DefaultUndoWindow > policy.MaxUndoWindow) {
+		return messages.DeletionPolicy{}, errors.New(fmt.Sprintf(
+			"DELETION_UNDO_WINDOW %s must be positive and between DELETION_UNDO_WINDOW_MIN %s and DELETION_UNDO_WINDOW_MAX %s",
+			policy.DefaultUndoWindow, policy.MinUndoWindow, policy.MaxUndoWindow))
+	}
+	return policy, nil
+}
+
+// GetSeparationOfDutiesRules returns the separation of duties rules declared as a JSON array in
+// SEPARATION_OF_DUTIES_RULES, e.g. [{"Name":"no-self-service","Permission":"grant_permissions","ConflictsWith":"read_files"}].
+// No rules apply when the variable is unset.
+func GetSeparationOfDutiesRules() ([]messages.SeparationOfDutiesRule, error) {
+	rules := make([]messages.SeparationOfDutiesRule, 0)
+	raw := strings.TrimSpace(os.Getenv("SEPARATION_OF_DUTIES_RULES"))
+	if raw == "" {
+		return rules, nil
+	}
+	err := json.Unmarshal([]byte(raw), &rules)
+	if err != nil {
+		return nil, errors.Join(errors.New("SEPARATION_OF_DUTIES_RULES must be a JSON array of rules"), err)
+	}
+	for _, rule := range rules {
+		if rule.Permission == "" || rule.ConflictsWith == "" {
+			return nil, errors.New(fmt.Sprintf("separation of duties rule %q requires Permission and ConflictsWith",
+				rule.Name))
+		}
+	}
+	return rules, nil
+}
@@ -1,17 +1,85 @@
 package constants
 
 const (
+	AcknowledgeBreakGlassUpdateHandlerName = "acknowledge_break_glass"
+	AddRoleMemberUpdateHandlerName         = "add_member"
 	AddUserPermissionUpdateHandlerName     = "add_permission"
+	AddUserRoleUpdateHandlerName           = "add_role"
+	ApprovalDeadlineExceededReason         = "approval deadline exceeded"
 	ApproveUserPermissionUpdateHandlerName = "approve_permission"
+	ApproveUserRoleUpdateHandlerName       = "approve_role"
+	ApproverAuthorityPrefix                = "approve:"
+	AuditActionBreakGlassExpired           = "break_glass_expired"
+	AuditActionDelegationExpired           = "delegation_expired"
+	AuditActionDeletionCompleted           = "deletion_completed"
+	AuditActionPermissionExpired           = "permission_expired"
+	AuditActionRolePermissionsChanged      = "role_permissions_changed"
+	AuditLogQueryHandlerName               = "audit_log"
+	AuditOutcomeFailed                     = "failed"
+	AuditOutcomeSucceeded                  = "succeeded"
 	AwaitingApprovalQueryHandlerName       = "awaiting_approval"
 	AwaitingApprovalSearchAttributeKey     = "awaiting_approval"
+	BreakGlassActiveSearchAttributeKey     = "break_glass_active"
+	BreakGlassUpdateHandlerName            = "break_glass"
+	CampaignDecisionAutoRevoked            = "auto_revoked"
+	CampaignDecisionCertified              = "certified"
+	CampaignDecisionRevoked                = "revoked"
+	CampaignProgressQueryHandlerName       = "campaign_progress"
+	CampaignWorkflowIDPrefix               = "campaign:"
+	CertificationDeadlineExceededReason    = "certification deadline exceeded"
+	CertifyGrantUpdateHandlerName          = "certify_grant"
 	CreateUserAccountUpdateHandlerName     = "create"
+	DefinePermissionUpdateHandlerName      = "define_permission"
+	DefineRoleUpdateHandlerName            = "define_role"
+	DelegateApprovalUpdateHandlerName      = "delegate_approval"
+	DelegatesSearchAttributeKey            = "delegates"
 	DeleteUserAccountUpdateHandlerName     = "delete"
+	DepartmentSearchAttributeKey           = "department"
+	DrainingErrorType                      = "Draining"
+	EmployeeTypeContractor                 = "contractor"
+	EmployeeTypeEmployee                   = "employee"
+	EmployeeTypeIntern                     = "intern"
 	EntityTaskQueueName                    = "entity"
+	IllegalTransitionErrorType             = "IllegalTransition"
+	InvalidRequestErrorType                = "InvalidRequest"
+	ManagerIDSearchAttributeKey            = "manager_id"
+	NotificationTypeApprovalEscalation     = "approval_escalation"
+	NotificationTypeApprovalReminder       = "approval_reminder"
+	NotificationTypeBreakGlassReview       = "break_glass_review"
+	NotificationTypeRecertificationReport  = "recertification_report"
+	NotificationTypeRecertificationReview  = "recertification_review"
+	PermissionCatalogWorkflowID            = "permission_catalog"
+	PermissionDefinitionsQueryHandlerName  = "permission_definitions"
 	PermissionsGrantedQueryHandlerName     = "granted"
 	PermissionsSearchAttributeKey          = "permissions"
+	PermissionTypeApproveGrantPermissions  = ApproverAuthorityPrefix + PermissionTypeGrantPermissions
+	PermissionTypeApproveReadFiles         = ApproverAuthorityPrefix + PermissionTypeReadFiles
 	PermissionTypeGrantPermissions         = "grant_permissions"
 	PermissionTypeReadFiles                = "read_files"
+	ReinstateUserAccountUpdateHandlerName  = "reinstate"
+	RejectUserPermissionUpdateHandlerName  = "reject_permission"
+	RemoveRoleMemberUpdateHandlerName      = "remove_member"
+	RemoveUserRoleUpdateHandlerName        = "remove_role"
+	RevokeDelegationUpdateHandlerName      = "revoke_delegation"
+	RevokeGrantUpdateHandlerName           = "revoke_grant"
+	RevokeUserPermissionUpdateHandlerName  = "revoke_permission"
+	RiskLevelHigh                          = "high"
+	RiskLevelLow                           = "low"
+	RiskLevelMedium                        = "medium"
+	RoleDetailsQueryHandlerName            = "role_details"
+	RolePermissionsChangedSignalName       = "role_permissions_changed"
+	RoleWorkflowIDPrefix                   = "role:"
+	ScheduledDeletionSearchAttributeKey    = "scheduled_deletion"
+	StatusSearchAttributeKey               = "status"
+	SuspendUserAccountUpdateHandlerName    = "suspend"
+	SystemActorID                          = "system"
 	UndoDeleteUserAccountUpdateHandlerName = "undo_delete"
+	UpdateUserProfileUpdateHandlerName     = "update_profile"
+	UserAlreadyExistsErrorType             = "UserAlreadyExists"
 	UserDetailsQueryHandlerName            = "user_details"
+	UserStatusActive                       = "active"
+	UserStatusDeleted                      = "deleted"
+	UserStatusPending                      = "pending"
+	UserStatusPendingDeletion              = "pending_deletion"
+	UserStatusSuspended                    = "suspended"
 )
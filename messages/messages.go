@@ -2,61 +2,440 @@ package messages
 
 import "time"
 
+type AcknowledgeBreakGlassResponse struct{}
+type AcknowledgeBreakGlassRequest struct {
+	ApproverID string
+	ID         string
+	Notes      string
+}
+type AddRoleMemberResponse struct {
+	Permissions []string
+}
+type AddRoleMemberRequest struct {
+	UserID string
+}
 type AddUserPermissionResponse struct{}
 type AddUserPermissionRequest struct {
 	Permission string
 }
-type ApproveUserPermissionResponse struct{}
+type AddUserRoleResponse struct{}
+type AddUserRoleRequest struct {
+	RequestedBy string
+	Role        string
+}
+type ApprovalPolicy struct {
+	RequiredApprovals int
+}
+type ApprovalSLA struct {
+	ApproverPool           string
+	DenyAfter              time.Duration
+	EscalateAfter          time.Duration
+	EscalationApproverPool string
+	RemindAfter            time.Duration
+}
+type ApproveUserPermissionResponse struct {
+	Approvals         int
+	Granted           bool
+	RequiredApprovals int
+}
 type ApproveUserPermissionRequest struct {
+	ApproverID   string
+	ExpiresAfter time.Duration
+	Permission   string
+}
+type ApproveUserRoleResponse struct{}
+type ApproveUserRoleRequest struct {
 	ApproverID string
+	Role       string
+}
+type AuditEntry struct {
+	Action     string
+	Actor      string
+	At         time.Time
+	OnBehalfOf string
+	Outcome    string
 	Permission string
+	Reason     string
+	Role       string
+}
+type AuditLogRequest struct {
+	Limit  int
+	Offset int
+}
+type AuditLogResponse struct {
+	Compacted  int
+	Entries    []AuditEntry
+	NextOffset int
+	Total      int
 }
 type AwaitingApprovalResponse struct {
 	Permissions []string
 }
+type BreakGlassAccess struct {
+	Active        bool
+	ExpiresAt     time.Time
+	GrantedAt     time.Time
+	ID            string
+	Justification string
+	Permission    string
+	RequestedBy   string
+	ReviewedAt    time.Time
+	ReviewedBy    string
+	ReviewNotes   string
+}
+type BreakGlassResponse struct {
+	ExpiresAt time.Time
+	ID        string
+}
+type BreakGlassRequest struct {
+	Justification string
+	Permission    string
+	RequestedBy   string
+}
+type CampaignItem struct {
+	DecidedAt  time.Time
+	DecidedBy  string
+	Decision   string
+	Error      string
+	Permission string
+	Reason     string
+	ReviewerID string
+	UserID     string
+}
+type CampaignOrchestrationInput struct {
+	Deadline    time.Time
+	Enumerated  bool
+	Items       []CampaignItem
+	Permissions []string
+	RequestedBy string
+	ReviewerID  string
+}
+type CampaignSummary struct {
+	AutoRevoked int
+	Certified   int
+	ClosedAt    time.Time
+	Deadline    time.Time
+	Failed      int
+	Items       []CampaignItem
+	Name        string
+	Pending     int
+	Permissions []string
+	RequestedBy string
+	Revoked     int
+	ReviewerID  string
+	Total       int
+}
+type CertifyGrantResponse struct{}
+type CertifyGrantRequest struct {
+	Permission string
+	ReviewerID string
+	UserID     string
+}
 type CreateUserAccountResponse struct{}
 type CreateUserAccountRequest struct {
 	Permissions []string
+	Profile     UserProfile
+	RequestedBy string
+}
+type DefinePermissionResponse struct{}
+type DefinePermissionRequest struct {
+	ApproverID string
+	Definition PermissionDefinition
+}
+type DefineRoleResponse struct{}
+type DefineRoleRequest struct {
+	ApproverID  string
+	Description string
+	Permissions []string
+}
+type DelegateApprovalResponse struct{}
+type DelegateApprovalRequest struct {
+	DelegateID   string
+	ExpiresAfter time.Duration
+	RequestedBy  string
+}
+type Delegation struct {
+	DelegateID string
+	ExpiresAt  time.Time
+	GrantedAt  time.Time
 }
 type DeleteUserAccountResponse struct{}
 type DeleteUserAccountRequest struct {
 	DeletionRequestedAt time.Time
 	DeletionScheduledAt time.Time
+	RequestedBy         string
+	UndoWindow          time.Duration
+}
+type DeletionPolicy struct {
+	DefaultUndoWindow time.Duration
+	MaxUndoWindow     time.Duration
+	MinUndoWindow     time.Duration
+}
+type GetRoleRequest struct {
+	Role string
+}
+type GetRoleResponse struct {
+	Found bool
+	Role  RoleDetailsResponse
 }
 type GETUserResponse struct {
-	AwaitingApproval   AwaitingApprovalResponse
-	DeletionRequested  bool
-	DeletionUndoWindow string
-	Permissions        PermissionsGrantedResponse
-	Username           string
+	Activity            AuditLogResponse
+	AdminUsername       string
+	AwaitingApproval    AwaitingApprovalResponse
+	BreakGlass          []BreakGlassAccess
+	Definitions         []PermissionDefinition
+	DeletionRequested   bool
+	DeletionScheduledAt string
+	DeletionStartsIn    string
+	DeletionUndoWindow  string
+	PendingApprovals    []PendingApproval
+	PendingRoles        []PendingRoleAssignment
+	PermissionExpiresIn map[string]string
+	Permissions         PermissionsGrantedResponse
+	Profile             UserProfile
+	Rejections          []PermissionRejection
+	Roles               []RoleDetailsResponse
+	Status              string
+	SuspensionReason    string
+	Username            string
+}
+type JoinRoleRequest struct {
+	Role   string
+	UserID string
+}
+type JoinRoleResponse struct {
+	Permissions []string
+}
+type LeaveRoleRequest struct {
+	Role   string
+	UserID string
+}
+type LeaveRoleResponse struct{}
+type ListGrantsRequest struct {
+	Permissions []string
+}
+type ListGrantsResponse struct {
+	Grants []PermissionGrant
+}
+type PendingApproval struct {
+	Approvals         []string
+	Escalated         bool
+	ExpiresAfter      time.Duration
+	Permission        string
+	Reminded          bool
+	RequestedAt       time.Time
+	RequiredApprovals int
+	SLA               ApprovalSLA
+}
+type PendingRoleAssignment struct {
+	RequestedAt time.Time
+	RequestedBy string
+	Role        string
+}
+type PermissionCatalogOrchestrationInput struct {
+	Definitions []PermissionDefinition
+}
+type PermissionDefinition struct {
+	AdminPermission   string
+	ApprovalPolicy    ApprovalPolicy
+	ApproverAuthority string
+	Description       string
+	Name              string
+	RiskLevel         string
+}
+type PermissionDefinitionsResponse struct {
+	Definitions []PermissionDefinition
+}
+type PermissionExpiration struct {
+	ExpiresAt  time.Time
+	Permission string
+}
+type PermissionGrant struct {
+	ManagerID  string
+	Permission string
+	UserID     string
+}
+type PermissionRejection struct {
+	ApproverID string
+	Permission string
+	Reason     string
+	RejectedAt time.Time
 }
 type PermissionsGrantedResponse struct {
+	Delegations []Delegation
+	Direct      []string
+	Permissions []string
+	Roles       []RoleGrant
+	Status      string
+	Suspended   bool
+}
+type ReinstateUserAccountResponse struct{}
+type ReinstateUserAccountRequest struct {
+	ApproverID string
+}
+type RejectUserPermissionResponse struct{}
+type RejectUserPermissionRequest struct {
+	ApproverID string
+	Permission string
+	Reason     string
+}
+type RemoveRoleMemberResponse struct{}
+type RemoveRoleMemberRequest struct {
+	UserID string
+}
+type RemoveUserRoleResponse struct{}
+type RemoveUserRoleRequest struct {
+	ApproverID string
+	Role       string
+}
+type RevokeDelegationResponse struct{}
+type RevokeDelegationRequest struct {
+	DelegateID  string
+	RequestedBy string
+}
+type RevokeCampaignGrantResponse struct{}
+type RevokeCampaignGrantRequest struct {
+	CampaignID string
+	Permission string
+	Reason     string
+	ReviewerID string
+	UserID     string
+}
+type RevokeGrantResponse struct{}
+type RevokeGrantRequest struct {
+	Permission string
+	Reason     string
+	ReviewerID string
+	UserID     string
+}
+type RevokeUserPermissionResponse struct{}
+type RevokeUserPermissionRequest struct {
+	ApproverID string
+	CampaignID string
+	Permission string
+	Reason     string
+}
+type RoleDetailsResponse struct {
+	Description string
+	Members     []string
+	Name        string
 	Permissions []string
 }
+type RoleGrant struct {
+	Permissions []string
+	Role        string
+}
+type RoleOrchestrationInput struct {
+	Description string
+	Members     []string
+	Permissions []string
+}
+type RolePermissionsChangedSignal struct {
+	Permissions []string
+	Role        string
+}
+type SeparationOfDutiesRule struct {
+	ConflictsWith string
+	Name          string
+	Permission    string
+}
 type SendNotificationsRequest struct {
-	ApproverID     string
-	PermissionType string
-	RequesterID    string
+	ApproverID       string
+	ApproverPool     string
+	NotificationType string
+	PermissionType   string
+	RequesterID      string
 }
 type SendNotificationsResponse struct{}
+type SuspendUserAccountResponse struct{}
+type SuspendUserAccountRequest struct {
+	Reason      string
+	RequestedBy string
+}
+type Suspension struct {
+	Reason      string
+	RequestedBy string
+	Suspended   bool
+	SuspendedAt time.Time
+}
 type UndoDeleteUserAccountResponse struct{}
-type UndoDeleteUserAccountRequest struct{}
+type UndoDeleteUserAccountRequest struct {
+	RequestedBy string
+}
+type UpdateUserProfileResponse struct{}
+type UpdateUserProfileRequest struct {
+	Profile     UserProfile
+	RequestedBy string
+}
 type UserAccountOrchestrationInput struct {
-	AwaitingApproval    []string
-	Permissions         []string
-	DeletionRequestedAt time.Time
+	AuditLog              []AuditEntry
+	AuditLogCompacted     int
+	AwaitingApproval      []string
+	BreakGlass            []BreakGlassAccess
+	Delegations           []Delegation
+	PendingApprovals      []PendingApproval
+	PendingRoles          []PendingRoleAssignment
+	Permissions           []string
+	PermissionExpirations []PermissionExpiration
+	Rejections            []PermissionRejection
+	Roles                 []RoleGrant
+	SchemaVersion         int
+	DeletionRequested     bool
+	DeletionRequestedAt   time.Time
+	DeletionScheduledAt   time.Time
+	DeletionScheduledFor  time.Time
+	DeletionUndoWindow    time.Duration
+	Profile               UserProfile
+	Status                string
+	StatusBeforeDeletion  string
+	Suspension            Suspension
 }
 type UserDetailsResponse struct {
-	AwaitingApproval     AwaitingApprovalResponse
-	DeletionRequested    bool
-	DeletionRequestedAt  time.Time
-	DeletionScheduledFor time.Time
-	Permissions          PermissionsGrantedResponse
+	AwaitingApproval      AwaitingApprovalResponse
+	BreakGlass            []BreakGlassAccess
+	DeletionRequested     bool
+	DeletionRequestedAt   time.Time
+	DeletionScheduledAt   time.Time
+	DeletionScheduledFor  time.Time
+	DeletionUndoWindow    time.Duration
+	PendingApprovals      []PendingApproval
+	PendingRoles          []PendingRoleAssignment
+	PermissionExpirations []PermissionExpiration
+	Permissions           PermissionsGrantedResponse
+	Profile               UserProfile
+	Rejections            []PermissionRejection
+	Status                string
+	Suspension            Suspension
+}
+type UserProfile struct {
+	Department   string
+	DisplayName  string
+	Email        string
+	EmployeeType string
+	ManagerID    string
+}
+type ValidatePermissionRequest struct {
+	Permission string
+}
+type ValidatePermissionResponse struct {
+	Definition PermissionDefinition
+	Valid      bool
 }
 type VerifyApproverRequest struct {
+	AdminOnly  bool
 	ApproverID string
 	Permission string
 }
 type VerifyApproverResponse struct {
+	OnBehalfOf string
+	Verified   bool
+}
+type VerifyCampaignReviewerRequest struct {
+	CampaignID string
+	Permission string
+	ReviewerID string
+	UserID     string
+}
+type VerifyCampaignReviewerResponse struct {
 	Verified bool
 }
@@ -6,9 +6,27 @@ import (
 	"fmt"
 	"github.com/temporal-sa/temporal-entity-lifecycle-go/constants"
 	"github.com/temporal-sa/temporal-entity-lifecycle-go/messages"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/permission_catalog_state"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/role_state"
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/activity"
 	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+	"slices"
+	"strings"
+	"time"
 )
 
+// delegatorsQuery matches the live user entities that have delegated their approval authority to the quoted ID it is
+// formatted with.
+const delegatorsQuery = "`ExecutionStatus`=\"Running\" AND `WorkflowType`=\"Orchestration\" AND `delegates`=%q"
+
+// grantHoldersQuery matches the live user entities that hold any of the permissions in {PERMISSIONS}, a comma
+// separated list of quoted permission names.
+const grantHoldersQuery = "`ExecutionStatus`=\"Running\" AND `WorkflowType`=\"Orchestration\" AND `permissions` IN ({PERMISSIONS})"
+
 type Handler struct {
 	c client.Client
 }
@@ -20,12 +38,34 @@ func New(c client.Client) (*Handler, error) {
 	return &Handler{c: c}, nil
 }
 
+// VerifyApprover reports whether req.ApproverID may grant or take away req.Permission. Approvers need either the
+// approver authority or the admin permission the catalog defines for req.Permission, and must be active: users who are
+// suspended, not yet created or deleted cannot approve.
+// Approvers without authority of their own may act on behalf of a holder of the admin permission who has delegated it
+// to them, which is reported in OnBehalfOf. With req.AdminOnly the approver authority is not enough.
 func (h *Handler) VerifyApprover(ctx context.Context, req messages.VerifyApproverRequest) (messages.VerifyApproverResponse, error) {
 	if h.c == nil {
 		return messages.VerifyApproverResponse{Verified: false}, errors.New("handler misconfigured")
 	}
+	if req.Permission == "" {
+		return messages.VerifyApproverResponse{Verified: false}, errors.New("permission required and missing")
+	}
+	validated, err := h.ValidatePermission(ctx, messages.ValidatePermissionRequest{Permission: req.Permission})
+	if err != nil {
+		return messages.VerifyApproverResponse{Verified: false}, err
+	}
+	definition := validated.Definition
+	if !validated.Valid {
+		// Roles, and permissions since dropped from the catalog, are still approved by someone
+		definition = permission_catalog_state.FallbackDefinition(req.Permission)
+	}
 	ev, err := h.c.QueryWorkflow(ctx, req.ApproverID, "", constants.PermissionsGrantedQueryHandlerName)
 	if err != nil {
+		// Unknown approvers will not turn up on retry
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			return messages.VerifyApproverResponse{Verified: false}, nil
+		}
 		return messages.VerifyApproverResponse{Verified: false}, err
 	}
 	m := messages.PermissionsGrantedResponse{}
@@ -33,15 +73,290 @@ func (h *Handler) VerifyApprover(ctx context.Context, req messages.VerifyApprove
 	if err != nil {
 		return messages.VerifyApproverResponse{Verified: false}, err
 	}
+	if m.Suspended || m.Status != constants.UserStatusActive {
+		return messages.VerifyApproverResponse{Verified: false}, nil
+	}
 	for _, p := range m.Permissions {
-		if p == "grant_permissions" {
+		if p == definition.AdminPermission || (p == definition.ApproverAuthority && !req.AdminOnly) {
 			return messages.VerifyApproverResponse{Verified: true}, nil
 		}
 	}
+	delegatorID, err := h.findDelegator(ctx, req.ApproverID, definition.AdminPermission)
+	if err != nil {
+		return messages.VerifyApproverResponse{Verified: false}, err
+	}
+	if delegatorID != "" {
+		return messages.VerifyApproverResponse{OnBehalfOf: delegatorID, Verified: true}, nil
+	}
 	return messages.VerifyApproverResponse{Verified: false}, nil
 }
 
+// VerifyCampaignReviewer reports whether req.ReviewerID decided, in the recertification campaign req.CampaignID, to
+// revoke req.UserID's grant of req.Permission. Only campaigns that are still running are trusted, and a reviewer's
+// revocation must come from the reviewer the campaign assigned to the grant.
+func (h *Handler) VerifyCampaignReviewer(ctx context.Context, req messages.VerifyCampaignReviewerRequest) (messages.VerifyCampaignReviewerResponse, error) {
+	if h.c == nil {
+		return messages.VerifyCampaignReviewerResponse{Verified: false}, errors.New("handler misconfigured")
+	}
+	if !strings.HasPrefix(req.CampaignID, constants.CampaignWorkflowIDPrefix) {
+		return messages.VerifyCampaignReviewerResponse{Verified: false}, nil
+	}
+	queryResp, err := h.c.QueryWorkflowWithOptions(ctx, &client.QueryWorkflowWithOptionsRequest{
+		QueryRejectCondition: enumspb.QUERY_REJECT_CONDITION_NOT_OPEN,
+		QueryType:            constants.CampaignProgressQueryHandlerName,
+		WorkflowID:           req.CampaignID,
+	})
+	if err != nil {
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			return messages.VerifyCampaignReviewerResponse{Verified: false}, nil
+		}
+		return messages.VerifyCampaignReviewerResponse{Verified: false}, err
+	}
+	if queryResp.QueryRejected != nil {
+		return messages.VerifyCampaignReviewerResponse{Verified: false}, nil
+	}
+	summary := messages.CampaignSummary{}
+	err = queryResp.QueryResult.Get(&summary)
+	if err != nil {
+		return messages.VerifyCampaignReviewerResponse{Verified: false}, err
+	}
+	for _, item := range summary.Items {
+		if item.UserID != req.UserID || item.Permission != req.Permission || item.DecidedBy != req.ReviewerID {
+			continue
+		}
+		switch item.Decision {
+		case constants.CampaignDecisionAutoRevoked:
+			return messages.VerifyCampaignReviewerResponse{Verified: true}, nil
+		case constants.CampaignDecisionRevoked:
+			return messages.VerifyCampaignReviewerResponse{Verified: item.ReviewerID == req.ReviewerID}, nil
+		}
+	}
+	return messages.VerifyCampaignReviewerResponse{Verified: false}, nil
+}
+
+// ValidatePermission looks req.Permission up in the permission catalog entity and returns its definition when it is a
+// known permission type.
+func (h *Handler) ValidatePermission(ctx context.Context, req messages.ValidatePermissionRequest) (messages.ValidatePermissionResponse, error) {
+	if h.c == nil {
+		return messages.ValidatePermissionResponse{Valid: false}, errors.New("handler misconfigured")
+	}
+	ev, err := h.c.QueryWorkflow(ctx, constants.PermissionCatalogWorkflowID, "",
+		constants.PermissionDefinitionsQueryHandlerName)
+	if err != nil {
+		return messages.ValidatePermissionResponse{Valid: false}, err
+	}
+	m := messages.PermissionDefinitionsResponse{}
+	err = ev.Get(&m)
+	if err != nil {
+		return messages.ValidatePermissionResponse{Valid: false}, err
+	}
+	for _, d := range m.Definitions {
+		if d.Name == req.Permission {
+			return messages.ValidatePermissionResponse{Definition: d, Valid: true}, nil
+		}
+	}
+	return messages.ValidatePermissionResponse{Valid: false}, nil
+}
+
+// GetRole looks req.Role up among the role entities and returns its details when it exists.
+func (h *Handler) GetRole(ctx context.Context, req messages.GetRoleRequest) (messages.GetRoleResponse, error) {
+	if h.c == nil {
+		return messages.GetRoleResponse{Found: false}, errors.New("handler misconfigured")
+	}
+	ev, err := h.c.QueryWorkflow(ctx, role_state.WorkflowID(req.Role), "", constants.RoleDetailsQueryHandlerName)
+	if err != nil {
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			return messages.GetRoleResponse{Found: false}, nil
+		}
+		return messages.GetRoleResponse{Found: false}, err
+	}
+	m := messages.RoleDetailsResponse{}
+	err = ev.Get(&m)
+	if err != nil {
+		return messages.GetRoleResponse{Found: false}, err
+	}
+	return messages.GetRoleResponse{Found: true, Role: m}, nil
+}
+
+// JoinRole adds req.UserID to the members of req.Role and returns the permissions the user inherits from it.
+func (h *Handler) JoinRole(ctx context.Context, req messages.JoinRoleRequest) (messages.JoinRoleResponse, error) {
+	if h.c == nil {
+		return messages.JoinRoleResponse{}, errors.New("handler misconfigured")
+	}
+	resp := messages.AddRoleMemberResponse{}
+	err := h.updateRole(ctx, req.Role, constants.AddRoleMemberUpdateHandlerName,
+		messages.AddRoleMemberRequest{UserID: req.UserID}, &resp)
+	if err != nil {
+		return messages.JoinRoleResponse{}, err
+	}
+	return messages.JoinRoleResponse{Permissions: resp.Permissions}, nil
+}
+
+// LeaveRole removes req.UserID from the members of req.Role.
+func (h *Handler) LeaveRole(ctx context.Context, req messages.LeaveRoleRequest) (messages.LeaveRoleResponse, error) {
+	if h.c == nil {
+		return messages.LeaveRoleResponse{}, errors.New("handler misconfigured")
+	}
+	err := h.updateRole(ctx, req.Role, constants.RemoveRoleMemberUpdateHandlerName,
+		messages.RemoveRoleMemberRequest{UserID: req.UserID}, &messages.RemoveRoleMemberResponse{})
+	return messages.LeaveRoleResponse{}, err
+}
+
+// ListGrants returns every direct grant of req.Permissions to a live user, along with the user's manager. Permissions
+// inherited from a role are left out: they are reviewed with the role. Users that are not active or suspended are left
+// out as well since their grants can no longer be revoked.
+func (h *Handler) ListGrants(ctx context.Context, req messages.ListGrantsRequest) (messages.ListGrantsResponse, error) {
+	if h.c == nil {
+		return messages.ListGrantsResponse{}, errors.New("handler misconfigured")
+	}
+	quoted := make([]string, 0, len(req.Permissions))
+	for _, permission := range req.Permissions {
+		quoted = append(quoted, fmt.Sprintf("%q", permission))
+	}
+	query := strings.Replace(grantHoldersQuery, "{PERMISSIONS}", strings.Join(quoted, ","), 1)
+	grants := make([]messages.PermissionGrant, 0)
+	var nextPageToken []byte
+	for {
+		listResp, err := h.c.ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{
+			NextPageToken: nextPageToken,
+			Query:         query,
+		})
+		if err != nil {
+			return messages.ListGrantsResponse{}, err
+		}
+		for _, e := range listResp.GetExecutions() {
+			userID := e.GetExecution().GetWorkflowId()
+			ev, err := h.c.QueryWorkflow(ctx, userID, "", constants.UserDetailsQueryHandlerName)
+			if err != nil {
+				var notFound *serviceerror.NotFound
+				if errors.As(err, &notFound) {
+					continue
+				}
+				return messages.ListGrantsResponse{}, err
+			}
+			details := messages.UserDetailsResponse{}
+			err = ev.Get(&details)
+			if err != nil {
+				return messages.ListGrantsResponse{}, err
+			}
+			if details.Status != constants.UserStatusActive && details.Status != constants.UserStatusSuspended {
+				continue
+			}
+			for _, permission := range details.Permissions.Direct {
+				if slices.Contains(req.Permissions, permission) {
+					grants = append(grants, messages.PermissionGrant{
+						ManagerID:  details.Profile.ManagerID,
+						Permission: permission,
+						UserID:     userID,
+					})
+				}
+			}
+		}
+		nextPageToken = listResp.GetNextPageToken()
+		if len(nextPageToken) == 0 {
+			return messages.ListGrantsResponse{Grants: grants}, nil
+		}
+	}
+}
+
+// RevokeCampaignGrant takes req.Permission away from req.UserID as decided in recertification campaign req.CampaignID.
+func (h *Handler) RevokeCampaignGrant(ctx context.Context, req messages.RevokeCampaignGrantRequest) (messages.RevokeCampaignGrantResponse, error) {
+	if h.c == nil {
+		return messages.RevokeCampaignGrantResponse{}, errors.New("handler misconfigured")
+	}
+	err := h.updateEntity(ctx, req.UserID, fmt.Sprintf("user %s not found", req.UserID),
+		constants.RevokeUserPermissionUpdateHandlerName, messages.RevokeUserPermissionRequest{
+			ApproverID: req.ReviewerID,
+			CampaignID: req.CampaignID,
+			Permission: req.Permission,
+			Reason:     req.Reason,
+		}, &messages.RevokeUserPermissionResponse{})
+	return messages.RevokeCampaignGrantResponse{}, err
+}
+
 func (h *Handler) SendNotifications(ctx context.Context, req messages.SendNotificationsRequest) (messages.SendNotificationsResponse, error) {
-	fmt.Println("*** SENDING NOTIFICATIONS ***")
+	activity.GetLogger(ctx).Info("sending notification", "NotificationType", req.NotificationType,
+		"RequesterID", req.RequesterID, "PermissionType", req.PermissionType, "ApproverID", req.ApproverID,
+		"ApproverPool", req.ApproverPool)
 	return messages.SendNotificationsResponse{}, nil
 }
+
+// findDelegator returns a holder of adminPermission who has an active delegation of their approval authority to
+// delegateID, or an empty string if there is none. Candidates are found through the delegates search attribute, which
+// may lag, and then confirmed against the delegator's entity so that delegations that expired or were revoked since
+// are not honored. Candidates whose entity can no longer be found are skipped.
+func (h *Handler) findDelegator(ctx context.Context, delegateID string, adminPermission string) (string, error) {
+	var nextPageToken []byte
+	for {
+		listResp, err := h.c.ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{
+			NextPageToken: nextPageToken,
+			Query:         fmt.Sprintf(delegatorsQuery, delegateID),
+		})
+		if err != nil {
+			return "", err
+		}
+		for _, e := range listResp.GetExecutions() {
+			delegatorID := e.GetExecution().GetWorkflowId()
+			ev, err := h.c.QueryWorkflow(ctx, delegatorID, "", constants.PermissionsGrantedQueryHandlerName)
+			if err != nil {
+				var notFound *serviceerror.NotFound
+				if errors.As(err, &notFound) {
+					continue
+				}
+				return "", err
+			}
+			m := messages.PermissionsGrantedResponse{}
+			err = ev.Get(&m)
+			if err != nil {
+				return "", err
+			}
+			if m.Suspended || m.Status != constants.UserStatusActive || !slices.Contains(m.Permissions, adminPermission) {
+				continue
+			}
+			for _, d := range m.Delegations {
+				if d.DelegateID == delegateID && d.ExpiresAt.After(time.Now()) {
+					return delegatorID, nil
+				}
+			}
+		}
+		nextPageToken = listResp.GetNextPageToken()
+		if len(nextPageToken) == 0 {
+			return "", nil
+		}
+	}
+}
+
+// updateRole sends an update to the entity of role and waits for its result.
+func (h *Handler) updateRole(ctx context.Context, role string, updateName string, req interface{}, resp interface{}) error {
+	return h.updateEntity(ctx, role_state.WorkflowID(role), fmt.Sprintf("role %s not found", role), updateName, req,
+		resp)
+}
+
+// updateEntity sends an update to the entity run under workflowID and waits for its result. The update ID is derived
+// from the activity so that retries are deduplicated. Missing entities, reported as notFound, and refused updates will
+// not succeed on retry and are returned as non-retryable errors. Entities that are draining before continue-as-new
+// accept the update once they resume, so those errors are left retryable.
+func (h *Handler) updateEntity(ctx context.Context, workflowID string, notFound string, updateName string, req interface{}, resp interface{}) error {
+	info := activity.GetInfo(ctx)
+	handle, err := h.c.UpdateWorkflow(ctx, client.UpdateWorkflowOptions{
+		WorkflowID:   workflowID,
+		UpdateID:     fmt.Sprintf("%s/%s/%s", info.WorkflowExecution.ID, info.WorkflowExecution.RunID, info.ActivityID),
+		UpdateName:   updateName,
+		Args:         []interface{}{req},
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+	})
+	if err == nil {
+		err = handle.Get(ctx, resp)
+	}
+	var notFoundErr *serviceerror.NotFound
+	if errors.As(err, &notFoundErr) {
+		return temporal.NewNonRetryableApplicationError(notFound, constants.InvalidRequestErrorType, err)
+	}
+	var appErr *temporal.ApplicationError
+	if errors.As(err, &appErr) && appErr.Type() != constants.DrainingErrorType {
+		return temporal.NewNonRetryableApplicationError(appErr.Message(), appErr.Type(), err)
+	}
+	return err
+}
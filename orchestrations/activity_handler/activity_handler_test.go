@@ -0,0 +1,522 @@
+package activity_handler
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/constants"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/messages"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/permission_catalog_state"
+	commonpb "go.temporal.io/api/common/v1"
+	enumspb "go.temporal.io/api/enums/v1"
+	querypb "go.temporal.io/api/query/v1"
+	"go.temporal.io/api/serviceerror"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/mocks"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"strings"
+	"testing"
+	"time"
+)
+
+type ActivityTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	c       *mocks.Client
+	catalog []messages.PermissionDefinition
+	env     *testsuite.TestActivityEnvironment
+	h       *Handler
+}
+
+func (s *ActivityTestSuite) SetupTest() {
+	s.c = &mocks.Client{}
+	h, err := New(s.c)
+	s.Nil(err)
+	s.h = h
+	s.catalog = permission_catalog_state.DefaultDefinitions()
+	// Approvers are verified against the catalog; answer with whatever s.catalog holds when it is queried
+	catalog := &mocks.Value{}
+	catalog.On("Get", mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(0).(*messages.PermissionDefinitionsResponse) = messages.PermissionDefinitionsResponse{
+			Definitions: s.catalog,
+		}
+	}).Return(nil).Maybe()
+	s.c.On("QueryWorkflow", mock.Anything, constants.PermissionCatalogWorkflowID, "",
+		constants.PermissionDefinitionsQueryHandlerName).Return(catalog, nil).Maybe()
+	s.env = s.NewTestActivityEnvironment()
+	s.env.RegisterActivity(s.h.GetRole)
+	s.env.RegisterActivity(s.h.JoinRole)
+	s.env.RegisterActivity(s.h.VerifyApprover)
+	s.env.RegisterActivity(s.h.VerifyCampaignReviewer)
+}
+
+func (s *ActivityTestSuite) AfterTest(suiteName, testName string) {
+	s.c.AssertExpectations(s.T())
+}
+
+func TestActivityTestSuite(t *testing.T) {
+	suite.Run(t, new(ActivityTestSuite))
+}
+
+// givenApproverPermissions stubs the granted query of approverID's entity workflow.
+func (s *ActivityTestSuite) givenApproverPermissions(approverID string, permissions ...string) {
+	s.givenApproverGranted(approverID, messages.PermissionsGrantedResponse{
+		Permissions: permissions,
+		Status:      constants.UserStatusActive,
+	})
+}
+
+func (s *ActivityTestSuite) givenApproverGranted(approverID string, granted messages.PermissionsGrantedResponse) {
+	v := &mocks.Value{}
+	v.On("Get", mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(0).(*messages.PermissionsGrantedResponse) = granted
+	}).Return(nil)
+	s.c.On("QueryWorkflow", mock.Anything, approverID, "", constants.PermissionsGrantedQueryHandlerName).
+		Return(v, nil)
+}
+
+// givenDelegators stubs the search for users who have delegated their approval authority to delegateID.
+func (s *ActivityTestSuite) givenDelegators(delegateID string, delegatorIDs ...string) {
+	executions := make([]*workflowpb.WorkflowExecutionInfo, 0)
+	for _, id := range delegatorIDs {
+		executions = append(executions, &workflowpb.WorkflowExecutionInfo{
+			Execution: &commonpb.WorkflowExecution{WorkflowId: id},
+		})
+	}
+	s.c.On("ListWorkflow", mock.Anything, mock.MatchedBy(func(req *workflowservice.ListWorkflowExecutionsRequest) bool {
+		return strings.HasSuffix(req.GetQuery(), fmt.Sprintf("`delegates`=%q", delegateID)) &&
+			len(req.GetNextPageToken()) == 0
+	})).Return(&workflowservice.ListWorkflowExecutionsResponse{Executions: executions}, nil)
+}
+
+func (s *ActivityTestSuite) verify(req messages.VerifyApproverRequest) messages.VerifyApproverResponse {
+	v, err := s.env.ExecuteActivity(s.h.VerifyApprover, req)
+	s.Nil(err)
+	resp := messages.VerifyApproverResponse{}
+	s.Nil(v.Get(&resp))
+	return resp
+}
+
+func (s *ActivityTestSuite) Test_VerifyApprover_PermissionScopedAuthority() {
+	s.givenApproverPermissions("bobsaget@temporal.io", constants.PermissionTypeApproveReadFiles)
+	s.givenDelegators("bobsaget@temporal.io")
+	s.True(s.verify(messages.VerifyApproverRequest{
+		ApproverID: "bobsaget@temporal.io",
+		Permission: constants.PermissionTypeReadFiles,
+	}).Verified)
+	s.False(s.verify(messages.VerifyApproverRequest{
+		ApproverID: "bobsaget@temporal.io",
+		Permission: constants.PermissionTypeGrantPermissions,
+	}).Verified)
+}
+
+func (s *ActivityTestSuite) Test_VerifyApprover_AdminOnly() {
+	s.givenApproverPermissions("bobsaget@temporal.io", constants.PermissionTypeApproveReadFiles)
+	s.givenApproverPermissions("admin@temporal.io", constants.PermissionTypeGrantPermissions)
+	s.givenDelegators("bobsaget@temporal.io")
+	s.False(s.verify(messages.VerifyApproverRequest{
+		AdminOnly:  true,
+		ApproverID: "bobsaget@temporal.io",
+		Permission: constants.PermissionTypeReadFiles,
+	}).Verified)
+	s.True(s.verify(messages.VerifyApproverRequest{
+		AdminOnly:  true,
+		ApproverID: "admin@temporal.io",
+		Permission: constants.PermissionTypeReadFiles,
+	}).Verified)
+}
+
+func (s *ActivityTestSuite) Test_VerifyApprover_SuperPermission() {
+	s.givenApproverPermissions("bobsaget@temporal.io", constants.PermissionTypeGrantPermissions)
+	s.True(s.verify(messages.VerifyApproverRequest{
+		ApproverID: "bobsaget@temporal.io",
+		Permission: constants.PermissionTypeReadFiles,
+	}).Verified)
+}
+
+func (s *ActivityTestSuite) Test_VerifyApprover_CatalogAuthority() {
+	s.catalog = append(s.catalog, messages.PermissionDefinition{
+		AdminPermission:   "platform_admin",
+		ApprovalPolicy:    messages.ApprovalPolicy{RequiredApprovals: 1},
+		ApproverAuthority: "release_manager",
+		Name:              "deploy_production",
+		RiskLevel:         constants.RiskLevelHigh,
+	})
+	s.givenApproverPermissions("rm@temporal.io", "release_manager")
+	s.givenApproverPermissions("pa@temporal.io", "platform_admin")
+	s.givenApproverPermissions("bobsaget@temporal.io", constants.PermissionTypeGrantPermissions,
+		constants.ApproverAuthorityPrefix+"deploy_production")
+	s.givenDelegators("bobsaget@temporal.io")
+	s.True(s.verify(messages.VerifyApproverRequest{
+		ApproverID: "rm@temporal.io",
+		Permission: "deploy_production",
+	}).Verified)
+	s.True(s.verify(messages.VerifyApproverRequest{
+		ApproverID: "pa@temporal.io",
+		Permission: "deploy_production",
+	}).Verified)
+	s.False(s.verify(messages.VerifyApproverRequest{
+		ApproverID: "bobsaget@temporal.io",
+		Permission: "deploy_production",
+	}).Verified)
+}
+
+func (s *ActivityTestSuite) Test_VerifyApprover_Role() {
+	s.givenApproverPermissions("bobsaget@temporal.io", constants.ApproverAuthorityPrefix+constants.RoleWorkflowIDPrefix+"ops")
+	s.True(s.verify(messages.VerifyApproverRequest{
+		ApproverID: "bobsaget@temporal.io",
+		Permission: constants.RoleWorkflowIDPrefix + "ops",
+	}).Verified)
+}
+
+func (s *ActivityTestSuite) Test_VerifyApprover_UnrelatedPermission() {
+	s.givenApproverPermissions("bobsaget@temporal.io", constants.PermissionTypeReadFiles)
+	s.givenDelegators("bobsaget@temporal.io")
+	s.False(s.verify(messages.VerifyApproverRequest{
+		ApproverID: "bobsaget@temporal.io",
+		Permission: constants.PermissionTypeReadFiles,
+	}).Verified)
+}
+
+func (s *ActivityTestSuite) Test_VerifyApprover_SuspendedApprover() {
+	s.givenApproverGranted("bobsaget@temporal.io", messages.PermissionsGrantedResponse{
+		Permissions: []string{constants.PermissionTypeGrantPermissions},
+		Status:      constants.UserStatusActive,
+		Suspended:   true,
+	})
+	s.False(s.verify(messages.VerifyApproverRequest{
+		ApproverID: "bobsaget@temporal.io",
+		Permission: constants.PermissionTypeReadFiles,
+	}).Verified)
+}
+
+func (s *ActivityTestSuite) Test_VerifyApprover_DeletedApprover() {
+	s.givenApproverGranted("bobsaget@temporal.io", messages.PermissionsGrantedResponse{
+		Permissions: []string{constants.PermissionTypeGrantPermissions},
+		Status:      constants.UserStatusDeleted,
+	})
+	s.False(s.verify(messages.VerifyApproverRequest{
+		ApproverID: "bobsaget@temporal.io",
+		Permission: constants.PermissionTypeReadFiles,
+	}).Verified)
+}
+
+func (s *ActivityTestSuite) Test_VerifyApprover_PendingApprover() {
+	s.givenApproverGranted("bobsaget@temporal.io", messages.PermissionsGrantedResponse{
+		Permissions: []string{constants.PermissionTypeGrantPermissions},
+		Status:      constants.UserStatusPending,
+	})
+	s.False(s.verify(messages.VerifyApproverRequest{
+		ApproverID: "bobsaget@temporal.io",
+		Permission: constants.PermissionTypeReadFiles,
+	}).Verified)
+}
+
+func (s *ActivityTestSuite) Test_VerifyApprover_UnknownApprover() {
+	s.c.On("QueryWorkflow", mock.Anything, "nobody@temporal.io", "", constants.PermissionsGrantedQueryHandlerName).
+		Return(nil, serviceerror.NewNotFound("workflow not found"))
+	s.False(s.verify(messages.VerifyApproverRequest{
+		ApproverID: "nobody@temporal.io",
+		Permission: constants.PermissionTypeReadFiles,
+	}).Verified)
+}
+
+func (s *ActivityTestSuite) Test_VerifyApprover_Delegated() {
+	s.givenApproverPermissions("bobsaget@temporal.io", constants.PermissionTypeReadFiles)
+	s.givenDelegators("bobsaget@temporal.io", "expired@temporal.io", "admin@temporal.io")
+	s.givenApproverGranted("expired@temporal.io", messages.PermissionsGrantedResponse{
+		Delegations: []messages.Delegation{{
+			DelegateID: "bobsaget@temporal.io",
+			ExpiresAt:  time.Now().Add(-time.Minute),
+		}},
+		Permissions: []string{constants.PermissionTypeGrantPermissions},
+		Status:      constants.UserStatusActive,
+	})
+	s.givenApproverGranted("admin@temporal.io", messages.PermissionsGrantedResponse{
+		Delegations: []messages.Delegation{{
+			DelegateID: "bobsaget@temporal.io",
+			ExpiresAt:  time.Now().Add(time.Hour),
+		}},
+		Permissions: []string{constants.PermissionTypeGrantPermissions},
+		Status:      constants.UserStatusActive,
+	})
+	s.Equal(messages.VerifyApproverResponse{OnBehalfOf: "admin@temporal.io", Verified: true},
+		s.verify(messages.VerifyApproverRequest{
+			ApproverID: "bobsaget@temporal.io",
+			Permission: constants.PermissionTypeReadFiles,
+		}))
+}
+
+func (s *ActivityTestSuite) Test_VerifyApprover_DelegatedOnLaterPage() {
+	s.givenApproverPermissions("bobsaget@temporal.io")
+	s.c.On("ListWorkflow", mock.Anything, mock.MatchedBy(func(req *workflowservice.ListWorkflowExecutionsRequest) bool {
+		return len(req.GetNextPageToken()) == 0
+	})).Return(&workflowservice.ListWorkflowExecutionsResponse{NextPageToken: []byte("page2")}, nil).Once()
+	s.c.On("ListWorkflow", mock.Anything, mock.MatchedBy(func(req *workflowservice.ListWorkflowExecutionsRequest) bool {
+		return string(req.GetNextPageToken()) == "page2"
+	})).Return(&workflowservice.ListWorkflowExecutionsResponse{
+		Executions: []*workflowpb.WorkflowExecutionInfo{{
+			Execution: &commonpb.WorkflowExecution{WorkflowId: "admin@temporal.io"},
+		}},
+	}, nil).Once()
+	s.givenApproverGranted("admin@temporal.io", messages.PermissionsGrantedResponse{
+		Delegations: []messages.Delegation{{
+			DelegateID: "bobsaget@temporal.io",
+			ExpiresAt:  time.Now().Add(time.Hour),
+		}},
+		Permissions: []string{constants.PermissionTypeGrantPermissions},
+		Status:      constants.UserStatusActive,
+	})
+	s.Equal("admin@temporal.io", s.verify(messages.VerifyApproverRequest{
+		ApproverID: "bobsaget@temporal.io",
+		Permission: constants.PermissionTypeReadFiles,
+	}).OnBehalfOf)
+}
+
+func (s *ActivityTestSuite) Test_VerifyApprover_DelegateIDQuoted() {
+	crafted := "nobody@temporal.io\" OR `delegates` IS NOT NULL OR `delegates`=\""
+	s.givenApproverPermissions(crafted)
+	s.c.On("ListWorkflow", mock.Anything, mock.MatchedBy(func(req *workflowservice.ListWorkflowExecutionsRequest) bool {
+		return strings.HasSuffix(req.GetQuery(),
+			"`delegates`=\"nobody@temporal.io\\\" OR `delegates` IS NOT NULL OR `delegates`=\\\"\"")
+	})).Return(&workflowservice.ListWorkflowExecutionsResponse{}, nil)
+	s.False(s.verify(messages.VerifyApproverRequest{
+		ApproverID: crafted,
+		Permission: constants.PermissionTypeReadFiles,
+	}).Verified)
+}
+
+func (s *ActivityTestSuite) Test_VerifyApprover_DelegatorSuspended() {
+	s.givenApproverPermissions("bobsaget@temporal.io")
+	s.givenDelegators("bobsaget@temporal.io", "admin@temporal.io")
+	s.givenApproverGranted("admin@temporal.io", messages.PermissionsGrantedResponse{
+		Delegations: []messages.Delegation{{
+			DelegateID: "bobsaget@temporal.io",
+			ExpiresAt:  time.Now().Add(time.Hour),
+		}},
+		Permissions: []string{constants.PermissionTypeGrantPermissions},
+		Status:      constants.UserStatusActive,
+		Suspended:   true,
+	})
+	s.False(s.verify(messages.VerifyApproverRequest{
+		ApproverID: "bobsaget@temporal.io",
+		Permission: constants.PermissionTypeReadFiles,
+	}).Verified)
+}
+
+func (s *ActivityTestSuite) Test_VerifyApprover_DelegatorDeleted() {
+	s.givenApproverPermissions("bobsaget@temporal.io")
+	s.givenDelegators("bobsaget@temporal.io", "admin@temporal.io")
+	s.givenApproverGranted("admin@temporal.io", messages.PermissionsGrantedResponse{
+		Delegations: []messages.Delegation{{
+			DelegateID: "bobsaget@temporal.io",
+			ExpiresAt:  time.Now().Add(time.Hour),
+		}},
+		Permissions: []string{constants.PermissionTypeGrantPermissions},
+		Status:      constants.UserStatusDeleted,
+	})
+	s.False(s.verify(messages.VerifyApproverRequest{
+		ApproverID: "bobsaget@temporal.io",
+		Permission: constants.PermissionTypeReadFiles,
+	}).Verified)
+}
+
+func (s *ActivityTestSuite) Test_VerifyApprover_DelegatorNotFound() {
+	s.givenApproverPermissions("bobsaget@temporal.io")
+	s.givenDelegators("bobsaget@temporal.io", "gone@temporal.io", "admin@temporal.io")
+	s.c.On("QueryWorkflow", mock.Anything, "gone@temporal.io", "", constants.PermissionsGrantedQueryHandlerName).
+		Return(nil, serviceerror.NewNotFound("workflow not found"))
+	s.givenApproverGranted("admin@temporal.io", messages.PermissionsGrantedResponse{
+		Delegations: []messages.Delegation{{
+			DelegateID: "bobsaget@temporal.io",
+			ExpiresAt:  time.Now().Add(time.Hour),
+		}},
+		Permissions: []string{constants.PermissionTypeGrantPermissions},
+		Status:      constants.UserStatusActive,
+	})
+	s.Equal("admin@temporal.io", s.verify(messages.VerifyApproverRequest{
+		ApproverID: "bobsaget@temporal.io",
+		Permission: constants.PermissionTypeReadFiles,
+	}).OnBehalfOf)
+}
+
+func (s *ActivityTestSuite) Test_GetRole_NotFound() {
+	s.c.On("QueryWorkflow", mock.Anything, "role:ghosts", "", constants.RoleDetailsQueryHandlerName).
+		Return(nil, serviceerror.NewNotFound("workflow not found"))
+	v, err := s.env.ExecuteActivity(s.h.GetRole, messages.GetRoleRequest{Role: "ghosts"})
+	s.Nil(err)
+	resp := messages.GetRoleResponse{}
+	s.Nil(v.Get(&resp))
+	s.False(resp.Found)
+}
+
+func (s *ActivityTestSuite) Test_JoinRole_Draining() {
+	s.c.On("UpdateWorkflow", mock.Anything, mock.MatchedBy(func(opts client.UpdateWorkflowOptions) bool {
+		return opts.WorkflowID == "role:auditors"
+	})).Return(nil, temporal.NewApplicationError("entity is finishing in-flight updates, retry shortly",
+		constants.DrainingErrorType)).Once()
+	_, err := s.env.ExecuteActivity(s.h.JoinRole, messages.JoinRoleRequest{Role: "auditors", UserID: "a@temporal.io"})
+	var appErr *temporal.ApplicationError
+	s.True(errors.As(err, &appErr))
+	s.Equal(constants.DrainingErrorType, appErr.Type())
+	s.False(appErr.NonRetryable())
+}
+
+func (s *ActivityTestSuite) Test_JoinRole_Refused() {
+	s.c.On("UpdateWorkflow", mock.Anything, mock.MatchedBy(func(opts client.UpdateWorkflowOptions) bool {
+		return opts.WorkflowID == "role:auditors"
+	})).Return(nil, temporal.NewApplicationError("user required and missing",
+		constants.InvalidRequestErrorType)).Once()
+	_, err := s.env.ExecuteActivity(s.h.JoinRole, messages.JoinRoleRequest{Role: "auditors"})
+	var appErr *temporal.ApplicationError
+	s.True(errors.As(err, &appErr))
+	s.True(appErr.NonRetryable())
+}
+
+func (s *ActivityTestSuite) Test_ListGrants() {
+	s.env.RegisterActivity(s.h.ListGrants)
+	s.c.On("ListWorkflow", mock.Anything, mock.MatchedBy(func(req *workflowservice.ListWorkflowExecutionsRequest) bool {
+		return strings.Contains(req.GetQuery(), "`permissions` IN (\"read_files\")") && len(req.GetNextPageToken()) == 0
+	})).Return(&workflowservice.ListWorkflowExecutionsResponse{
+		Executions: []*workflowpb.WorkflowExecutionInfo{
+			{Execution: &commonpb.WorkflowExecution{WorkflowId: "a@temporal.io"}},
+			{Execution: &commonpb.WorkflowExecution{WorkflowId: "b@temporal.io"}},
+		},
+		NextPageToken: []byte("2"),
+	}, nil).Once()
+	s.c.On("ListWorkflow", mock.Anything, mock.MatchedBy(func(req *workflowservice.ListWorkflowExecutionsRequest) bool {
+		return string(req.GetNextPageToken()) == "2"
+	})).Return(&workflowservice.ListWorkflowExecutionsResponse{
+		Executions: []*workflowpb.WorkflowExecutionInfo{
+			{Execution: &commonpb.WorkflowExecution{WorkflowId: "c@temporal.io"}},
+		},
+	}, nil).Once()
+	for userID, details := range map[string]messages.UserDetailsResponse{
+		"a@temporal.io": {
+			Permissions: messages.PermissionsGrantedResponse{Direct: []string{constants.PermissionTypeReadFiles}},
+			Profile:     messages.UserProfile{ManagerID: "m@temporal.io"},
+			Status:      constants.UserStatusActive,
+		},
+		// Inherits read_files from a role
+		"b@temporal.io": {
+			Permissions: messages.PermissionsGrantedResponse{Direct: []string{}},
+			Status:      constants.UserStatusActive,
+		},
+		"c@temporal.io": {
+			Permissions: messages.PermissionsGrantedResponse{Direct: []string{constants.PermissionTypeReadFiles}},
+			Status:      constants.UserStatusPendingDeletion,
+		},
+	} {
+		v := &mocks.Value{}
+		v.On("Get", mock.Anything).Run(func(args mock.Arguments) {
+			*args.Get(0).(*messages.UserDetailsResponse) = details
+		}).Return(nil)
+		s.c.On("QueryWorkflow", mock.Anything, userID, "", constants.UserDetailsQueryHandlerName).Return(v, nil)
+	}
+	v, err := s.env.ExecuteActivity(s.h.ListGrants, messages.ListGrantsRequest{
+		Permissions: []string{constants.PermissionTypeReadFiles},
+	})
+	s.Nil(err)
+	resp := messages.ListGrantsResponse{}
+	s.Nil(v.Get(&resp))
+	s.Equal([]messages.PermissionGrant{{
+		ManagerID:  "m@temporal.io",
+		Permission: constants.PermissionTypeReadFiles,
+		UserID:     "a@temporal.io",
+	}}, resp.Grants)
+}
+
+// givenCampaign stubs the progress query of the running campaign run under campaignID.
+func (s *ActivityTestSuite) givenCampaign(campaignID string, items ...messages.CampaignItem) {
+	v := &mocks.Value{}
+	v.On("Get", mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(0).(*messages.CampaignSummary) = messages.CampaignSummary{Items: items}
+	}).Return(nil)
+	s.c.On("QueryWorkflowWithOptions", mock.Anything, mock.MatchedBy(func(req *client.QueryWorkflowWithOptionsRequest) bool {
+		return req.WorkflowID == campaignID && req.QueryType == constants.CampaignProgressQueryHandlerName &&
+			req.QueryRejectCondition == enumspb.QUERY_REJECT_CONDITION_NOT_OPEN
+	})).Return(&client.QueryWorkflowWithOptionsResponse{QueryResult: v}, nil)
+}
+
+func (s *ActivityTestSuite) verifyCampaignReviewer(req messages.VerifyCampaignReviewerRequest) bool {
+	v, err := s.env.ExecuteActivity(s.h.VerifyCampaignReviewer, req)
+	s.Nil(err)
+	resp := messages.VerifyCampaignReviewerResponse{}
+	s.Nil(v.Get(&resp))
+	return resp.Verified
+}
+
+func (s *ActivityTestSuite) Test_VerifyCampaignReviewer() {
+	s.givenCampaign("campaign:q3",
+		messages.CampaignItem{
+			DecidedBy:  "m@temporal.io",
+			Decision:   constants.CampaignDecisionRevoked,
+			Permission: constants.PermissionTypeReadFiles,
+			ReviewerID: "m@temporal.io",
+			UserID:     "a@temporal.io",
+		},
+		messages.CampaignItem{
+			DecidedBy:  "m@temporal.io",
+			Decision:   constants.CampaignDecisionCertified,
+			Permission: constants.PermissionTypeReadFiles,
+			ReviewerID: "m@temporal.io",
+			UserID:     "b@temporal.io",
+		},
+		messages.CampaignItem{
+			DecidedBy:  constants.SystemActorID,
+			Decision:   constants.CampaignDecisionAutoRevoked,
+			Permission: constants.PermissionTypeReadFiles,
+			ReviewerID: "m@temporal.io",
+			UserID:     "c@temporal.io",
+		})
+	revoke := func(reviewerID string, userID string) messages.VerifyCampaignReviewerRequest {
+		return messages.VerifyCampaignReviewerRequest{
+			CampaignID: "campaign:q3",
+			Permission: constants.PermissionTypeReadFiles,
+			ReviewerID: reviewerID,
+			UserID:     userID,
+		}
+	}
+	s.True(s.verifyCampaignReviewer(revoke("m@temporal.io", "a@temporal.io")))
+	s.True(s.verifyCampaignReviewer(revoke(constants.SystemActorID, "c@temporal.io")))
+	s.False(s.verifyCampaignReviewer(revoke("mallory@temporal.io", "a@temporal.io")))
+	s.False(s.verifyCampaignReviewer(revoke("m@temporal.io", "b@temporal.io")))
+	s.False(s.verifyCampaignReviewer(revoke("m@temporal.io", "c@temporal.io")))
+	s.False(s.verifyCampaignReviewer(revoke("m@temporal.io", "z@temporal.io")))
+}
+
+func (s *ActivityTestSuite) Test_VerifyCampaignReviewer_UnknownCampaign() {
+	s.c.On("QueryWorkflowWithOptions", mock.Anything, mock.MatchedBy(func(req *client.QueryWorkflowWithOptionsRequest) bool {
+		return req.WorkflowID == "campaign:made-up"
+	})).Return(nil, serviceerror.NewNotFound("workflow not found"))
+	s.False(s.verifyCampaignReviewer(messages.VerifyCampaignReviewerRequest{
+		CampaignID: "campaign:made-up",
+		Permission: constants.PermissionTypeReadFiles,
+		ReviewerID: "m@temporal.io",
+		UserID:     "a@temporal.io",
+	}))
+	// Other entities are never consulted as campaigns
+	s.False(s.verifyCampaignReviewer(messages.VerifyCampaignReviewerRequest{
+		CampaignID: "m@temporal.io",
+		Permission: constants.PermissionTypeReadFiles,
+		ReviewerID: "m@temporal.io",
+		UserID:     "a@temporal.io",
+	}))
+}
+
+func (s *ActivityTestSuite) Test_VerifyCampaignReviewer_ClosedCampaign() {
+	s.c.On("QueryWorkflowWithOptions", mock.Anything, mock.Anything).Return(&client.QueryWorkflowWithOptionsResponse{
+		QueryRejected: &querypb.QueryRejected{Status: enumspb.WORKFLOW_EXECUTION_STATUS_COMPLETED},
+	}, nil)
+	s.False(s.verifyCampaignReviewer(messages.VerifyCampaignReviewerRequest{
+		CampaignID: "campaign:q3",
+		Permission: constants.PermissionTypeReadFiles,
+		ReviewerID: "m@temporal.io",
+		UserID:     "a@temporal.io",
+	}))
+}
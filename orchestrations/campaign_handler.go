@@ -0,0 +1,87 @@
+package orchestrations
+
+import (
+	"errors"
+	"fmt"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/campaign_state"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/constants"
+	msgs "github.com/temporal-sa/temporal-entity-lifecycle-go/messages"
+	wf "go.temporal.io/sdk/workflow"
+)
+
+type CampaignOrchestrationHandler struct{}
+
+func NewCampaign() (*CampaignOrchestrationHandler, error) {
+	return &CampaignOrchestrationHandler{}, nil
+}
+
+// CampaignOrchestration runs an access recertification campaign under campaign_state.WorkflowID(name). Reviewers
+// certify or revoke every direct grant of in.Permissions until in.Deadline, when whatever is left undecided is revoked.
+// The campaign returns its summary.
+func (h *CampaignOrchestrationHandler) CampaignOrchestration(ctx wf.Context, in msgs.CampaignOrchestrationInput) (msgs.CampaignSummary, error) {
+	state, err := campaign_state.New(ctx, campaign_state.WithSnapshot(in))
+	if err != nil {
+		return msgs.CampaignSummary{}, errors.Join(errors.New("unable to initialize campaign_state"), err)
+	}
+	err = wf.SetUpdateHandlerWithOptions(ctx, constants.CertifyGrantUpdateHandlerName,
+		func(inner wf.Context, req msgs.CertifyGrantRequest) (msgs.CertifyGrantResponse, error) {
+			return msgs.CertifyGrantResponse{}, state.Certify(req)
+		},
+		wf.UpdateHandlerOptions{
+			Validator: func(ctx wf.Context, req msgs.CertifyGrantRequest) error {
+				return invalidRequest(state.ValidateCertify(req))
+			},
+		})
+	if err != nil {
+		return msgs.CampaignSummary{}, errors.Join(errors.New(
+			fmt.Sprintf("unable to set %s UpdateHandler", constants.CertifyGrantUpdateHandlerName)), err)
+	}
+	err = wf.SetUpdateHandlerWithOptions(ctx, constants.RevokeGrantUpdateHandlerName,
+		func(inner wf.Context, req msgs.RevokeGrantRequest) (msgs.RevokeGrantResponse, error) {
+			return msgs.RevokeGrantResponse{}, state.Revoke(inner, req)
+		},
+		wf.UpdateHandlerOptions{
+			Validator: func(ctx wf.Context, req msgs.RevokeGrantRequest) error {
+				return invalidRequest(state.ValidateRevoke(req))
+			},
+		})
+	if err != nil {
+		return msgs.CampaignSummary{}, errors.Join(errors.New(
+			fmt.Sprintf("unable to set %s UpdateHandler", constants.RevokeGrantUpdateHandlerName)), err)
+	}
+	err = wf.SetQueryHandler(ctx, constants.CampaignProgressQueryHandlerName,
+		func() (msgs.CampaignSummary, error) {
+			return state.Summary(), nil
+		})
+	if err != nil {
+		return msgs.CampaignSummary{}, errors.Join(errors.New(
+			fmt.Sprintf("unable to set %s QueryHandler", constants.CampaignProgressQueryHandlerName)), err)
+	}
+	if !in.Enumerated {
+		err = state.Validate()
+		if err != nil {
+			return msgs.CampaignSummary{}, invalidRequest(err)
+		}
+		err = state.Enumerate(ctx)
+		if err != nil {
+			return msgs.CampaignSummary{}, errors.Join(errors.New("unable to enumerate grants"), err)
+		}
+	}
+	// A campaign resumed after continue-as-new may already be past its deadline
+	decided, err := wf.AwaitWithTimeout(ctx, max(state.Deadline().Sub(wf.Now(ctx)), 0), func() bool {
+		return state.Pending() == 0 || wf.GetInfo(ctx).GetContinueAsNewSuggested()
+	})
+	if err != nil {
+		return msgs.CampaignSummary{}, errors.Join(errors.New("wait cancelled"), err)
+	}
+	// Revocations still being applied must finish before the campaign moves on
+	err = wf.Await(ctx, func() bool { return wf.AllHandlersFinished(ctx) })
+	if err != nil {
+		return msgs.CampaignSummary{}, errors.Join(errors.New("wait cancelled"), err)
+	}
+	if decided && state.Pending() > 0 {
+		return msgs.CampaignSummary{}, wf.NewContinueAsNewError(ctx, h.CampaignOrchestration, state.Snapshot())
+	}
+	state.Close(ctx)
+	return state.Summary(), nil
+}
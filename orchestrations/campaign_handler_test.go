@@ -0,0 +1,176 @@
+package orchestrations
+
+import (
+	"github.com/stretchr/testify/mock"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/constants"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/messages"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/orchestrations/activity_handler"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+	"time"
+)
+
+func (s *UnitTestSuite) Test_CampaignOrchestration_CertifyRevokeAndAutoRevoke() {
+	h, err := NewCampaign()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	s.env.SetStartWorkflowOptions(client.StartWorkflowOptions{ID: "campaign:q3"})
+	s.env.OnActivity(new(activity_handler.Handler).ListGrants, mock.Anything, messages.ListGrantsRequest{
+		Permissions: []string{constants.PermissionTypeReadFiles},
+	}).Return(messages.ListGrantsResponse{Grants: []messages.PermissionGrant{
+		{ManagerID: "m@temporal.io", Permission: constants.PermissionTypeReadFiles, UserID: "a@temporal.io"},
+		{ManagerID: "m@temporal.io", Permission: constants.PermissionTypeReadFiles, UserID: "b@temporal.io"},
+		{Permission: constants.PermissionTypeReadFiles, UserID: "c@temporal.io"},
+	}}, nil).Once()
+	for _, notification := range []messages.SendNotificationsRequest{
+		{ApproverID: "m@temporal.io", NotificationType: constants.NotificationTypeRecertificationReview},
+		{ApproverID: "ciso@temporal.io", NotificationType: constants.NotificationTypeRecertificationReview},
+		{ApproverID: "ciso@temporal.io", NotificationType: constants.NotificationTypeRecertificationReport},
+	} {
+		notification.PermissionType = constants.PermissionTypeReadFiles
+		notification.RequesterID = "ciso@temporal.io"
+		s.env.OnActivity(new(activity_handler.Handler).SendNotifications, mock.Anything, notification).Return(
+			messages.SendNotificationsResponse{}, nil).Once()
+	}
+	s.env.OnActivity(new(activity_handler.Handler).RevokeCampaignGrant, mock.Anything,
+		messages.RevokeCampaignGrantRequest{
+			CampaignID: "campaign:q3",
+			Permission: constants.PermissionTypeReadFiles,
+			Reason:     "moved teams",
+			ReviewerID: "m@temporal.io",
+			UserID:     "b@temporal.io",
+		}).Return(messages.RevokeCampaignGrantResponse{}, nil).Once()
+	s.env.OnActivity(new(activity_handler.Handler).RevokeCampaignGrant, mock.Anything,
+		messages.RevokeCampaignGrantRequest{
+			CampaignID: "campaign:q3",
+			Permission: constants.PermissionTypeReadFiles,
+			Reason:     constants.CertificationDeadlineExceededReason,
+			ReviewerID: constants.SystemActorID,
+			UserID:     "c@temporal.io",
+		}).Return(messages.RevokeCampaignGrantResponse{},
+		temporal.NewNonRetryableApplicationError("permission not found", constants.InvalidRequestErrorType, nil)).Once()
+	certify := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.CertifyGrantUpdateHandlerName, "1", certify, messages.CertifyGrantRequest{
+			Permission: constants.PermissionTypeReadFiles,
+			ReviewerID: "m@temporal.io",
+			UserID:     "a@temporal.io",
+		})
+	}, time.Second*1)
+	notReviewer := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.CertifyGrantUpdateHandlerName, "2", notReviewer, messages.CertifyGrantRequest{
+			Permission: constants.PermissionTypeReadFiles,
+			ReviewerID: "mallory@temporal.io",
+			UserID:     "b@temporal.io",
+		})
+	}, time.Second*2)
+	revoke := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.RevokeGrantUpdateHandlerName, "3", revoke, messages.RevokeGrantRequest{
+			Permission: constants.PermissionTypeReadFiles,
+			Reason:     "moved teams",
+			ReviewerID: "m@temporal.io",
+			UserID:     "b@temporal.io",
+		})
+	}, time.Second*3)
+	again := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.CertifyGrantUpdateHandlerName, "4", again, messages.CertifyGrantRequest{
+			Permission: constants.PermissionTypeReadFiles,
+			ReviewerID: "m@temporal.io",
+			UserID:     "b@temporal.io",
+		})
+	}, time.Second*4)
+	progress := messages.CampaignSummary{}
+	s.env.RegisterDelayedCallback(func() {
+		v, err := s.env.QueryWorkflow(constants.CampaignProgressQueryHandlerName)
+		s.Nil(err)
+		s.Nil(v.Get(&progress))
+	}, time.Second*5)
+	s.env.ExecuteWorkflow(h.CampaignOrchestration, messages.CampaignOrchestrationInput{
+		Deadline:    s.env.Now().Add(time.Hour * 24 * 14),
+		Permissions: []string{constants.PermissionTypeReadFiles},
+		RequestedBy: "ciso@temporal.io",
+	})
+	s.True(s.env.IsWorkflowCompleted())
+	s.Nil(s.env.GetWorkflowError())
+	s.Nil(certify.Error())
+	s.True(notReviewer.Rejected())
+	s.Equal("mallory@temporal.io is not the reviewer of b@temporal.io's grant of read_files",
+		errorMessage(notReviewer.Error()))
+	s.Nil(revoke.Error())
+	s.True(again.Rejected())
+	s.Equal("b@temporal.io's grant of read_files already revoked by m@temporal.io", errorMessage(again.Error()))
+	s.Equal(1, progress.Pending)
+	s.True(progress.ClosedAt.IsZero())
+
+	summary := messages.CampaignSummary{}
+	s.Nil(s.env.GetWorkflowResult(&summary))
+	s.Equal("q3", summary.Name)
+	s.False(summary.ClosedAt.IsZero())
+	s.Equal(3, summary.Total)
+	s.Equal(1, summary.Certified)
+	s.Equal(1, summary.Revoked)
+	s.Equal(1, summary.AutoRevoked)
+	s.Equal(1, summary.Failed)
+	s.Equal(0, summary.Pending)
+	s.Equal("ciso@temporal.io", summary.Items[2].ReviewerID)
+	s.Equal("permission not found", summary.Items[2].Error)
+}
+
+func (s *UnitTestSuite) Test_CampaignOrchestration_ResumedPastDeadline() {
+	h, err := NewCampaign()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	s.env.SetStartWorkflowOptions(client.StartWorkflowOptions{ID: "campaign:q3"})
+	s.env.OnActivity(new(activity_handler.Handler).RevokeCampaignGrant, mock.Anything,
+		messages.RevokeCampaignGrantRequest{
+			CampaignID: "campaign:q3",
+			Permission: constants.PermissionTypeReadFiles,
+			Reason:     constants.CertificationDeadlineExceededReason,
+			ReviewerID: constants.SystemActorID,
+			UserID:     "b@temporal.io",
+		}).Return(messages.RevokeCampaignGrantResponse{}, nil).Once()
+	s.env.OnActivity(new(activity_handler.Handler).SendNotifications, mock.Anything, messages.SendNotificationsRequest{
+		ApproverID:       "ciso@temporal.io",
+		NotificationType: constants.NotificationTypeRecertificationReport,
+		PermissionType:   constants.PermissionTypeReadFiles,
+		RequesterID:      "ciso@temporal.io",
+	}).Return(messages.SendNotificationsResponse{}, nil).Once()
+	s.env.ExecuteWorkflow(h.CampaignOrchestration, messages.CampaignOrchestrationInput{
+		Deadline:   s.env.Now().Add(-time.Hour),
+		Enumerated: true,
+		Items: []messages.CampaignItem{
+			{
+				Decision:   constants.CampaignDecisionCertified,
+				DecidedBy:  "m@temporal.io",
+				Permission: constants.PermissionTypeReadFiles,
+				ReviewerID: "m@temporal.io",
+				UserID:     "a@temporal.io",
+			},
+			{Permission: constants.PermissionTypeReadFiles, ReviewerID: "m@temporal.io", UserID: "b@temporal.io"},
+		},
+		Permissions: []string{constants.PermissionTypeReadFiles},
+		RequestedBy: "ciso@temporal.io",
+	})
+	s.True(s.env.IsWorkflowCompleted())
+	s.Nil(s.env.GetWorkflowError())
+	summary := messages.CampaignSummary{}
+	s.Nil(s.env.GetWorkflowResult(&summary))
+	s.Equal(1, summary.Certified)
+	s.Equal(1, summary.AutoRevoked)
+	s.Equal(0, summary.Pending)
+}
+
+func (s *UnitTestSuite) Test_CampaignOrchestration_Refused() {
+	h, err := NewCampaign()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	s.env.ExecuteWorkflow(h.CampaignOrchestration, messages.CampaignOrchestrationInput{
+		Permissions: []string{constants.PermissionTypeReadFiles},
+		RequestedBy: "ciso@temporal.io",
+	})
+	s.True(s.env.IsWorkflowCompleted())
+	s.Equal("deadline required and missing", errorMessage(s.env.GetWorkflowError()))
+}
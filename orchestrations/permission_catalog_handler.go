@@ -0,0 +1,61 @@
+package orchestrations
+
+import (
+	"errors"
+	"fmt"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/constants"
+	msgs "github.com/temporal-sa/temporal-entity-lifecycle-go/messages"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/permission_catalog_state"
+	wf "go.temporal.io/sdk/workflow"
+)
+
+type PermissionCatalogOrchestrationHandler struct{}
+
+func NewPermissionCatalog() (*PermissionCatalogOrchestrationHandler, error) {
+	return &PermissionCatalogOrchestrationHandler{}, nil
+}
+
+// PermissionCatalogOrchestration is the single, long-running entity holding every permission type users may request.
+// It runs under constants.PermissionCatalogWorkflowID.
+func (h *PermissionCatalogOrchestrationHandler) PermissionCatalogOrchestration(ctx wf.Context, in msgs.PermissionCatalogOrchestrationInput) error {
+	state, err := permission_catalog_state.New(ctx, permission_catalog_state.WithSnapshot(in))
+	if err != nil {
+		return errors.Join(errors.New("unable to initialize permission_catalog_state"), err)
+	}
+	draining := false
+	err = wf.SetUpdateHandlerWithOptions(ctx, constants.DefinePermissionUpdateHandlerName,
+		func(inner wf.Context, req msgs.DefinePermissionRequest) (msgs.DefinePermissionResponse, error) {
+			return msgs.DefinePermissionResponse{}, state.Define(inner, req)
+		},
+		wf.UpdateHandlerOptions{
+			Validator: func(ctx wf.Context, req msgs.DefinePermissionRequest) error {
+				if draining {
+					return drainingError()
+				}
+				return invalidRequest(state.ValidateDefine(req))
+			},
+		})
+	if err != nil {
+		return errors.Join(errors.New(
+			fmt.Sprintf("unable to set %s UpdateHandler", constants.DefinePermissionUpdateHandlerName)), err)
+	}
+	err = wf.SetQueryHandler(ctx, constants.PermissionDefinitionsQueryHandlerName,
+		func() (msgs.PermissionDefinitionsResponse, error) {
+			return state.Definitions(), nil
+		})
+	if err != nil {
+		return errors.Join(errors.New(
+			fmt.Sprintf("unable to set %s QueryHandler", constants.PermissionDefinitionsQueryHandlerName)), err)
+	}
+	err = wf.Await(ctx, func() bool { return wf.GetInfo(ctx).GetContinueAsNewSuggested() })
+	if err != nil {
+		return errors.Join(errors.New("wait cancelled"), err)
+	}
+	// A define_permission still verifying its approver would be lost with this run
+	draining = true
+	err = wf.Await(ctx, func() bool { return wf.AllHandlersFinished(ctx) })
+	if err != nil {
+		return errors.Join(errors.New("wait cancelled"), err)
+	}
+	return wf.NewContinueAsNewError(ctx, h.PermissionCatalogOrchestration, state.Snapshot())
+}
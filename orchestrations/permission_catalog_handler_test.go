@@ -0,0 +1,193 @@
+package orchestrations
+
+import (
+	"github.com/stretchr/testify/mock"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/constants"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/messages"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/orchestrations/activity_handler"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/permission_catalog_state"
+	"go.temporal.io/sdk/workflow"
+	"time"
+)
+
+func (s *UnitTestSuite) Test_PermissionCatalogOrchestration_DefinePermission() {
+	h, err := NewPermissionCatalog()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	uc := &updateCallbacks{t: s.T()}
+	invalid := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.DefinePermissionUpdateHandlerName, "1", uc,
+			messages.DefinePermissionRequest{
+				Definition: messages.PermissionDefinition{
+					ApprovalPolicy:    messages.ApprovalPolicy{RequiredApprovals: 3},
+					ApproverAuthority: "release_manager",
+					Description:       "Deploy to production",
+					Name:              "deploy_production",
+					RiskLevel:         constants.RiskLevelHigh,
+				},
+			})
+	}, time.Second*1)
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.DefinePermissionUpdateHandlerName, "2", invalid,
+			messages.DefinePermissionRequest{
+				Definition: messages.PermissionDefinition{
+					Name:      "write_files",
+					RiskLevel: "extreme",
+				},
+			})
+	}, time.Second*2)
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SetContinueAsNewSuggested(true)
+	}, time.Second*3)
+	s.env.ExecuteWorkflow(h.PermissionCatalogOrchestration, messages.PermissionCatalogOrchestrationInput{
+		Definitions: permission_catalog_state.DefaultDefinitions(),
+	})
+	s.True(s.env.IsWorkflowCompleted())
+	s.True(workflow.IsContinueAsNewError(s.env.GetWorkflowError()))
+	s.Nil(uc.Error())
+	s.True(invalid.Rejected())
+	v, err := s.env.QueryWorkflow(constants.PermissionDefinitionsQueryHandlerName)
+	s.Nil(err)
+	definitions := messages.PermissionDefinitionsResponse{}
+	err = v.Get(&definitions)
+	s.Nil(err)
+	s.Len(definitions.Definitions, len(permission_catalog_state.DefaultDefinitions())+1)
+	// Approvers who are not release managers fall back on the super-permission
+	s.Contains(definitions.Definitions, messages.PermissionDefinition{
+		AdminPermission:   constants.PermissionTypeGrantPermissions,
+		ApprovalPolicy:    messages.ApprovalPolicy{RequiredApprovals: 3},
+		ApproverAuthority: "release_manager",
+		Description:       "Deploy to production",
+		Name:              "deploy_production",
+		RiskLevel:         constants.RiskLevelHigh,
+	})
+}
+
+func (s *UnitTestSuite) Test_PermissionCatalogOrchestration_RedefineAuthorityRefused() {
+	h, err := NewPermissionCatalog()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	escalate := &updateCallbacks{t: s.T()}
+	describe := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.DefinePermissionUpdateHandlerName, "1", escalate,
+			messages.DefinePermissionRequest{
+				Definition: messages.PermissionDefinition{
+					AdminPermission:   constants.PermissionTypeReadFiles,
+					ApproverAuthority: constants.PermissionTypeApproveReadFiles,
+					Name:              constants.PermissionTypeGrantPermissions,
+					RiskLevel:         constants.RiskLevelHigh,
+				},
+			})
+	}, time.Second*1)
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.DefinePermissionUpdateHandlerName, "2", describe,
+			messages.DefinePermissionRequest{
+				Definition: messages.PermissionDefinition{
+					ApprovalPolicy: messages.ApprovalPolicy{RequiredApprovals: 2},
+					Description:    "Approve anything",
+					Name:           constants.PermissionTypeGrantPermissions,
+					RiskLevel:      constants.RiskLevelHigh,
+				},
+			})
+	}, time.Second*2)
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SetContinueAsNewSuggested(true)
+	}, time.Second*3)
+	s.env.ExecuteWorkflow(h.PermissionCatalogOrchestration, messages.PermissionCatalogOrchestrationInput{
+		Definitions: permission_catalog_state.DefaultDefinitions(),
+	})
+	s.True(s.env.IsWorkflowCompleted())
+	s.True(escalate.Rejected())
+	s.Nil(describe.Error())
+	v, err := s.env.QueryWorkflow(constants.PermissionDefinitionsQueryHandlerName)
+	s.Nil(err)
+	definitions := messages.PermissionDefinitionsResponse{}
+	err = v.Get(&definitions)
+	s.Nil(err)
+	// Leaving the authority out keeps the existing one
+	s.Contains(definitions.Definitions, messages.PermissionDefinition{
+		AdminPermission:   constants.PermissionTypeGrantPermissions,
+		ApprovalPolicy:    messages.ApprovalPolicy{RequiredApprovals: 2},
+		ApproverAuthority: constants.PermissionTypeApproveGrantPermissions,
+		Description:       "Approve anything",
+		Name:              constants.PermissionTypeGrantPermissions,
+		RiskLevel:         constants.RiskLevelHigh,
+	})
+}
+
+func (s *UnitTestSuite) Test_PermissionCatalogOrchestration_RelaxRequiresAdmin() {
+	h, err := NewPermissionCatalog()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	s.env.OnActivity(new(activity_handler.Handler).VerifyApprover, mock.Anything, messages.VerifyApproverRequest{
+		AdminOnly:  true,
+		ApproverID: "a@temporal.io",
+		Permission: constants.PermissionTypeGrantPermissions,
+	}).Return(messages.VerifyApproverResponse{Verified: false}, nil).Once()
+	s.env.OnActivity(new(activity_handler.Handler).VerifyApprover, mock.Anything, messages.VerifyApproverRequest{
+		AdminOnly:  true,
+		ApproverID: "admin@temporal.io",
+		Permission: constants.PermissionTypeGrantPermissions,
+	}).Return(messages.VerifyApproverResponse{Verified: true}, nil).Once()
+	unapproved := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.DefinePermissionUpdateHandlerName, "1", unapproved,
+			messages.DefinePermissionRequest{
+				Definition: messages.PermissionDefinition{
+					ApprovalPolicy: messages.ApprovalPolicy{RequiredApprovals: 1},
+					Name:           constants.PermissionTypeGrantPermissions,
+					RiskLevel:      constants.RiskLevelHigh,
+				},
+			})
+	}, time.Second*1)
+	notAdmin := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.DefinePermissionUpdateHandlerName, "2", notAdmin,
+			messages.DefinePermissionRequest{
+				ApproverID: "a@temporal.io",
+				Definition: messages.PermissionDefinition{
+					ApprovalPolicy: messages.ApprovalPolicy{RequiredApprovals: 2},
+					Name:           constants.PermissionTypeGrantPermissions,
+					RiskLevel:      constants.RiskLevelLow,
+				},
+			})
+	}, time.Second*2)
+	approved := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.DefinePermissionUpdateHandlerName, "3", approved,
+			messages.DefinePermissionRequest{
+				ApproverID: "admin@temporal.io",
+				Definition: messages.PermissionDefinition{
+					ApprovalPolicy: messages.ApprovalPolicy{RequiredApprovals: 1},
+					Name:           constants.PermissionTypeGrantPermissions,
+					RiskLevel:      constants.RiskLevelHigh,
+				},
+			})
+	}, time.Second*3)
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SetContinueAsNewSuggested(true)
+	}, time.Second*4)
+	s.env.ExecuteWorkflow(h.PermissionCatalogOrchestration, messages.PermissionCatalogOrchestrationInput{
+		Definitions: permission_catalog_state.DefaultDefinitions(),
+	})
+	s.True(s.env.IsWorkflowCompleted())
+	s.True(unapproved.Rejected())
+	s.Equal("approver required and missing to lower the approvals or change the risk level of permission "+
+		"grant_permissions", errorMessage(unapproved.Error()))
+	s.Equal("a@temporal.io cannot lower the approvals or change the risk level of permission grant_permissions",
+		errorMessage(notAdmin.Error()))
+	s.Nil(approved.Error())
+	v, err := s.env.QueryWorkflow(constants.PermissionDefinitionsQueryHandlerName)
+	s.Nil(err)
+	definitions := messages.PermissionDefinitionsResponse{}
+	s.Nil(v.Get(&definitions))
+	s.Contains(definitions.Definitions, messages.PermissionDefinition{
+		AdminPermission:   constants.PermissionTypeGrantPermissions,
+		ApprovalPolicy:    messages.ApprovalPolicy{RequiredApprovals: 1},
+		ApproverAuthority: constants.PermissionTypeApproveGrantPermissions,
+		Name:              constants.PermissionTypeGrantPermissions,
+		RiskLevel:         constants.RiskLevelHigh,
+	})
+}
@@ -0,0 +1,90 @@
+package orchestrations
+
+import (
+	"errors"
+	"fmt"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/constants"
+	msgs "github.com/temporal-sa/temporal-entity-lifecycle-go/messages"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/role_state"
+	wf "go.temporal.io/sdk/workflow"
+)
+
+type RoleOrchestrationHandler struct{}
+
+func NewRole() (*RoleOrchestrationHandler, error) {
+	return &RoleOrchestrationHandler{}, nil
+}
+
+// RoleOrchestration is the long-running entity of a role: a named bundle of permissions that member users inherit. It
+// runs under role_state.WorkflowID(role) and keeps its members' inherited permissions up to date.
+func (h *RoleOrchestrationHandler) RoleOrchestration(ctx wf.Context, in msgs.RoleOrchestrationInput) error {
+	state, err := role_state.New(ctx, role_state.WithSnapshot(in))
+	if err != nil {
+		return errors.Join(errors.New("unable to initialize role_state"), err)
+	}
+	draining := false
+	validated := func(err error) error {
+		if draining {
+			return drainingError()
+		}
+		return invalidRequest(err)
+	}
+	err = wf.SetUpdateHandlerWithOptions(ctx, constants.DefineRoleUpdateHandlerName,
+		func(inner wf.Context, req msgs.DefineRoleRequest) (msgs.DefineRoleResponse, error) {
+			return msgs.DefineRoleResponse{}, state.Define(inner, req)
+		},
+		wf.UpdateHandlerOptions{
+			Validator: func(ctx wf.Context, req msgs.DefineRoleRequest) error {
+				return validated(state.ValidateDefine(req))
+			},
+		})
+	if err != nil {
+		return errors.Join(errors.New(
+			fmt.Sprintf("unable to set %s UpdateHandler", constants.DefineRoleUpdateHandlerName)), err)
+	}
+	err = wf.SetUpdateHandlerWithOptions(ctx, constants.AddRoleMemberUpdateHandlerName,
+		func(inner wf.Context, req msgs.AddRoleMemberRequest) (msgs.AddRoleMemberResponse, error) {
+			return state.AddMember(req)
+		},
+		wf.UpdateHandlerOptions{
+			Validator: func(ctx wf.Context, req msgs.AddRoleMemberRequest) error {
+				return validated(state.ValidateAddMember(req))
+			},
+		})
+	if err != nil {
+		return errors.Join(errors.New(
+			fmt.Sprintf("unable to set %s UpdateHandler", constants.AddRoleMemberUpdateHandlerName)), err)
+	}
+	err = wf.SetUpdateHandlerWithOptions(ctx, constants.RemoveRoleMemberUpdateHandlerName,
+		func(inner wf.Context, req msgs.RemoveRoleMemberRequest) (msgs.RemoveRoleMemberResponse, error) {
+			return msgs.RemoveRoleMemberResponse{}, state.RemoveMember(req)
+		},
+		wf.UpdateHandlerOptions{
+			Validator: func(ctx wf.Context, req msgs.RemoveRoleMemberRequest) error {
+				return validated(state.ValidateRemoveMember(req))
+			},
+		})
+	if err != nil {
+		return errors.Join(errors.New(
+			fmt.Sprintf("unable to set %s UpdateHandler", constants.RemoveRoleMemberUpdateHandlerName)), err)
+	}
+	err = wf.SetQueryHandler(ctx, constants.RoleDetailsQueryHandlerName,
+		func() (msgs.RoleDetailsResponse, error) {
+			return state.Details(), nil
+		})
+	if err != nil {
+		return errors.Join(errors.New(
+			fmt.Sprintf("unable to set %s QueryHandler", constants.RoleDetailsQueryHandlerName)), err)
+	}
+	err = wf.Await(ctx, func() bool { return wf.GetInfo(ctx).GetContinueAsNewSuggested() })
+	if err != nil {
+		return errors.Join(errors.New("wait cancelled"), err)
+	}
+	// A define_role still checking the catalog would be lost with this run
+	draining = true
+	err = wf.Await(ctx, func() bool { return wf.AllHandlersFinished(ctx) })
+	if err != nil {
+		return errors.Join(errors.New("wait cancelled"), err)
+	}
+	return wf.NewContinueAsNewError(ctx, h.RoleOrchestration, state.Snapshot())
+}
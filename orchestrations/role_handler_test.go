@@ -0,0 +1,132 @@
+package orchestrations
+
+import (
+	"github.com/stretchr/testify/mock"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/constants"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/messages"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/orchestrations/activity_handler"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/workflow"
+	"time"
+)
+
+func (s *UnitTestSuite) Test_RoleOrchestration_DefineAndPropagate() {
+	h, err := NewRole()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	s.env.SetStartWorkflowOptions(client.StartWorkflowOptions{ID: "role:auditors"})
+	s.env.OnSignalExternalWorkflow(mock.Anything, "a@temporal.io", "", constants.RolePermissionsChangedSignalName,
+		messages.RolePermissionsChangedSignal{
+			Permissions: []string{constants.PermissionTypeReadFiles},
+			Role:        "auditors",
+		}).Return(nil).Once()
+	s.env.OnActivity(new(activity_handler.Handler).VerifyApprover, mock.Anything, messages.VerifyApproverRequest{
+		AdminOnly:  true,
+		ApproverID: "admin@temporal.io",
+		Permission: constants.PermissionTypeReadFiles,
+	}).Return(messages.VerifyApproverResponse{Verified: true}, nil).Once()
+	define := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.DefineRoleUpdateHandlerName, "1", define,
+			messages.DefineRoleRequest{
+				ApproverID:  "admin@temporal.io",
+				Description: "Read-only access for auditors",
+				Permissions: []string{constants.PermissionTypeReadFiles},
+			})
+	}, time.Second*1)
+	notInCatalog := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.DefineRoleUpdateHandlerName, "2", notInCatalog,
+			messages.DefineRoleRequest{ApproverID: "admin@temporal.io", Permissions: []string{"deploy_production"}})
+	}, time.Second*2)
+	join := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.AddRoleMemberUpdateHandlerName, "3", join,
+			messages.AddRoleMemberRequest{UserID: "b@temporal.io"})
+	}, time.Second*3)
+	leave := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.RemoveRoleMemberUpdateHandlerName, "4", leave,
+			messages.RemoveRoleMemberRequest{UserID: "a@temporal.io"})
+	}, time.Second*4)
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SetContinueAsNewSuggested(true)
+	}, time.Second*5)
+	s.env.ExecuteWorkflow(h.RoleOrchestration, messages.RoleOrchestrationInput{
+		Members: []string{"a@temporal.io"},
+	})
+	s.True(s.env.IsWorkflowCompleted())
+	s.True(workflow.IsContinueAsNewError(s.env.GetWorkflowError()))
+	s.Nil(define.Error())
+	s.Equal("deploy_production is not a permission in the catalog", errorMessage(notInCatalog.Error()))
+	s.Nil(join.Error())
+	s.Equal(messages.AddRoleMemberResponse{Permissions: []string{constants.PermissionTypeReadFiles}}, join.Result())
+	s.Nil(leave.Error())
+	v, err := s.env.QueryWorkflow(constants.RoleDetailsQueryHandlerName)
+	s.Nil(err)
+	details := messages.RoleDetailsResponse{}
+	s.Nil(v.Get(&details))
+	s.Equal(messages.RoleDetailsResponse{
+		Description: "Read-only access for auditors",
+		Members:     []string{"b@temporal.io"},
+		Name:        "auditors",
+		Permissions: []string{constants.PermissionTypeReadFiles},
+	}, details)
+}
+
+func (s *UnitTestSuite) Test_RoleOrchestration_DefineRefused() {
+	h, err := NewRole()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	s.env.SetStartWorkflowOptions(client.StartWorkflowOptions{ID: "role:auditors"})
+	s.env.OnActivity(new(activity_handler.Handler).VerifyApprover, mock.Anything, messages.VerifyApproverRequest{
+		AdminOnly:  true,
+		ApproverID: "a@temporal.io",
+		Permission: constants.PermissionTypeApproveReadFiles,
+	}).Return(messages.VerifyApproverResponse{Verified: false}, nil).Once()
+	unapproved := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.DefineRoleUpdateHandlerName, "1", unapproved,
+			messages.DefineRoleRequest{
+				Permissions: []string{constants.PermissionTypeReadFiles, constants.PermissionTypeGrantPermissions},
+			})
+	}, time.Second*1)
+	quorum := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.DefineRoleUpdateHandlerName, "2", quorum,
+			messages.DefineRoleRequest{
+				ApproverID:  "admin@temporal.io",
+				Permissions: []string{constants.PermissionTypeReadFiles, constants.PermissionTypeGrantPermissions},
+			})
+	}, time.Second*2)
+	notAdmin := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.DefineRoleUpdateHandlerName, "3", notAdmin,
+			messages.DefineRoleRequest{
+				ApproverID:  "a@temporal.io",
+				Permissions: []string{constants.PermissionTypeReadFiles, constants.PermissionTypeApproveReadFiles},
+			})
+	}, time.Second*3)
+	narrow := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.DefineRoleUpdateHandlerName, "4", narrow,
+			messages.DefineRoleRequest{Description: "Nothing left", Permissions: []string{}})
+	}, time.Second*4)
+	s.env.OnSignalExternalWorkflow(mock.Anything, "a@temporal.io", "", constants.RolePermissionsChangedSignalName,
+		messages.RolePermissionsChangedSignal{Permissions: []string{}, Role: "auditors"}).Return(nil).Once()
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SetContinueAsNewSuggested(true)
+	}, time.Second*5)
+	s.env.ExecuteWorkflow(h.RoleOrchestration, messages.RoleOrchestrationInput{
+		Members:     []string{"a@temporal.io"},
+		Permissions: []string{constants.PermissionTypeReadFiles},
+	})
+	s.True(s.env.IsWorkflowCompleted())
+	s.True(unapproved.Rejected())
+	s.Equal("approver required and missing to add permissions", errorMessage(unapproved.Error()))
+	s.Equal("permission grant_permissions requires 2 approvals and cannot be added to role auditors while it has "+
+		"members", errorMessage(quorum.Error()))
+	s.Equal("a@temporal.io cannot add permission approve:read_files to role auditors", errorMessage(notAdmin.Error()))
+	// Taking permissions away needs no approver
+	s.Nil(narrow.Error())
+}
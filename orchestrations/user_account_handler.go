@@ -6,39 +6,107 @@ import (
 	"github.com/temporal-sa/temporal-entity-lifecycle-go/constants"
 	msgs "github.com/temporal-sa/temporal-entity-lifecycle-go/messages"
 	"github.com/temporal-sa/temporal-entity-lifecycle-go/user_account_state"
+	"go.temporal.io/sdk/temporal"
 	wf "go.temporal.io/sdk/workflow"
 )
 
-type UserAccountOrchestrationHandler struct{}
+type UserAccountOrchestrationHandler struct {
+	approvalPolicies   map[string]msgs.ApprovalPolicy
+	approvalSLA        msgs.ApprovalSLA
+	deletionPolicy     msgs.DeletionPolicy
+	separationOfDuties []msgs.SeparationOfDutiesRule
+}
+
+type Option func(*UserAccountOrchestrationHandler)
+
+func New(opts ...Option) (*UserAccountOrchestrationHandler, error) {
+	h := &UserAccountOrchestrationHandler{}
+	for _, o := range opts {
+		o(h)
+	}
+	return h, nil
+}
+
+// WithApprovalPolicies sets the number of distinct approvers required per permission type.
+func WithApprovalPolicies(policies map[string]msgs.ApprovalPolicy) Option {
+	return func(h *UserAccountOrchestrationHandler) {
+		h.approvalPolicies = policies
+	}
+}
+
+// WithApprovalSLA sets the worker-wide reminder, escalation and auto-deny schedule for pending permission requests.
+func WithApprovalSLA(sla msgs.ApprovalSLA) Option {
+	return func(h *UserAccountOrchestrationHandler) {
+		h.approvalSLA = sla
+	}
+}
 
-func New() (*UserAccountOrchestrationHandler, error) {
-	return &UserAccountOrchestrationHandler{}, nil
+// WithDeletionPolicy sets the worker-wide default undo window for deletions and the bounds on per-request windows.
+func WithDeletionPolicy(policy msgs.DeletionPolicy) Option {
+	return func(h *UserAccountOrchestrationHandler) {
+		h.deletionPolicy = policy
+	}
+}
+
+// WithSeparationOfDutiesRules sets pairs of permissions that may not be held by the same user.
+func WithSeparationOfDutiesRules(rules []msgs.SeparationOfDutiesRule) Option {
+	return func(h *UserAccountOrchestrationHandler) {
+		h.separationOfDuties = rules
+	}
 }
 
 func (h *UserAccountOrchestrationHandler) Orchestration(ctx wf.Context, in msgs.UserAccountOrchestrationInput) error {
-	state, err := user_account_state.New(ctx, user_account_state.WithSnapshot(in))
+	state, err := user_account_state.New(ctx,
+		user_account_state.WithApprovalPolicies(h.approvalPolicies),
+		user_account_state.WithApprovalSLA(h.approvalSLA),
+		user_account_state.WithDeletionPolicy(h.deletionPolicy),
+		user_account_state.WithSeparationOfDutiesRules(h.separationOfDuties),
+		user_account_state.WithSnapshot(in))
 	if err != nil {
 		return errors.Join(errors.New("unable to initialize user_account_state"), err)
 	}
-	err = wf.SetUpdateHandler(ctx, constants.CreateUserAccountUpdateHandlerName,
+	// Once the run starts finishing its in-flight updates, new ones are refused so that they cannot hold it open
+	draining := false
+	validated := func(err error) error {
+		if draining {
+			return drainingError()
+		}
+		return invalidRequest(err)
+	}
+	err = wf.SetUpdateHandlerWithOptions(ctx, constants.CreateUserAccountUpdateHandlerName,
 		func(inner wf.Context, req msgs.CreateUserAccountRequest) (msgs.CreateUserAccountResponse, error) {
 			return msgs.CreateUserAccountResponse{}, state.CreateUser(req)
+		},
+		wf.UpdateHandlerOptions{
+			Validator: func(ctx wf.Context, req msgs.CreateUserAccountRequest) error {
+				return validated(state.ValidateCreateUser(req))
+			},
 		})
 	if err != nil {
 		return errors.Join(errors.New(fmt.Sprintf("unable to set %s UpdateHandler",
 			constants.CreateUserAccountUpdateHandlerName)), err)
 	}
-	err = wf.SetUpdateHandler(ctx, constants.AddUserPermissionUpdateHandlerName,
+	err = wf.SetUpdateHandlerWithOptions(ctx, constants.AddUserPermissionUpdateHandlerName,
 		func(inner wf.Context, req msgs.AddUserPermissionRequest) (msgs.AddUserPermissionResponse, error) {
-			return msgs.AddUserPermissionResponse{}, state.RequestAddPermission(req)
+			return msgs.AddUserPermissionResponse{}, state.RequestAddPermission(inner, req)
+		},
+		wf.UpdateHandlerOptions{
+			Validator: func(ctx wf.Context, req msgs.AddUserPermissionRequest) error {
+				return validated(state.ValidateAddPermission(req))
+			},
 		})
 	if err != nil {
 		return errors.Join(errors.New(
 			fmt.Sprintf("unable to set %s UpdateHandler", constants.AddUserPermissionUpdateHandlerName)), err)
 	}
-	err = wf.SetUpdateHandler(ctx, constants.ApproveUserPermissionUpdateHandlerName,
+	err = wf.SetUpdateHandlerWithOptions(ctx, constants.ApproveUserPermissionUpdateHandlerName,
 		func(inner wf.Context, req msgs.ApproveUserPermissionRequest) (msgs.ApproveUserPermissionResponse, error) {
-			return msgs.ApproveUserPermissionResponse{}, state.RequestApprovePermission(inner, req)
+			return state.RequestApprovePermission(inner, req)
+		},
+		wf.UpdateHandlerOptions{
+			Validator: func(ctx wf.Context, req msgs.ApproveUserPermissionRequest) error {
+				return validated(state.ValidateApprovePermission(ctx, req))
+			},
 		})
 	if err != nil {
 		return errors.Join(
@@ -47,24 +115,198 @@ func (h *UserAccountOrchestrationHandler) Orchestration(ctx wf.Context, in msgs.
 					"unable to set %s UpdateHandler", constants.ApproveUserPermissionUpdateHandlerName)),
 			err)
 	}
-	err = wf.SetUpdateHandler(ctx, constants.DeleteUserAccountUpdateHandlerName,
+	err = wf.SetUpdateHandlerWithOptions(ctx, constants.RejectUserPermissionUpdateHandlerName,
+		func(inner wf.Context, req msgs.RejectUserPermissionRequest) (msgs.RejectUserPermissionResponse, error) {
+			return msgs.RejectUserPermissionResponse{}, state.RequestRejectPermission(inner, req)
+		},
+		wf.UpdateHandlerOptions{
+			Validator: func(ctx wf.Context, req msgs.RejectUserPermissionRequest) error {
+				return validated(state.ValidateRejectPermission(req))
+			},
+		})
+	if err != nil {
+		return errors.Join(errors.New(
+			fmt.Sprintf("unable to set %s UpdateHandler", constants.RejectUserPermissionUpdateHandlerName)), err)
+	}
+	err = wf.SetUpdateHandlerWithOptions(ctx, constants.RevokeUserPermissionUpdateHandlerName,
+		func(inner wf.Context, req msgs.RevokeUserPermissionRequest) (msgs.RevokeUserPermissionResponse, error) {
+			return msgs.RevokeUserPermissionResponse{}, state.RequestRevokePermission(inner, req)
+		},
+		wf.UpdateHandlerOptions{
+			Validator: func(ctx wf.Context, req msgs.RevokeUserPermissionRequest) error {
+				return validated(state.ValidateRevokePermission(req))
+			},
+		})
+	if err != nil {
+		return errors.Join(errors.New(
+			fmt.Sprintf("unable to set %s UpdateHandler", constants.RevokeUserPermissionUpdateHandlerName)), err)
+	}
+	err = wf.SetUpdateHandlerWithOptions(ctx, constants.DeleteUserAccountUpdateHandlerName,
 		func(inner wf.Context, req msgs.DeleteUserAccountRequest) (msgs.DeleteUserAccountResponse, error) {
-			state.RequestDeletion(req)
-			return msgs.DeleteUserAccountResponse{}, nil
+			return msgs.DeleteUserAccountResponse{}, state.RequestDeletion(req)
+		},
+		wf.UpdateHandlerOptions{
+			Validator: func(ctx wf.Context, req msgs.DeleteUserAccountRequest) error {
+				return validated(state.ValidateDeletion(req))
+			},
 		})
 	if err != nil {
 		return errors.Join(errors.New(
 			fmt.Sprintf("unable to set %s UpdateHandler", constants.DeleteUserAccountUpdateHandlerName)), err)
 	}
-	err = wf.SetUpdateHandler(ctx, constants.UndoDeleteUserAccountUpdateHandlerName,
+	err = wf.SetUpdateHandlerWithOptions(ctx, constants.UndoDeleteUserAccountUpdateHandlerName,
 		func(inner wf.Context, req msgs.UndoDeleteUserAccountRequest) (msgs.UndoDeleteUserAccountResponse, error) {
 			requestUndoDeletionErr := state.RequestUndoDeletion(req)
 			return msgs.UndoDeleteUserAccountResponse{}, requestUndoDeletionErr
+		},
+		wf.UpdateHandlerOptions{
+			Validator: func(ctx wf.Context, req msgs.UndoDeleteUserAccountRequest) error {
+				return validated(state.ValidateUndoDeletion(req))
+			},
 		})
 	if err != nil {
 		return errors.Join(errors.New(fmt.Sprintf("unable to set %s UpdateHandler",
 			constants.UndoDeleteUserAccountUpdateHandlerName)), err)
 	}
+	err = wf.SetUpdateHandlerWithOptions(ctx, constants.SuspendUserAccountUpdateHandlerName,
+		func(inner wf.Context, req msgs.SuspendUserAccountRequest) (msgs.SuspendUserAccountResponse, error) {
+			return msgs.SuspendUserAccountResponse{}, state.RequestSuspend(req)
+		},
+		wf.UpdateHandlerOptions{
+			Validator: func(ctx wf.Context, req msgs.SuspendUserAccountRequest) error {
+				return validated(state.ValidateSuspend(req))
+			},
+		})
+	if err != nil {
+		return errors.Join(errors.New(
+			fmt.Sprintf("unable to set %s UpdateHandler", constants.SuspendUserAccountUpdateHandlerName)), err)
+	}
+	err = wf.SetUpdateHandlerWithOptions(ctx, constants.ReinstateUserAccountUpdateHandlerName,
+		func(inner wf.Context, req msgs.ReinstateUserAccountRequest) (msgs.ReinstateUserAccountResponse, error) {
+			return msgs.ReinstateUserAccountResponse{}, state.RequestReinstate(inner, req)
+		},
+		wf.UpdateHandlerOptions{
+			Validator: func(ctx wf.Context, req msgs.ReinstateUserAccountRequest) error {
+				return validated(state.ValidateReinstate(ctx, req))
+			},
+		})
+	if err != nil {
+		return errors.Join(errors.New(
+			fmt.Sprintf("unable to set %s UpdateHandler", constants.ReinstateUserAccountUpdateHandlerName)), err)
+	}
+	err = wf.SetUpdateHandlerWithOptions(ctx, constants.UpdateUserProfileUpdateHandlerName,
+		func(inner wf.Context, req msgs.UpdateUserProfileRequest) (msgs.UpdateUserProfileResponse, error) {
+			return msgs.UpdateUserProfileResponse{}, state.RequestUpdateProfile(req)
+		},
+		wf.UpdateHandlerOptions{
+			Validator: func(ctx wf.Context, req msgs.UpdateUserProfileRequest) error {
+				return validated(state.ValidateUpdateProfile(req))
+			},
+		})
+	if err != nil {
+		return errors.Join(errors.New(
+			fmt.Sprintf("unable to set %s UpdateHandler", constants.UpdateUserProfileUpdateHandlerName)), err)
+	}
+	err = wf.SetUpdateHandlerWithOptions(ctx, constants.AddUserRoleUpdateHandlerName,
+		func(inner wf.Context, req msgs.AddUserRoleRequest) (msgs.AddUserRoleResponse, error) {
+			return msgs.AddUserRoleResponse{}, state.RequestAddRole(inner, req)
+		},
+		wf.UpdateHandlerOptions{
+			Validator: func(ctx wf.Context, req msgs.AddUserRoleRequest) error {
+				return validated(state.ValidateAddRole(req))
+			},
+		})
+	if err != nil {
+		return errors.Join(errors.New(
+			fmt.Sprintf("unable to set %s UpdateHandler", constants.AddUserRoleUpdateHandlerName)), err)
+	}
+	err = wf.SetUpdateHandlerWithOptions(ctx, constants.ApproveUserRoleUpdateHandlerName,
+		func(inner wf.Context, req msgs.ApproveUserRoleRequest) (msgs.ApproveUserRoleResponse, error) {
+			return msgs.ApproveUserRoleResponse{}, state.RequestApproveRole(inner, req)
+		},
+		wf.UpdateHandlerOptions{
+			Validator: func(ctx wf.Context, req msgs.ApproveUserRoleRequest) error {
+				return validated(state.ValidateApproveRole(ctx, req))
+			},
+		})
+	if err != nil {
+		return errors.Join(errors.New(
+			fmt.Sprintf("unable to set %s UpdateHandler", constants.ApproveUserRoleUpdateHandlerName)), err)
+	}
+	err = wf.SetUpdateHandlerWithOptions(ctx, constants.RemoveUserRoleUpdateHandlerName,
+		func(inner wf.Context, req msgs.RemoveUserRoleRequest) (msgs.RemoveUserRoleResponse, error) {
+			return msgs.RemoveUserRoleResponse{}, state.RequestRemoveRole(inner, req)
+		},
+		wf.UpdateHandlerOptions{
+			Validator: func(ctx wf.Context, req msgs.RemoveUserRoleRequest) error {
+				return validated(state.ValidateRemoveRole(req))
+			},
+		})
+	if err != nil {
+		return errors.Join(errors.New(
+			fmt.Sprintf("unable to set %s UpdateHandler", constants.RemoveUserRoleUpdateHandlerName)), err)
+	}
+	err = wf.SetUpdateHandlerWithOptions(ctx, constants.BreakGlassUpdateHandlerName,
+		func(inner wf.Context, req msgs.BreakGlassRequest) (msgs.BreakGlassResponse, error) {
+			return state.RequestBreakGlass(inner, req)
+		},
+		wf.UpdateHandlerOptions{
+			Validator: func(ctx wf.Context, req msgs.BreakGlassRequest) error {
+				return validated(state.ValidateBreakGlass(req))
+			},
+		})
+	if err != nil {
+		return errors.Join(errors.New(
+			fmt.Sprintf("unable to set %s UpdateHandler", constants.BreakGlassUpdateHandlerName)), err)
+	}
+	err = wf.SetUpdateHandlerWithOptions(ctx, constants.AcknowledgeBreakGlassUpdateHandlerName,
+		func(inner wf.Context, req msgs.AcknowledgeBreakGlassRequest) (msgs.AcknowledgeBreakGlassResponse, error) {
+			return msgs.AcknowledgeBreakGlassResponse{}, state.RequestAcknowledgeBreakGlass(inner, req)
+		},
+		wf.UpdateHandlerOptions{
+			Validator: func(ctx wf.Context, req msgs.AcknowledgeBreakGlassRequest) error {
+				return validated(state.ValidateAcknowledgeBreakGlass(ctx, req))
+			},
+		})
+	if err != nil {
+		return errors.Join(errors.New(
+			fmt.Sprintf("unable to set %s UpdateHandler", constants.AcknowledgeBreakGlassUpdateHandlerName)), err)
+	}
+	err = wf.SetUpdateHandlerWithOptions(ctx, constants.DelegateApprovalUpdateHandlerName,
+		func(inner wf.Context, req msgs.DelegateApprovalRequest) (msgs.DelegateApprovalResponse, error) {
+			return msgs.DelegateApprovalResponse{}, state.RequestDelegateApproval(inner, req)
+		},
+		wf.UpdateHandlerOptions{
+			Validator: func(ctx wf.Context, req msgs.DelegateApprovalRequest) error {
+				return validated(state.ValidateDelegateApproval(ctx, req))
+			},
+		})
+	if err != nil {
+		return errors.Join(errors.New(
+			fmt.Sprintf("unable to set %s UpdateHandler", constants.DelegateApprovalUpdateHandlerName)), err)
+	}
+	err = wf.SetUpdateHandlerWithOptions(ctx, constants.RevokeDelegationUpdateHandlerName,
+		func(inner wf.Context, req msgs.RevokeDelegationRequest) (msgs.RevokeDelegationResponse, error) {
+			return msgs.RevokeDelegationResponse{}, state.RequestRevokeDelegation(inner, req)
+		},
+		wf.UpdateHandlerOptions{
+			Validator: func(ctx wf.Context, req msgs.RevokeDelegationRequest) error {
+				return validated(state.ValidateRevokeDelegation(req))
+			},
+		})
+	if err != nil {
+		return errors.Join(errors.New(
+			fmt.Sprintf("unable to set %s UpdateHandler", constants.RevokeDelegationUpdateHandlerName)), err)
+	}
+	// Roles signal their members whenever their permissions change
+	rolePermissionsChanged := wf.GetSignalChannel(ctx, constants.RolePermissionsChangedSignalName)
+	wf.Go(ctx, func(inner wf.Context) {
+		for {
+			sig := msgs.RolePermissionsChangedSignal{}
+			rolePermissionsChanged.Receive(inner, &sig)
+			state.ApplyRolePermissions(sig)
+		}
+	})
 	err = wf.SetQueryHandler(ctx, constants.AwaitingApprovalQueryHandlerName,
 		func() (msgs.AwaitingApprovalResponse, error) {
 			return state.AwaitingApproval(), nil
@@ -89,16 +331,58 @@ func (h *UserAccountOrchestrationHandler) Orchestration(ctx wf.Context, in msgs.
 		return errors.Join(errors.New(
 			fmt.Sprintf("unable to set %s QueryHandler", constants.UserDetailsQueryHandlerName)), err)
 	}
+	err = wf.SetQueryHandler(ctx, constants.AuditLogQueryHandlerName,
+		func(req msgs.AuditLogRequest) (msgs.AuditLogResponse, error) {
+			return state.AuditLog(req)
+		})
+	if err != nil {
+		return errors.Join(errors.New(
+			fmt.Sprintf("unable to set %s QueryHandler", constants.AuditLogQueryHandlerName)), err)
+	}
 	err = wf.Await(ctx, func() bool { return state.Deleted() || wf.GetInfo(ctx).GetContinueAsNewSuggested() })
 	if err != nil {
 		return errors.Join(errors.New("wait cancelled"), err)
 	}
+	// Updates still waiting on an activity, e.g. approve_permission on VerifyApprover, would be lost with this run
+	draining = true
+	err = wf.Await(ctx, func() bool { return wf.AllHandlersFinished(ctx) })
+	if err != nil {
+		return errors.Join(errors.New("wait cancelled"), err)
+	}
 	if wf.GetInfo(ctx).GetContinueAsNewSuggested() {
-		return wf.NewContinueAsNewError(ctx, h.Orchestration, msgs.UserAccountOrchestrationInput{
-			AwaitingApproval:    state.AwaitingApproval().Permissions,
-			DeletionRequestedAt: state.DeletionRequestedAt(),
-			Permissions:         state.Permissions().Permissions,
-		})
+		// Signals not yet received would be lost with this run
+		sig := msgs.RolePermissionsChangedSignal{}
+		for rolePermissionsChanged.ReceiveAsync(&sig) {
+			state.ApplyRolePermissions(sig)
+			sig = msgs.RolePermissionsChangedSignal{}
+		}
+		return wf.NewContinueAsNewError(ctx, h.Orchestration, state.Snapshot())
 	}
 	return nil
 }
+
+// drainingError refuses an update that arrives while the run finishes its in-flight updates before it continues as new
+// or completes. It is marked with constants.DrainingErrorType so that callers know to retry it.
+func drainingError() error {
+	return temporal.NewApplicationError("entity is finishing in-flight updates, retry shortly",
+		constants.DrainingErrorType)
+}
+
+// invalidRequest marks a validator's error with constants.InvalidRequestErrorType, or
+// constants.IllegalTransitionErrorType when the user's lifecycle state does not allow the update, so that callers can
+// tell a rejected update apart from one that was accepted and then failed. Creating a user that already exists is
+// marked with constants.UserAlreadyExistsErrorType and carries the user's status as its details.
+func invalidRequest(err error) error {
+	if err == nil {
+		return nil
+	}
+	var illegal *user_account_state.IllegalTransitionError
+	if errors.As(err, &illegal) {
+		return temporal.NewApplicationError(err.Error(), constants.IllegalTransitionErrorType)
+	}
+	var exists *user_account_state.UserAlreadyExistsError
+	if errors.As(err, &exists) {
+		return temporal.NewApplicationError(err.Error(), constants.UserAlreadyExistsErrorType, exists.Status)
+	}
+	return temporal.NewApplicationError(err.Error(), constants.InvalidRequestErrorType)
+}
@@ -1,16 +1,23 @@
 package orchestrations
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 	"github.com/temporal-sa/temporal-entity-lifecycle-go/constants"
 	"github.com/temporal-sa/temporal-entity-lifecycle-go/messages"
 	"github.com/temporal-sa/temporal-entity-lifecycle-go/orchestrations/activity_handler"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/permission_catalog_state"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/user_account_state"
 	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/converter"
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/testsuite"
 	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -28,12 +35,214 @@ func (s *UnitTestSuite) AfterTest(suiteName, testName string) {
 
 func (s *UnitTestSuite) SetupTest() {
 	s.env = s.NewTestWorkflowEnvironment()
+	s.env.RegisterActivityWithOptions(new(activity_handler.Handler).GetRole, activity.RegisterOptions{
+		Name: "GetRole",
+	})
+	s.env.RegisterActivityWithOptions(new(activity_handler.Handler).JoinRole, activity.RegisterOptions{
+		Name: "JoinRole",
+	})
+	s.env.RegisterActivityWithOptions(new(activity_handler.Handler).LeaveRole, activity.RegisterOptions{
+		Name: "LeaveRole",
+	})
+	s.env.RegisterActivityWithOptions(new(activity_handler.Handler).ListGrants, activity.RegisterOptions{
+		Name: "ListGrants",
+	})
+	s.env.RegisterActivityWithOptions(new(activity_handler.Handler).RevokeCampaignGrant, activity.RegisterOptions{
+		Name: "RevokeCampaignGrant",
+	})
+	s.env.RegisterActivityWithOptions(new(activity_handler.Handler).SendNotifications, activity.RegisterOptions{
+		Name: "SendNotifications",
+	})
+	s.env.RegisterActivityWithOptions(new(activity_handler.Handler).ValidatePermission, activity.RegisterOptions{
+		Name: "ValidatePermission",
+	})
+	s.env.RegisterActivityWithOptions(new(activity_handler.Handler).VerifyApprover, activity.RegisterOptions{
+		Name: "VerifyApprover",
+	})
+	s.env.RegisterActivityWithOptions(new(activity_handler.Handler).VerifyCampaignReviewer, activity.RegisterOptions{
+		Name: "VerifyCampaignReviewer",
+	})
+	// Every add_permission validates against the catalog; answer from the default definitions.
+	s.env.OnActivity(new(activity_handler.Handler).ValidatePermission, mock.Anything, mock.Anything).Return(
+		func(ctx context.Context, req messages.ValidatePermissionRequest) (messages.ValidatePermissionResponse, error) {
+			for _, d := range permission_catalog_state.DefaultDefinitions() {
+				if d.Name == req.Permission {
+					return messages.ValidatePermissionResponse{Definition: d, Valid: true}, nil
+				}
+			}
+			return messages.ValidatePermissionResponse{Valid: false}, nil
+		}).Maybe()
 }
 
 func TestUnitTestSuite(t *testing.T) {
 	suite.Run(t, new(UnitTestSuite))
 }
 
+// executeCreated starts a new user entity and creates it with permissions before anything else reaches it, the way the
+// web app does with update-with-start.
+func (s *UnitTestSuite) executeCreated(orchestration func(workflow.Context, messages.UserAccountOrchestrationInput) error, permissions ...string) {
+	create := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.CreateUserAccountUpdateHandlerName, "create", create,
+			messages.CreateUserAccountRequest{Permissions: permissions})
+	}, 0)
+	s.env.ExecuteWorkflow(orchestration, messages.UserAccountOrchestrationInput{})
+	s.Nil(create.Error())
+}
+
+func (s *UnitTestSuite) Test_Orchestration_ApprovalSLA_RemindEscalateAndDeny() {
+	h, err := New(WithApprovalSLA(messages.ApprovalSLA{
+		ApproverPool:           "approvers",
+		DenyAfter:              time.Hour * 72,
+		EscalateAfter:          time.Hour * 48,
+		EscalationApproverPool: "escalation_approvers",
+		RemindAfter:            time.Hour * 24,
+	}))
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	uc := &updateCallbacks{t: s.T()}
+	s.env.OnActivity(new(activity_handler.Handler).SendNotifications, mock.Anything, messages.SendNotificationsRequest{
+		ApproverPool:     "approvers",
+		NotificationType: constants.NotificationTypeApprovalReminder,
+		PermissionType:   constants.PermissionTypeReadFiles,
+		RequesterID:      "default-test-workflow-id",
+	}).Return(messages.SendNotificationsResponse{}, nil).Once()
+	s.env.OnActivity(new(activity_handler.Handler).SendNotifications, mock.Anything, messages.SendNotificationsRequest{
+		ApproverPool:     "escalation_approvers",
+		NotificationType: constants.NotificationTypeApprovalEscalation,
+		PermissionType:   constants.PermissionTypeReadFiles,
+		RequesterID:      "default-test-workflow-id",
+	}).Return(messages.SendNotificationsResponse{}, nil).Once()
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.AddUserPermissionUpdateHandlerName, "1", uc,
+			messages.AddUserPermissionRequest{
+				Permission: constants.PermissionTypeReadFiles,
+			})
+	}, time.Second*1)
+	escalated := messages.UserDetailsResponse{}
+	s.env.RegisterDelayedCallback(func() {
+		v, err := s.env.QueryWorkflow(constants.UserDetailsQueryHandlerName)
+		s.Nil(err)
+		s.Nil(v.Get(&escalated))
+	}, time.Hour*60)
+	s.executeCreated(h.Orchestration)
+	s.True(s.env.IsWorkflowCompleted())
+	s.Nil(uc.Error())
+	s.Len(escalated.PendingApprovals, 1)
+	s.True(escalated.PendingApprovals[0].Reminded)
+	s.True(escalated.PendingApprovals[0].Escalated)
+	v, err := s.env.QueryWorkflow(constants.UserDetailsQueryHandlerName)
+	s.Nil(err)
+	details := messages.UserDetailsResponse{}
+	err = v.Get(&details)
+	s.Nil(err)
+	s.Equal([]string{}, details.AwaitingApproval.Permissions)
+	s.Len(details.Rejections, 1)
+	s.Equal(constants.SystemActorID, details.Rejections[0].ApproverID)
+	s.Equal(constants.ApprovalDeadlineExceededReason, details.Rejections[0].Reason)
+}
+
+func (s *UnitTestSuite) Test_Orchestration_AuditLog() {
+	h, err := New()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	started := s.env.Now()
+	s.env.OnActivity(new(activity_handler.Handler).VerifyApprover, mock.Anything, messages.VerifyApproverRequest{
+		ApproverID: "bobsaget@temporal.io",
+		Permission: constants.PermissionTypeReadFiles,
+	}).Return(messages.VerifyApproverResponse{Verified: true}, nil)
+	s.env.OnActivity(new(activity_handler.Handler).VerifyApprover, mock.Anything, messages.VerifyApproverRequest{
+		ApproverID: "mallory@temporal.io",
+		Permission: constants.PermissionTypeReadFiles,
+	}).Return(messages.VerifyApproverResponse{Verified: false}, nil)
+	uc := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.CreateUserAccountUpdateHandlerName, "1", uc,
+			messages.CreateUserAccountRequest{RequestedBy: "bobsaget@temporal.io"})
+	}, time.Second*1)
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.AddUserPermissionUpdateHandlerName, "2", uc,
+			messages.AddUserPermissionRequest{
+				Permission: constants.PermissionTypeReadFiles,
+			})
+	}, time.Second*2)
+	unauthorized := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.ApproveUserPermissionUpdateHandlerName, "3", unauthorized,
+			messages.ApproveUserPermissionRequest{
+				ApproverID: "mallory@temporal.io",
+				Permission: constants.PermissionTypeReadFiles,
+			})
+	}, time.Second*3)
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.ApproveUserPermissionUpdateHandlerName, "4", uc,
+			messages.ApproveUserPermissionRequest{
+				ApproverID: "bobsaget@temporal.io",
+				Permission: constants.PermissionTypeReadFiles,
+			})
+	}, time.Second*4)
+	s.env.ExecuteWorkflow(h.Orchestration, messages.UserAccountOrchestrationInput{})
+	s.True(s.env.IsWorkflowCompleted())
+	s.Nil(uc.Error())
+	s.NotNil(unauthorized.Error())
+	v, err := s.env.QueryWorkflow(constants.AuditLogQueryHandlerName, messages.AuditLogRequest{Limit: 2})
+	s.Nil(err)
+	page := messages.AuditLogResponse{}
+	s.Nil(v.Get(&page))
+	s.Equal(4, page.Total)
+	s.Equal(2, page.NextOffset)
+	s.Len(page.Entries, 2)
+	s.Equal(constants.ApproveUserPermissionUpdateHandlerName, page.Entries[0].Action)
+	s.Equal("bobsaget@temporal.io", page.Entries[0].Actor)
+	s.Equal(constants.PermissionTypeReadFiles, page.Entries[0].Permission)
+	s.Equal(constants.AuditOutcomeSucceeded, page.Entries[0].Outcome)
+	s.Equal("1 of 1 approvals", page.Entries[0].Reason)
+	s.WithinDuration(started.Add(time.Second*4), page.Entries[0].At, 0)
+	s.Equal("mallory@temporal.io", page.Entries[1].Actor)
+	s.Equal(constants.AuditOutcomeFailed, page.Entries[1].Outcome)
+	s.Equal("mallory@temporal.io cannot grant permission read_files", page.Entries[1].Reason)
+	v, err = s.env.QueryWorkflow(constants.AuditLogQueryHandlerName, messages.AuditLogRequest{Limit: 2, Offset: 2})
+	s.Nil(err)
+	page = messages.AuditLogResponse{}
+	s.Nil(v.Get(&page))
+	s.Equal(0, page.NextOffset)
+	s.Len(page.Entries, 2)
+	s.Equal(constants.AddUserPermissionUpdateHandlerName, page.Entries[0].Action)
+	s.Equal("default-test-workflow-id", page.Entries[0].Actor)
+	s.Equal(constants.CreateUserAccountUpdateHandlerName, page.Entries[1].Action)
+	s.Equal("bobsaget@temporal.io", page.Entries[1].Actor)
+}
+
+func (s *UnitTestSuite) Test_Orchestration_AuditLog_CompactedAcrossContinueAsNew() {
+	h, err := New()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	// Only a run continued from an earlier one carries an audit log
+	s.env.SetContinuedExecutionRunID("previous-run")
+	input := messages.UserAccountOrchestrationInput{Status: constants.UserStatusActive}
+	for i := 0; i < 510; i++ {
+		input.AuditLog = append(input.AuditLog, messages.AuditEntry{
+			Action:  constants.UpdateUserProfileUpdateHandlerName,
+			Actor:   fmt.Sprintf("admin-%d", i),
+			Outcome: constants.AuditOutcomeSucceeded,
+		})
+	}
+	input.AuditLogCompacted = 7
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SetContinueAsNewSuggested(true)
+	}, time.Second*1)
+	s.env.ExecuteWorkflow(h.Orchestration, input)
+	s.True(s.env.IsWorkflowCompleted())
+	var continueAsNew *workflow.ContinueAsNewError
+	s.True(errors.As(s.env.GetWorkflowError(), &continueAsNew))
+	snapshot := messages.UserAccountOrchestrationInput{}
+	s.Nil(converter.GetDefaultDataConverter().FromPayloads(continueAsNew.Input, &snapshot))
+	s.Len(snapshot.AuditLog, 500)
+	s.Equal("admin-10", snapshot.AuditLog[0].Actor)
+	s.Equal("admin-509", snapshot.AuditLog[499].Actor)
+	s.Equal(17, snapshot.AuditLogCompacted)
+}
+
 func (s *UnitTestSuite) Test_Orchestration_HandleAddPermission() {
 	h, err := New()
 	s.Nil(err)
@@ -45,7 +254,7 @@ func (s *UnitTestSuite) Test_Orchestration_HandleAddPermission() {
 				Permission: constants.PermissionTypeReadFiles,
 			})
 	}, time.Second*1)
-	s.env.ExecuteWorkflow(h.Orchestration, messages.UserAccountOrchestrationInput{})
+	s.executeCreated(h.Orchestration)
 	s.True(s.env.IsWorkflowCompleted())
 	v, err := s.env.QueryWorkflow(constants.AwaitingApprovalQueryHandlerName)
 	s.Nil(err)
@@ -65,17 +274,21 @@ func (s *UnitTestSuite) Test_Orchestration_HandleApprovePermission() {
 	uc := &updateCallbacks{t: s.T()}
 	permissionsKey := temporal.NewSearchAttributeKeyKeywordList(constants.PermissionsSearchAttributeKey)
 	permissionsSearchAttrState1 := temporal.NewSearchAttributes(permissionsKey.ValueSet([]string{}))
-	s.env.OnUpsertTypedSearchAttributes(permissionsSearchAttrState1).Return(nil).Once()
+	// Created and then asked for read_files
+	s.env.OnUpsertTypedSearchAttributes(permissionsSearchAttrState1).Return(nil).Twice()
 	permissionsSearchAttrState2 := temporal.NewSearchAttributes(permissionsKey.ValueSet([]string{constants.PermissionTypeReadFiles}))
 	s.env.OnUpsertTypedSearchAttributes(permissionsSearchAttrState2).Return(nil).Once()
 	approvalsKey := temporal.NewSearchAttributeKeyKeywordList(constants.AwaitingApprovalSearchAttributeKey)
 	approvalsSearchAttrState1 := temporal.NewSearchAttributes(approvalsKey.ValueSet([]string{constants.PermissionTypeReadFiles}))
 	s.env.OnUpsertTypedSearchAttributes(approvalsSearchAttrState1).Return(nil).Once()
 	approvalsSearchAttrState2 := temporal.NewSearchAttributes(approvalsKey.ValueSet([]string{}))
-	s.env.OnUpsertTypedSearchAttributes(approvalsSearchAttrState2).Return(nil).Once()
-	s.env.RegisterActivityWithOptions(new(activity_handler.Handler).VerifyApprover, activity.RegisterOptions{
-		Name: "VerifyApprover",
-	})
+	// Created and then granted read_files
+	s.env.OnUpsertTypedSearchAttributes(approvalsSearchAttrState2).Return(nil).Twice()
+	statusKey := temporal.NewSearchAttributeKeyKeyword(constants.StatusSearchAttributeKey)
+	pendingSearchAttr := temporal.NewSearchAttributes(statusKey.ValueSet(constants.UserStatusPending))
+	s.env.OnUpsertTypedSearchAttributes(pendingSearchAttr).Return(nil).Once()
+	statusSearchAttr := temporal.NewSearchAttributes(statusKey.ValueSet(constants.UserStatusActive))
+	s.env.OnUpsertTypedSearchAttributes(statusSearchAttr).Return(nil).Once()
 	s.env.OnActivity(new(activity_handler.Handler).VerifyApprover, mock.Anything, messages.VerifyApproverRequest{
 		ApproverID: "bobsaget@temporal.io",
 		Permission: constants.PermissionTypeReadFiles,
@@ -93,7 +306,7 @@ func (s *UnitTestSuite) Test_Orchestration_HandleApprovePermission() {
 				ApproverID: "bobsaget@temporal.io",
 			})
 	}, time.Second*2)
-	s.env.ExecuteWorkflow(h.Orchestration, messages.UserAccountOrchestrationInput{})
+	s.executeCreated(h.Orchestration)
 	s.True(s.env.IsWorkflowCompleted())
 	v, err := s.env.QueryWorkflow(constants.PermissionsGrantedQueryHandlerName)
 	s.Nil(err)
@@ -101,19 +314,289 @@ func (s *UnitTestSuite) Test_Orchestration_HandleApprovePermission() {
 	err = v.Get(&granted)
 	s.Nil(err)
 	expected := messages.PermissionsGrantedResponse{
+		Direct:      []string{constants.PermissionTypeReadFiles},
 		Permissions: []string{constants.PermissionTypeReadFiles},
+		Status:      constants.UserStatusActive,
 	}
 	s.Equal(expected, granted)
 }
 
-func (s *UnitTestSuite) Test_Orchestration_HandleApprovePermission_UnauthorizedApprover() {
+func (s *UnitTestSuite) Test_Orchestration_HandleCreate_DuplicatePermissions() {
 	h, err := New()
 	s.Nil(err)
 	s.env.SetTestTimeout(time.Second * 5)
 	uc := &updateCallbacks{t: s.T()}
-	s.env.RegisterActivityWithOptions(new(activity_handler.Handler).VerifyApprover, activity.RegisterOptions{
-		Name: "VerifyApprover",
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.CreateUserAccountUpdateHandlerName, "1", uc,
+			messages.CreateUserAccountRequest{
+				Permissions: []string{
+					constants.PermissionTypeReadFiles,
+					constants.PermissionTypeApproveReadFiles,
+					constants.PermissionTypeApproveReadFiles,
+				},
+			})
+	}, time.Second*1)
+	s.env.ExecuteWorkflow(h.Orchestration, messages.UserAccountOrchestrationInput{})
+	s.True(s.env.IsWorkflowCompleted())
+	s.Nil(uc.Error())
+	v, err := s.env.QueryWorkflow(constants.UserDetailsQueryHandlerName)
+	s.Nil(err)
+	details := messages.UserDetailsResponse{}
+	err = v.Get(&details)
+	s.Nil(err)
+	s.Equal([]string{constants.PermissionTypeReadFiles, constants.PermissionTypeApproveReadFiles},
+		details.Permissions.Permissions)
+}
+
+func (s *UnitTestSuite) Test_Orchestration_HandleAddPermission_Concurrent() {
+	h, err := New(WithApprovalPolicies(map[string]messages.ApprovalPolicy{
+		constants.PermissionTypeReadFiles: {RequiredApprovals: 2},
+	}))
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	first := &updateCallbacks{t: s.T()}
+	second := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		// Both requests pass their validator while the catalog is consulted
+		s.env.UpdateWorkflow(constants.AddUserPermissionUpdateHandlerName, "1", first,
+			messages.AddUserPermissionRequest{
+				Permission: constants.PermissionTypeReadFiles,
+			})
+		s.env.UpdateWorkflow(constants.AddUserPermissionUpdateHandlerName, "2", second,
+			messages.AddUserPermissionRequest{
+				Permission: constants.PermissionTypeReadFiles,
+			})
+	}, time.Second*1)
+	s.executeCreated(h.Orchestration)
+	s.True(s.env.IsWorkflowCompleted())
+	s.False(first.Rejected())
+	s.False(second.Rejected())
+	// Whichever request resumes last finds the permission already awaiting approval
+	failed := first.Error()
+	if failed == nil {
+		failed = second.Error()
+	} else {
+		s.Nil(second.Error())
+	}
+	s.Equal("permission read_files already awaiting approval", errorMessage(failed))
+	v, err := s.env.QueryWorkflow(constants.UserDetailsQueryHandlerName)
+	s.Nil(err)
+	details := messages.UserDetailsResponse{}
+	s.Nil(v.Get(&details))
+	s.Equal([]string{constants.PermissionTypeReadFiles}, details.AwaitingApproval.Permissions)
+	s.Len(details.PendingApprovals, 1)
+}
+
+func (s *UnitTestSuite) Test_Orchestration_HandleAddPermission_NotInCatalog() {
+	h, err := New()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	uc := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.AddUserPermissionUpdateHandlerName, "1", uc,
+			messages.AddUserPermissionRequest{
+				Permission: "launch_missiles",
+			})
+	}, time.Second*1)
+	s.executeCreated(h.Orchestration)
+	s.True(s.env.IsWorkflowCompleted())
+	s.NotNil(uc.Error())
+	v, err := s.env.QueryWorkflow(constants.UserDetailsQueryHandlerName)
+	s.Nil(err)
+	details := messages.UserDetailsResponse{}
+	err = v.Get(&details)
+	s.Nil(err)
+	s.Empty(details.AwaitingApproval.Permissions)
+}
+
+func (s *UnitTestSuite) Test_Orchestration_HandleApprovePermission_Quorum() {
+	h, err := New(WithApprovalPolicies(map[string]messages.ApprovalPolicy{
+		constants.PermissionTypeGrantPermissions: {RequiredApprovals: 2},
+	}))
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	for _, approver := range []string{"bobsaget@temporal.io", "davecoulier@temporal.io"} {
+		s.env.OnActivity(new(activity_handler.Handler).VerifyApprover, mock.Anything, messages.VerifyApproverRequest{
+			ApproverID: approver,
+			Permission: constants.PermissionTypeGrantPermissions,
+		}).Return(messages.VerifyApproverResponse{Verified: true}, nil)
+	}
+	addCallbacks := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.AddUserPermissionUpdateHandlerName, "1", addCallbacks,
+			messages.AddUserPermissionRequest{
+				Permission: constants.PermissionTypeGrantPermissions,
+			})
+	}, time.Second*1)
+	firstApproval := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.ApproveUserPermissionUpdateHandlerName, "2", firstApproval,
+			messages.ApproveUserPermissionRequest{
+				ApproverID: "bobsaget@temporal.io",
+				Permission: constants.PermissionTypeGrantPermissions,
+			})
+	}, time.Second*2)
+	partiallyApproved := messages.UserDetailsResponse{}
+	s.env.RegisterDelayedCallback(func() {
+		v, err := s.env.QueryWorkflow(constants.UserDetailsQueryHandlerName)
+		s.Nil(err)
+		s.Nil(v.Get(&partiallyApproved))
+	}, time.Second*3)
+	duplicateApproval := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.ApproveUserPermissionUpdateHandlerName, "3", duplicateApproval,
+			messages.ApproveUserPermissionRequest{
+				ApproverID: "bobsaget@temporal.io",
+				Permission: constants.PermissionTypeGrantPermissions,
+			})
+	}, time.Second*4)
+	secondApproval := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.ApproveUserPermissionUpdateHandlerName, "4", secondApproval,
+			messages.ApproveUserPermissionRequest{
+				ApproverID: "davecoulier@temporal.io",
+				Permission: constants.PermissionTypeGrantPermissions,
+			})
+	}, time.Second*5)
+	s.executeCreated(h.Orchestration)
+	s.True(s.env.IsWorkflowCompleted())
+	s.Nil(addCallbacks.Error())
+	s.Nil(firstApproval.Error())
+	s.Nil(secondApproval.Error())
+	s.True(duplicateApproval.Rejected())
+	s.Equal("bobsaget@temporal.io has already approved permission grant_permissions",
+		errorMessage(duplicateApproval.Error()))
+	s.Len(partiallyApproved.PendingApprovals, 1)
+	s.Equal([]string{"bobsaget@temporal.io"}, partiallyApproved.PendingApprovals[0].Approvals)
+	s.Equal(2, partiallyApproved.PendingApprovals[0].RequiredApprovals)
+	s.Empty(partiallyApproved.Permissions.Permissions)
+	v, err := s.env.QueryWorkflow(constants.PermissionsGrantedQueryHandlerName)
+	s.Nil(err)
+	granted := messages.PermissionsGrantedResponse{}
+	err = v.Get(&granted)
+	s.Nil(err)
+	s.Equal([]string{constants.PermissionTypeGrantPermissions}, granted.Permissions)
+}
+
+func (s *UnitTestSuite) Test_Orchestration_HandleApprovePermission_QuorumCountsAuthority() {
+	h, err := New(WithApprovalPolicies(map[string]messages.ApprovalPolicy{
+		constants.PermissionTypeGrantPermissions: {RequiredApprovals: 2},
+	}))
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	for approver, onBehalfOf := range map[string]string{
+		"davecoulier@temporal.io": "",
+		"delegate1@temporal.io":   "admin@temporal.io",
+		"delegate2@temporal.io":   "admin@temporal.io",
+	} {
+		s.env.OnActivity(new(activity_handler.Handler).VerifyApprover, mock.Anything, messages.VerifyApproverRequest{
+			ApproverID: approver,
+			Permission: constants.PermissionTypeGrantPermissions,
+		}).Return(messages.VerifyApproverResponse{OnBehalfOf: onBehalfOf, Verified: true}, nil)
+	}
+	add := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.AddUserPermissionUpdateHandlerName, "1", add,
+			messages.AddUserPermissionRequest{
+				Permission: constants.PermissionTypeGrantPermissions,
+			})
+	}, time.Second*1)
+	approvals := make([]*updateCallbacks, 0)
+	for i, approver := range []string{"delegate1@temporal.io", "delegate2@temporal.io", "admin@temporal.io",
+		"davecoulier@temporal.io"} {
+		uc := &updateCallbacks{t: s.T()}
+		approvals = append(approvals, uc)
+		s.env.RegisterDelayedCallback(func() {
+			s.env.UpdateWorkflow(constants.ApproveUserPermissionUpdateHandlerName, fmt.Sprint(i+2), uc,
+				messages.ApproveUserPermissionRequest{
+					ApproverID: approver,
+					Permission: constants.PermissionTypeGrantPermissions,
+				})
+		}, time.Second*time.Duration(i+2))
+	}
+	s.executeCreated(h.Orchestration)
+	s.True(s.env.IsWorkflowCompleted())
+	s.Nil(add.Error())
+	s.Nil(approvals[0].Error())
+	// Another delegate of the same delegator brings no authority of their own
+	s.Equal("admin@temporal.io has already approved permission grant_permissions",
+		errorMessage(approvals[1].Error()))
+	// Nor does the delegator whose authority was already exercised
+	s.True(approvals[2].Rejected())
+	s.Equal("admin@temporal.io has already approved permission grant_permissions",
+		errorMessage(approvals[2].Error()))
+	s.Nil(approvals[3].Error())
+	s.True(approvals[3].Result().(messages.ApproveUserPermissionResponse).Granted)
+}
+
+func (s *UnitTestSuite) Test_Orchestration_HandleApprovePermission_SelfApproval() {
+	h, err := New()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	uc := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.AddUserPermissionUpdateHandlerName, "1", uc,
+			messages.AddUserPermissionRequest{
+				Permission: constants.PermissionTypeReadFiles,
+			})
+	}, time.Second*1)
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.ApproveUserPermissionUpdateHandlerName, "2", uc,
+			messages.ApproveUserPermissionRequest{
+				ApproverID: "default-test-workflow-id",
+				Permission: constants.PermissionTypeReadFiles,
+			})
+	}, time.Second*2)
+	s.executeCreated(h.Orchestration)
+	s.True(s.env.IsWorkflowCompleted())
+	s.True(uc.Rejected())
+	s.Equal("default-test-workflow-id cannot approve their own permission request", errorMessage(uc.Error()))
+}
+
+func (s *UnitTestSuite) Test_Orchestration_HandleApprovePermission_SeparationOfDuties() {
+	h, err := New(WithSeparationOfDutiesRules([]messages.SeparationOfDutiesRule{{
+		ConflictsWith: constants.PermissionTypeReadFiles,
+		Name:          "approvers-do-not-read",
+		Permission:    constants.PermissionTypeGrantPermissions,
+	}}))
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	approveCallbacks := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.ApproveUserPermissionUpdateHandlerName, "1", approveCallbacks,
+			messages.ApproveUserPermissionRequest{
+				ApproverID: "bobsaget@temporal.io",
+				Permission: constants.PermissionTypeReadFiles,
+			})
+	}, time.Second*1)
+	rejectedAddCallbacks := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.AddUserPermissionUpdateHandlerName, "2", rejectedAddCallbacks,
+			messages.AddUserPermissionRequest{
+				Permission: constants.PermissionTypeReadFiles,
+			})
+	}, time.Second*2)
+	// read_files was requested before grant_permissions was granted
+	s.env.SetContinuedExecutionRunID("previous-run")
+	s.env.ExecuteWorkflow(h.Orchestration, messages.UserAccountOrchestrationInput{
+		AwaitingApproval: []string{constants.PermissionTypeReadFiles},
+		Permissions:      []string{constants.PermissionTypeGrantPermissions},
+		Status:           constants.UserStatusActive,
 	})
+	s.True(s.env.IsWorkflowCompleted())
+	expected := "read_files conflicts with held permission grant_permissions under separation of duties rule " +
+		"\"approvers-do-not-read\""
+	s.True(approveCallbacks.Rejected())
+	s.Equal(expected, errorMessage(approveCallbacks.Error()))
+	s.True(rejectedAddCallbacks.Rejected())
+	s.Equal(expected, errorMessage(rejectedAddCallbacks.Error()))
+}
+
+func (s *UnitTestSuite) Test_Orchestration_HandleApprovePermission_UnauthorizedApprover() {
+	h, err := New()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	uc := &updateCallbacks{t: s.T()}
 	s.env.OnActivity(new(activity_handler.Handler).VerifyApprover, mock.Anything, messages.VerifyApproverRequest{
 		ApproverID: "bobsaget@temporal.io",
 		Permission: constants.PermissionTypeReadFiles,
@@ -131,12 +614,104 @@ func (s *UnitTestSuite) Test_Orchestration_HandleApprovePermission_UnauthorizedA
 				ApproverID: "bobsaget@temporal.io",
 			})
 	}, time.Second*2)
-	s.env.ExecuteWorkflow(h.Orchestration, messages.UserAccountOrchestrationInput{})
+	s.executeCreated(h.Orchestration)
 	s.True(s.env.IsWorkflowCompleted())
 	s.Error(uc.Error())
 	s.Equal("bobsaget@temporal.io cannot grant permission read_files", uc.Error().Error())
 }
 
+func (s *UnitTestSuite) Test_Orchestration_HandleApprovePermission_ExpiresAfter() {
+	h, err := New()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	uc := &updateCallbacks{t: s.T()}
+	s.env.OnActivity(new(activity_handler.Handler).VerifyApprover, mock.Anything, messages.VerifyApproverRequest{
+		ApproverID: "bobsaget@temporal.io",
+		Permission: constants.PermissionTypeReadFiles,
+	}).Return(messages.VerifyApproverResponse{Verified: true}, nil)
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.AddUserPermissionUpdateHandlerName, "1", uc,
+			messages.AddUserPermissionRequest{
+				Permission: constants.PermissionTypeReadFiles,
+			})
+	}, time.Second*1)
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.ApproveUserPermissionUpdateHandlerName, "2", uc,
+			messages.ApproveUserPermissionRequest{
+				ApproverID:   "bobsaget@temporal.io",
+				ExpiresAfter: time.Hour,
+				Permission:   constants.PermissionTypeReadFiles,
+			})
+	}, time.Second*2)
+	grantedBeforeExpiry := messages.PermissionsGrantedResponse{}
+	s.env.RegisterDelayedCallback(func() {
+		v, err := s.env.QueryWorkflow(constants.PermissionsGrantedQueryHandlerName)
+		s.Nil(err)
+		s.Nil(v.Get(&grantedBeforeExpiry))
+	}, time.Minute*30)
+	s.executeCreated(h.Orchestration)
+	s.True(s.env.IsWorkflowCompleted())
+	s.Nil(uc.Error())
+	s.Equal([]string{constants.PermissionTypeReadFiles}, grantedBeforeExpiry.Permissions)
+	v, err := s.env.QueryWorkflow(constants.UserDetailsQueryHandlerName)
+	s.Nil(err)
+	details := messages.UserDetailsResponse{}
+	err = v.Get(&details)
+	s.Nil(err)
+	s.Equal([]string{}, details.Permissions.Permissions)
+	s.Empty(details.PermissionExpirations)
+}
+
+func (s *UnitTestSuite) Test_Orchestration_HandleApprovePermission_ExpiresAfterShortestOfQuorum() {
+	h, err := New(WithApprovalPolicies(map[string]messages.ApprovalPolicy{
+		constants.PermissionTypeGrantPermissions: {RequiredApprovals: 2},
+	}))
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	started := s.env.Now()
+	for _, approver := range []string{"bobsaget@temporal.io", "davecoulier@temporal.io"} {
+		s.env.OnActivity(new(activity_handler.Handler).VerifyApprover, mock.Anything, messages.VerifyApproverRequest{
+			ApproverID: approver,
+			Permission: constants.PermissionTypeGrantPermissions,
+		}).Return(messages.VerifyApproverResponse{Verified: true}, nil)
+	}
+	uc := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.AddUserPermissionUpdateHandlerName, "1", uc,
+			messages.AddUserPermissionRequest{
+				Permission: constants.PermissionTypeGrantPermissions,
+			})
+	}, time.Second*1)
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.ApproveUserPermissionUpdateHandlerName, "2", uc,
+			messages.ApproveUserPermissionRequest{
+				ApproverID:   "bobsaget@temporal.io",
+				ExpiresAfter: time.Hour,
+				Permission:   constants.PermissionTypeGrantPermissions,
+			})
+	}, time.Second*2)
+	// The final approver asks for no bound, which must not lift the first approver's
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.ApproveUserPermissionUpdateHandlerName, "3", uc,
+			messages.ApproveUserPermissionRequest{
+				ApproverID: "davecoulier@temporal.io",
+				Permission: constants.PermissionTypeGrantPermissions,
+			})
+	}, time.Second*3)
+	details := messages.UserDetailsResponse{}
+	s.env.RegisterDelayedCallback(func() {
+		v, err := s.env.QueryWorkflow(constants.UserDetailsQueryHandlerName)
+		s.Nil(err)
+		s.Nil(v.Get(&details))
+	}, time.Second*4)
+	s.executeCreated(h.Orchestration)
+	s.True(s.env.IsWorkflowCompleted())
+	s.Nil(uc.Error())
+	s.Equal([]string{constants.PermissionTypeGrantPermissions}, details.Permissions.Permissions)
+	s.Len(details.PermissionExpirations, 1)
+	s.WithinDuration(started.Add(time.Second*3+time.Hour), details.PermissionExpirations[0].ExpiresAt, 0)
+}
+
 func (s *UnitTestSuite) Test_Orchestration_HandleCreateUpdate() {
 	h, err := New()
 	s.Nil(err)
@@ -145,6 +720,11 @@ func (s *UnitTestSuite) Test_Orchestration_HandleCreateUpdate() {
 	attributeKey := temporal.NewSearchAttributeKeyKeywordList(constants.PermissionsSearchAttributeKey)
 	attributes := temporal.NewSearchAttributes(attributeKey.ValueSet([]string{constants.PermissionTypeGrantPermissions}))
 	s.env.OnUpsertTypedSearchAttributes(attributes).Return(nil).Once()
+	statusKey := temporal.NewSearchAttributeKeyKeyword(constants.StatusSearchAttributeKey)
+	pendingAttributes := temporal.NewSearchAttributes(statusKey.ValueSet(constants.UserStatusPending))
+	s.env.OnUpsertTypedSearchAttributes(pendingAttributes).Return(nil).Once()
+	statusAttributes := temporal.NewSearchAttributes(statusKey.ValueSet(constants.UserStatusActive))
+	s.env.OnUpsertTypedSearchAttributes(statusAttributes).Return(nil).Once()
 	s.env.RegisterDelayedCallback(func() {
 		s.env.UpdateWorkflow(constants.CreateUserAccountUpdateHandlerName, "1", uc,
 			messages.CreateUserAccountRequest{
@@ -159,7 +739,9 @@ func (s *UnitTestSuite) Test_Orchestration_HandleCreateUpdate() {
 	err = v.Get(&granted)
 	s.Nil(err)
 	expected := messages.PermissionsGrantedResponse{
+		Direct:      []string{constants.PermissionTypeGrantPermissions},
 		Permissions: []string{constants.PermissionTypeGrantPermissions},
+		Status:      constants.UserStatusActive,
 	}
 	s.Equal(expected, granted)
 }
@@ -179,43 +761,1496 @@ func (s *UnitTestSuite) Test_Orchestration_HandleDeleteUpdate() {
 	s.Nil(err)
 }
 
-func (s *UnitTestSuite) Test_Orchestration_HandleUndoDeleteUpdate() {
-	// In the event that deletion is undone within the soft-delete time window the workflow should revert to it's normal
-	// behavior: unending execution. In tests this looks like a timeout since the workflow DOES NOT complete within the
-	// test timeout window.
+func (s *UnitTestSuite) Test_Orchestration_HandleRejectPermission() {
 	h, err := New()
 	s.Nil(err)
 	s.env.SetTestTimeout(time.Second * 5)
 	uc := &updateCallbacks{t: s.T()}
+	s.env.OnActivity(new(activity_handler.Handler).VerifyApprover, mock.Anything, messages.VerifyApproverRequest{
+		ApproverID: "bobsaget@temporal.io",
+		Permission: constants.PermissionTypeReadFiles,
+	}).Return(messages.VerifyApproverResponse{Verified: true}, nil)
 	s.env.RegisterDelayedCallback(func() {
-		s.env.UpdateWorkflow(constants.DeleteUserAccountUpdateHandlerName, "1", uc,
-			messages.DeleteUserAccountRequest{})
-	}, time.Second*1)
-	s.env.RegisterDelayedCallback(func() {
-		// Want to verify that this test works? Comment out this callback and the test will fail.
+		s.env.UpdateWorkflow(constants.AddUserPermissionUpdateHandlerName, "1", uc,
+			messages.AddUserPermissionRequest{
+				Permission: constants.PermissionTypeReadFiles,
+			})
+	}, time.Second*1)
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.RejectUserPermissionUpdateHandlerName, "2", uc,
+			messages.RejectUserPermissionRequest{
+				ApproverID: "bobsaget@temporal.io",
+				Permission: constants.PermissionTypeReadFiles,
+				Reason:     "not needed for current role",
+			})
+	}, time.Second*2)
+	s.executeCreated(h.Orchestration)
+	s.True(s.env.IsWorkflowCompleted())
+	s.Nil(uc.Error())
+	v, err := s.env.QueryWorkflow(constants.UserDetailsQueryHandlerName)
+	s.Nil(err)
+	details := messages.UserDetailsResponse{}
+	err = v.Get(&details)
+	s.Nil(err)
+	s.Equal([]string{}, details.AwaitingApproval.Permissions)
+	s.Len(details.Rejections, 1)
+	s.Equal("bobsaget@temporal.io", details.Rejections[0].ApproverID)
+	s.Equal(constants.PermissionTypeReadFiles, details.Rejections[0].Permission)
+	s.Equal("not needed for current role", details.Rejections[0].Reason)
+}
+
+func (s *UnitTestSuite) Test_Orchestration_HandleRevokePermission() {
+	h, err := New()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	uc := &updateCallbacks{t: s.T()}
+	s.env.OnActivity(new(activity_handler.Handler).VerifyApprover, mock.Anything, messages.VerifyApproverRequest{
+		ApproverID: "bobsaget@temporal.io",
+		Permission: constants.PermissionTypeReadFiles,
+	}).Return(messages.VerifyApproverResponse{Verified: true}, nil)
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.CreateUserAccountUpdateHandlerName, "1", uc,
+			messages.CreateUserAccountRequest{
+				Permissions: []string{constants.PermissionTypeReadFiles},
+			})
+	}, time.Second*1)
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.RevokeUserPermissionUpdateHandlerName, "2", uc,
+			messages.RevokeUserPermissionRequest{
+				Permission: constants.PermissionTypeReadFiles,
+				ApproverID: "bobsaget@temporal.io",
+			})
+	}, time.Second*2)
+	s.env.ExecuteWorkflow(h.Orchestration, messages.UserAccountOrchestrationInput{})
+	s.True(s.env.IsWorkflowCompleted())
+	s.Nil(uc.Error())
+	v, err := s.env.QueryWorkflow(constants.PermissionsGrantedQueryHandlerName)
+	s.Nil(err)
+	granted := messages.PermissionsGrantedResponse{}
+	err = v.Get(&granted)
+	s.Nil(err)
+	expected := messages.PermissionsGrantedResponse{
+		Direct:      []string{},
+		Permissions: []string{},
+		Status:      constants.UserStatusActive,
+	}
+	s.Equal(expected, granted)
+}
+
+func (s *UnitTestSuite) Test_Orchestration_HandleRevokePermission_Concurrent() {
+	h, err := New()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	// Both revocations pass their validator while the approver is being verified
+	s.env.OnActivity(new(activity_handler.Handler).VerifyApprover, mock.Anything, messages.VerifyApproverRequest{
+		ApproverID: "bobsaget@temporal.io",
+		Permission: constants.PermissionTypeReadFiles,
+	}).After(time.Minute).Return(messages.VerifyApproverResponse{Verified: true}, nil)
+	create := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.CreateUserAccountUpdateHandlerName, "1", create,
+			messages.CreateUserAccountRequest{
+				Permissions: []string{constants.PermissionTypeReadFiles},
+			})
+	}, time.Second*1)
+	first := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.RevokeUserPermissionUpdateHandlerName, "2", first,
+			messages.RevokeUserPermissionRequest{
+				ApproverID: "bobsaget@temporal.io",
+				Permission: constants.PermissionTypeReadFiles,
+			})
+	}, time.Second*2)
+	second := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.RevokeUserPermissionUpdateHandlerName, "3", second,
+			messages.RevokeUserPermissionRequest{
+				ApproverID: "bobsaget@temporal.io",
+				Permission: constants.PermissionTypeReadFiles,
+			})
+	}, time.Second*3)
+	page := messages.AuditLogResponse{}
+	s.env.RegisterDelayedCallback(func() {
+		v, err := s.env.QueryWorkflow(constants.AuditLogQueryHandlerName, messages.AuditLogRequest{})
+		s.Nil(err)
+		s.Nil(v.Get(&page))
+	}, time.Minute*2)
+	s.env.ExecuteWorkflow(h.Orchestration, messages.UserAccountOrchestrationInput{})
+	s.True(s.env.IsWorkflowCompleted())
+	s.Nil(create.Error())
+	s.False(first.Rejected())
+	s.False(second.Rejected())
+	// Whichever revocation resumes last finds the permission already gone
+	failed := first.Error()
+	if failed == nil {
+		failed = second.Error()
+	} else {
+		s.Nil(second.Error())
+	}
+	s.Equal("permission not found", errorMessage(failed))
+	outcomes := make([]string, 0)
+	for _, entry := range page.Entries {
+		if entry.Action == constants.RevokeUserPermissionUpdateHandlerName {
+			outcomes = append(outcomes, entry.Outcome)
+		}
+	}
+	s.ElementsMatch([]string{constants.AuditOutcomeSucceeded, constants.AuditOutcomeFailed}, outcomes)
+}
+
+func (s *UnitTestSuite) Test_Orchestration_HandleRevokePermission_UnauthorizedApprover() {
+	h, err := New()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	uc := &updateCallbacks{t: s.T()}
+	s.env.OnActivity(new(activity_handler.Handler).VerifyApprover, mock.Anything, messages.VerifyApproverRequest{
+		ApproverID: "bobsaget@temporal.io",
+		Permission: constants.PermissionTypeReadFiles,
+	}).Return(messages.VerifyApproverResponse{Verified: false}, nil)
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.CreateUserAccountUpdateHandlerName, "1", uc,
+			messages.CreateUserAccountRequest{
+				Permissions: []string{constants.PermissionTypeReadFiles},
+			})
+	}, time.Second*1)
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.RevokeUserPermissionUpdateHandlerName, "2", uc,
+			messages.RevokeUserPermissionRequest{
+				Permission: constants.PermissionTypeReadFiles,
+				ApproverID: "bobsaget@temporal.io",
+			})
+	}, time.Second*2)
+	s.env.ExecuteWorkflow(h.Orchestration, messages.UserAccountOrchestrationInput{})
+	s.True(s.env.IsWorkflowCompleted())
+	s.Error(uc.Error())
+	s.Equal("bobsaget@temporal.io cannot revoke permission read_files", uc.Error().Error())
+}
+
+func (s *UnitTestSuite) Test_Orchestration_HandleRevokePermission_Campaign() {
+	h, err := New()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	s.env.OnActivity(new(activity_handler.Handler).VerifyCampaignReviewer, mock.Anything,
+		messages.VerifyCampaignReviewerRequest{
+			CampaignID: "campaign:q3",
+			Permission: constants.PermissionTypeReadFiles,
+			ReviewerID: "manager@temporal.io",
+			UserID:     "default-test-workflow-id",
+		}).Return(messages.VerifyCampaignReviewerResponse{Verified: true}, nil).Once()
+	uc := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.RevokeUserPermissionUpdateHandlerName, "1", uc,
+			messages.RevokeUserPermissionRequest{
+				ApproverID: "manager@temporal.io",
+				CampaignID: "campaign:q3",
+				Permission: constants.PermissionTypeReadFiles,
+				Reason:     "moved teams",
+			})
+	}, time.Second*1)
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SetContinueAsNewSuggested(true)
+	}, time.Second*2)
+	s.executeCreated(h.Orchestration, constants.PermissionTypeReadFiles)
+	s.True(s.env.IsWorkflowCompleted())
+	s.Nil(uc.Error())
+	v, err := s.env.QueryWorkflow(constants.AuditLogQueryHandlerName, messages.AuditLogRequest{})
+	s.Nil(err)
+	page := messages.AuditLogResponse{}
+	s.Nil(v.Get(&page))
+	s.Equal("manager@temporal.io", page.Entries[0].Actor)
+	s.Equal("moved teams", page.Entries[0].Reason)
+	s.Equal(constants.AuditOutcomeSucceeded, page.Entries[0].Outcome)
+}
+
+func (s *UnitTestSuite) Test_Orchestration_HandleRevokePermission_UnknownCampaign() {
+	h, err := New()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	s.env.OnActivity(new(activity_handler.Handler).VerifyCampaignReviewer, mock.Anything,
+		messages.VerifyCampaignReviewerRequest{
+			CampaignID: "campaign:made-up",
+			Permission: constants.PermissionTypeReadFiles,
+			ReviewerID: "mallory@temporal.io",
+			UserID:     "default-test-workflow-id",
+		}).Return(messages.VerifyCampaignReviewerResponse{Verified: false}, nil).Once()
+	uc := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.RevokeUserPermissionUpdateHandlerName, "1", uc,
+			messages.RevokeUserPermissionRequest{
+				ApproverID: "mallory@temporal.io",
+				CampaignID: "campaign:made-up",
+				Permission: constants.PermissionTypeReadFiles,
+			})
+	}, time.Second*1)
+	s.executeCreated(h.Orchestration, constants.PermissionTypeReadFiles)
+	s.True(s.env.IsWorkflowCompleted())
+	s.Equal("mallory@temporal.io cannot revoke permission read_files", errorMessage(uc.Error()))
+	v, err := s.env.QueryWorkflow(constants.PermissionsGrantedQueryHandlerName)
+	s.Nil(err)
+	granted := messages.PermissionsGrantedResponse{}
+	s.Nil(v.Get(&granted))
+	s.Equal([]string{constants.PermissionTypeReadFiles}, granted.Direct)
+}
+
+func (s *UnitTestSuite) Test_Orchestration_HandleUndoDeleteUpdate() {
+	// In the event that deletion is undone within the soft-delete time window the workflow should revert to it's normal
+	// behavior: unending execution. In tests this looks like a timeout since the workflow DOES NOT complete within the
+	// test timeout window.
+	h, err := New()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	uc := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.DeleteUserAccountUpdateHandlerName, "1", uc,
+			messages.DeleteUserAccountRequest{})
+	}, time.Second*1)
+	s.env.RegisterDelayedCallback(func() {
+		// Want to verify that this test works? Comment out this callback and the test will fail.
 		s.env.UpdateWorkflow(constants.UndoDeleteUserAccountUpdateHandlerName, "1", uc,
 			messages.UndoDeleteUserAccountRequest{})
 	}, time.Second*2)
 	s.env.ExecuteWorkflow(h.Orchestration, messages.UserAccountOrchestrationInput{})
 	s.True(s.env.IsWorkflowCompleted())
-	err = s.env.GetWorkflowError()
-	s.Error(err)
-	s.IsTypef(&temporal.TimeoutError{}, errors.Unwrap(err), "")
+	err = s.env.GetWorkflowError()
+	s.Error(err)
+	s.IsTypef(&temporal.TimeoutError{}, errors.Unwrap(err), "")
+}
+
+func (s *UnitTestSuite) Test_Orchestration_Lifecycle_RequiresCreate() {
+	h, err := New()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	addBeforeCreate := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.AddUserPermissionUpdateHandlerName, "1", addBeforeCreate,
+			messages.AddUserPermissionRequest{
+				Permission: constants.PermissionTypeReadFiles,
+			})
+	}, time.Second*1)
+	pending := messages.UserDetailsResponse{}
+	s.env.RegisterDelayedCallback(func() {
+		v, err := s.env.QueryWorkflow(constants.UserDetailsQueryHandlerName)
+		s.Nil(err)
+		s.Nil(v.Get(&pending))
+	}, time.Second*2)
+	create := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.CreateUserAccountUpdateHandlerName, "3", create,
+			messages.CreateUserAccountRequest{})
+	}, time.Second*3)
+	createAgain := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.CreateUserAccountUpdateHandlerName, "4", createAgain,
+			messages.CreateUserAccountRequest{})
+	}, time.Second*4)
+	add := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.AddUserPermissionUpdateHandlerName, "5", add,
+			messages.AddUserPermissionRequest{
+				Permission: constants.PermissionTypeReadFiles,
+			})
+	}, time.Second*5)
+	s.env.ExecuteWorkflow(h.Orchestration, messages.UserAccountOrchestrationInput{})
+	s.True(s.env.IsWorkflowCompleted())
+	s.Equal(constants.UserStatusPending, pending.Status)
+	s.True(addBeforeCreate.Rejected())
+	s.Equal("add_permission not allowed while user is pending", errorMessage(addBeforeCreate.Error()))
+	var appErr *temporal.ApplicationError
+	s.True(errors.As(addBeforeCreate.Error(), &appErr))
+	s.Equal(constants.IllegalTransitionErrorType, appErr.Type())
+	s.Nil(create.Error())
+	s.True(createAgain.Rejected())
+	s.Equal("user already exists and is active", errorMessage(createAgain.Error()))
+	s.True(errors.As(createAgain.Error(), &appErr))
+	s.Equal(constants.UserAlreadyExistsErrorType, appErr.Type())
+	status := ""
+	s.Nil(appErr.Details(&status))
+	s.Equal(constants.UserStatusActive, status)
+	s.Nil(add.Error())
+	v, err := s.env.QueryWorkflow(constants.UserDetailsQueryHandlerName)
+	s.Nil(err)
+	details := messages.UserDetailsResponse{}
+	s.Nil(v.Get(&details))
+	s.Equal(constants.UserStatusActive, details.Status)
+}
+
+func (s *UnitTestSuite) Test_Orchestration_Lifecycle_StartsPending() {
+	h, err := New()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	s.env.ExecuteWorkflow(h.Orchestration, messages.UserAccountOrchestrationInput{
+		Permissions:   []string{constants.PermissionTypeGrantPermissions},
+		SchemaVersion: user_account_state.SnapshotSchemaVersion,
+		Status:        constants.UserStatusActive,
+	})
+	s.True(s.env.IsWorkflowCompleted())
+	s.ErrorContains(s.env.GetWorkflowError(), "new users start pending, not active")
+}
+
+func (s *UnitTestSuite) Test_Orchestration_Lifecycle_StartsPendingWithoutSchemaVersion() {
+	h, err := New()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	s.env.ExecuteWorkflow(h.Orchestration, messages.UserAccountOrchestrationInput{
+		Permissions: []string{constants.PermissionTypeGrantPermissions},
+		Status:      constants.UserStatusActive,
+	})
+	s.True(s.env.IsWorkflowCompleted())
+	s.ErrorContains(s.env.GetWorkflowError(), "new users start pending, not active")
+}
+
+func (s *UnitTestSuite) Test_Orchestration_Lifecycle_StartsBlank() {
+	h, err := New()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	s.env.ExecuteWorkflow(h.Orchestration, messages.UserAccountOrchestrationInput{
+		Permissions: []string{constants.PermissionTypeGrantPermissions},
+	})
+	s.True(s.env.IsWorkflowCompleted())
+	s.ErrorContains(s.env.GetWorkflowError(), "new users start blank and are set up through the create update")
+}
+
+func (s *UnitTestSuite) Test_Orchestration_Lifecycle_UndoDeleteRestoresSuspension() {
+	h, err := New()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	suspend := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.SuspendUserAccountUpdateHandlerName, "1", suspend,
+			messages.SuspendUserAccountRequest{Reason: "under investigation"})
+	}, time.Second*1)
+	deletion := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.DeleteUserAccountUpdateHandlerName, "2", deletion,
+			messages.DeleteUserAccountRequest{})
+	}, time.Second*2)
+	pendingDeletion := messages.UserDetailsResponse{}
+	s.env.RegisterDelayedCallback(func() {
+		v, err := s.env.QueryWorkflow(constants.UserDetailsQueryHandlerName)
+		s.Nil(err)
+		s.Nil(v.Get(&pendingDeletion))
+	}, time.Second*3)
+	suspendWhileDeleting := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.SuspendUserAccountUpdateHandlerName, "4", suspendWhileDeleting,
+			messages.SuspendUserAccountRequest{})
+	}, time.Second*4)
+	undo := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.UndoDeleteUserAccountUpdateHandlerName, "5", undo,
+			messages.UndoDeleteUserAccountRequest{})
+	}, time.Second*5)
+	s.executeCreated(h.Orchestration)
+	s.True(s.env.IsWorkflowCompleted())
+	s.Nil(suspend.Error())
+	s.Nil(deletion.Error())
+	s.Equal(constants.UserStatusPendingDeletion, pendingDeletion.Status)
+	s.True(suspendWhileDeleting.Rejected())
+	s.Equal("suspend not allowed while user is pending_deletion", errorMessage(suspendWhileDeleting.Error()))
+	s.Nil(undo.Error())
+	v, err := s.env.QueryWorkflow(constants.UserDetailsQueryHandlerName)
+	s.Nil(err)
+	details := messages.UserDetailsResponse{}
+	s.Nil(v.Get(&details))
+	s.Equal(constants.UserStatusSuspended, details.Status)
+	s.Equal("under investigation", details.Suspension.Reason)
+}
+
+func (s *UnitTestSuite) Test_Orchestration_ReplayHistory() {
+	oh, err := New()
+	s.Nil(err)
+	r := worker.NewWorkflowReplayer()
+	r.RegisterWorkflow(oh.Orchestration)
+	err = r.ReplayWorkflowHistoryFromJSONFile(s.GetLogger(), "../fixtures/event_history.json")
+	s.Nil(err)
+}
+
+// The history was recorded by a worker that reminded approvers after a day; this one has no approval SLA at all
+func (s *UnitTestSuite) Test_Orchestration_ReplayHistory_ApprovalSLAChanged() {
+	oh, err := New()
+	s.Nil(err)
+	r := worker.NewWorkflowReplayer()
+	r.RegisterWorkflow(oh.Orchestration)
+	err = r.ReplayWorkflowHistoryFromJSONFile(s.GetLogger(), "../fixtures/event_history_approval_sla.json")
+	s.Nil(err)
+}
+
+func (s *UnitTestSuite) Test_Orchestration_HandleDelete_UndoWindow() {
+	h, err := New(WithDeletionPolicy(messages.DeletionPolicy{
+		DefaultUndoWindow: time.Hour,
+		MaxUndoWindow:     time.Hour * 24,
+		MinUndoWindow:     time.Minute * 10,
+	}))
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	started := s.env.Now()
+	tooLong := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.DeleteUserAccountUpdateHandlerName, "1", tooLong,
+			messages.DeleteUserAccountRequest{UndoWindow: time.Hour * 48})
+	}, time.Second*1)
+	uc := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.DeleteUserAccountUpdateHandlerName, "2", uc,
+			messages.DeleteUserAccountRequest{UndoWindow: time.Hour * 2})
+	}, time.Second*2)
+	requested := messages.UserDetailsResponse{}
+	s.env.RegisterDelayedCallback(func() {
+		v, err := s.env.QueryWorkflow(constants.UserDetailsQueryHandlerName)
+		s.Nil(err)
+		s.Nil(v.Get(&requested))
+	}, time.Second*3)
+	s.env.ExecuteWorkflow(h.Orchestration, messages.UserAccountOrchestrationInput{})
+	s.True(s.env.IsWorkflowCompleted())
+	s.Nil(uc.Error())
+	s.True(tooLong.Rejected())
+	s.Equal("undo window 48h0m0s is longer than the maximum of 24h0m0s", errorMessage(tooLong.Error()))
+	s.True(requested.DeletionRequested)
+	s.Equal(time.Hour*2, requested.DeletionUndoWindow)
+	s.Equal(requested.DeletionRequestedAt.Add(time.Hour*2), requested.DeletionScheduledFor)
+	s.Equal(started.Add(time.Second*2+time.Hour*2), s.env.Now())
+}
+
+func (s *UnitTestSuite) Test_Orchestration_HandleDelete_UndoWindowResumedFromSnapshot() {
+	h, err := New(WithDeletionPolicy(messages.DeletionPolicy{DefaultUndoWindow: time.Hour}))
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	started := s.env.Now()
+	s.env.SetContinuedExecutionRunID("previous-run")
+	s.env.ExecuteWorkflow(h.Orchestration, messages.UserAccountOrchestrationInput{
+		DeletionRequestedAt: started.Add(-time.Second * 30),
+		DeletionUndoWindow:  time.Minute,
+	})
+	s.True(s.env.IsWorkflowCompleted())
+	s.Nil(s.env.GetWorkflowError())
+	s.Equal(started.Add(time.Second*30), s.env.Now())
+}
+
+func (s *UnitTestSuite) Test_Orchestration_HandleDelete_ContinueAsNewMidWindow() {
+	h, err := New(WithDeletionPolicy(messages.DeletionPolicy{DefaultUndoWindow: time.Minute}))
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	started := s.env.Now()
+	uc := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.DeleteUserAccountUpdateHandlerName, "1", uc,
+			messages.DeleteUserAccountRequest{})
+	}, time.Second*1)
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SetContinueAsNewSuggested(true)
+	}, time.Second*20)
+	s.env.ExecuteWorkflow(h.Orchestration, messages.UserAccountOrchestrationInput{})
+	s.True(s.env.IsWorkflowCompleted())
+	s.Nil(uc.Error())
+	var continueAsNew *workflow.ContinueAsNewError
+	s.True(errors.As(s.env.GetWorkflowError(), &continueAsNew))
+	snapshot := messages.UserAccountOrchestrationInput{}
+	s.Nil(converter.GetDefaultDataConverter().FromPayloads(continueAsNew.Input, &snapshot))
+	s.True(snapshot.DeletionRequested)
+	s.WithinDuration(started.Add(time.Second), snapshot.DeletionRequestedAt, 0)
+	s.WithinDuration(started.Add(time.Second+time.Minute), snapshot.DeletionScheduledFor, 0)
+
+	// The next run starts where the previous one left off and must delete at the originally promised moment
+	continuedAt := s.env.Now()
+	s.env = s.NewTestWorkflowEnvironment()
+	s.env.SetStartTime(continuedAt)
+	s.env.SetContinuedExecutionRunID("previous-run")
+	s.env.SetTestTimeout(time.Second * 5)
+	details := messages.UserDetailsResponse{}
+	s.env.RegisterDelayedCallback(func() {
+		v, err := s.env.QueryWorkflow(constants.UserDetailsQueryHandlerName)
+		s.Nil(err)
+		s.Nil(v.Get(&details))
+	}, time.Second*1)
+	s.env.ExecuteWorkflow(h.Orchestration, snapshot)
+	s.True(s.env.IsWorkflowCompleted())
+	s.Nil(s.env.GetWorkflowError())
+	s.Equal(snapshot.DeletionRequestedAt, details.DeletionRequestedAt)
+	s.Equal(snapshot.DeletionScheduledFor, details.DeletionScheduledFor)
+	s.WithinDuration(snapshot.DeletionScheduledFor, s.env.Now(), 0)
+}
+
+func (s *UnitTestSuite) Test_Orchestration_HandleDelete_Scheduled() {
+	h, err := New(WithDeletionPolicy(messages.DeletionPolicy{DefaultUndoWindow: time.Minute}))
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	started := s.env.Now()
+	scheduledAt := started.Add(time.Hour * 2)
+	var scheduledDeletion time.Time
+	scheduledDeletionKey := temporal.NewSearchAttributeKeyTime(constants.ScheduledDeletionSearchAttributeKey)
+	s.env.OnUpsertTypedSearchAttributes(mock.Anything).Run(func(args mock.Arguments) {
+		if v, ok := args.Get(0).(temporal.SearchAttributes).GetTime(scheduledDeletionKey); ok {
+			scheduledDeletion = v
+		}
+	}).Return(nil)
+	deletion := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.DeleteUserAccountUpdateHandlerName, "1", deletion,
+			messages.DeleteUserAccountRequest{DeletionScheduledAt: scheduledAt})
+	}, time.Second*1)
+	beforeDate := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.AddUserPermissionUpdateHandlerName, "2", beforeDate,
+			messages.AddUserPermissionRequest{
+				Permission: constants.PermissionTypeReadFiles,
+			})
+	}, time.Hour)
+	afterDate := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.AddUserPermissionUpdateHandlerName, "3", afterDate,
+			messages.AddUserPermissionRequest{
+				Permission: constants.PermissionTypeGrantPermissions,
+			})
+	}, time.Hour*2+time.Second*30)
+	s.executeCreated(h.Orchestration)
+	s.True(s.env.IsWorkflowCompleted())
+	s.Nil(s.env.GetWorkflowError())
+	s.Nil(deletion.Error())
+	s.Nil(beforeDate.Error())
+	s.True(afterDate.Rejected())
+	s.Equal("add_permission not allowed while user is pending_deletion", errorMessage(afterDate.Error()))
+	s.WithinDuration(scheduledAt.Add(time.Minute), scheduledDeletion, 0)
+	s.WithinDuration(scheduledAt.Add(time.Minute), s.env.Now(), 0)
+}
+
+func (s *UnitTestSuite) Test_Orchestration_HandleUpdateProfile() {
+	h, err := New()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	departmentKey := temporal.NewSearchAttributeKeyKeyword(constants.DepartmentSearchAttributeKey)
+	managerKey := temporal.NewSearchAttributeKeyKeyword(constants.ManagerIDSearchAttributeKey)
+	departments := make([]string, 0)
+	managers := make([]string, 0)
+	s.env.OnUpsertTypedSearchAttributes(mock.Anything).Run(func(args mock.Arguments) {
+		attributes := args.Get(0).(temporal.SearchAttributes)
+		if attributes.ContainsKey(departmentKey) {
+			v, _ := attributes.GetKeyword(departmentKey)
+			departments = append(departments, v)
+		}
+		if attributes.ContainsKey(managerKey) {
+			v, _ := attributes.GetKeyword(managerKey)
+			managers = append(managers, v)
+		}
+	}).Return(nil)
+	create := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.CreateUserAccountUpdateHandlerName, "1", create,
+			messages.CreateUserAccountRequest{
+				Profile: messages.UserProfile{
+					Department:   "engineering",
+					DisplayName:  "Default Test",
+					Email:        "default@temporal.io",
+					EmployeeType: constants.EmployeeTypeEmployee,
+					ManagerID:    "bobsaget@temporal.io",
+				},
+			})
+	}, time.Second*1)
+	invalidEmail := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.UpdateUserProfileUpdateHandlerName, "2", invalidEmail,
+			messages.UpdateUserProfileRequest{
+				Profile: messages.UserProfile{Email: "Default Test <default@temporal.io>"},
+			})
+	}, time.Second*2)
+	invalidEmployeeType := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.UpdateUserProfileUpdateHandlerName, "3", invalidEmployeeType,
+			messages.UpdateUserProfileRequest{
+				Profile: messages.UserProfile{EmployeeType: "robot"},
+			})
+	}, time.Second*3)
+	selfManaged := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.UpdateUserProfileUpdateHandlerName, "4", selfManaged,
+			messages.UpdateUserProfileRequest{
+				Profile: messages.UserProfile{ManagerID: "default-test-workflow-id"},
+			})
+	}, time.Second*4)
+	update := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.UpdateUserProfileUpdateHandlerName, "5", update,
+			messages.UpdateUserProfileRequest{
+				Profile: messages.UserProfile{
+					Department:   "sales",
+					DisplayName:  "Default Test",
+					EmployeeType: constants.EmployeeTypeContractor,
+				},
+			})
+	}, time.Second*5)
+	s.env.ExecuteWorkflow(h.Orchestration, messages.UserAccountOrchestrationInput{})
+	s.True(s.env.IsWorkflowCompleted())
+	s.Nil(create.Error())
+	s.True(invalidEmail.Rejected())
+	s.Equal("Default Test <default@temporal.io> is not a valid email address", errorMessage(invalidEmail.Error()))
+	s.True(invalidEmployeeType.Rejected())
+	s.Equal("employee type robot is not one of contractor, employee, intern",
+		errorMessage(invalidEmployeeType.Error()))
+	s.True(selfManaged.Rejected())
+	s.Equal("users cannot manage themselves", errorMessage(selfManaged.Error()))
+	s.Nil(update.Error())
+	s.Equal([]string{"engineering", "sales"}, departments)
+	s.Equal([]string{"bobsaget@temporal.io"}, managers)
+	v, err := s.env.QueryWorkflow(constants.UserDetailsQueryHandlerName)
+	s.Nil(err)
+	details := messages.UserDetailsResponse{}
+	s.Nil(v.Get(&details))
+	s.Equal(messages.UserProfile{
+		Department:   "sales",
+		DisplayName:  "Default Test",
+		EmployeeType: constants.EmployeeTypeContractor,
+	}, details.Profile)
+}
+
+func (s *UnitTestSuite) Test_Orchestration_HandleSuspendAndReinstate() {
+	h, err := New()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	s.env.OnActivity(new(activity_handler.Handler).VerifyApprover, mock.Anything, messages.VerifyApproverRequest{
+		ApproverID: "bobsaget@temporal.io",
+		Permission: constants.PermissionTypeGrantPermissions,
+	}).Return(messages.VerifyApproverResponse{Verified: true}, nil)
+	create := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.CreateUserAccountUpdateHandlerName, "1", create,
+			messages.CreateUserAccountRequest{
+				Permissions: []string{constants.PermissionTypeReadFiles},
+			})
+	}, time.Second*1)
+	suspend := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.SuspendUserAccountUpdateHandlerName, "2", suspend,
+			messages.SuspendUserAccountRequest{
+				Reason:      "credentials leaked",
+				RequestedBy: "security@temporal.io",
+			})
+	}, time.Second*2)
+	suspended := messages.UserDetailsResponse{}
+	s.env.RegisterDelayedCallback(func() {
+		v, err := s.env.QueryWorkflow(constants.UserDetailsQueryHandlerName)
+		s.Nil(err)
+		s.Nil(v.Get(&suspended))
+	}, time.Second*3)
+	addWhileSuspended := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.AddUserPermissionUpdateHandlerName, "4", addWhileSuspended,
+			messages.AddUserPermissionRequest{
+				Permission: constants.PermissionTypeGrantPermissions,
+			})
+	}, time.Second*4)
+	selfReinstate := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.ReinstateUserAccountUpdateHandlerName, "5", selfReinstate,
+			messages.ReinstateUserAccountRequest{
+				ApproverID: "default-test-workflow-id",
+			})
+	}, time.Second*5)
+	reinstate := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.ReinstateUserAccountUpdateHandlerName, "6", reinstate,
+			messages.ReinstateUserAccountRequest{
+				ApproverID: "bobsaget@temporal.io",
+			})
+	}, time.Second*6)
+	addAfterReinstate := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.AddUserPermissionUpdateHandlerName, "7", addAfterReinstate,
+			messages.AddUserPermissionRequest{
+				Permission: constants.PermissionTypeGrantPermissions,
+			})
+	}, time.Second*7)
+	s.env.ExecuteWorkflow(h.Orchestration, messages.UserAccountOrchestrationInput{})
+	s.True(s.env.IsWorkflowCompleted())
+	s.Nil(create.Error())
+	s.Nil(suspend.Error())
+	s.Equal(constants.UserStatusSuspended, suspended.Status)
+	s.Equal("credentials leaked", suspended.Suspension.Reason)
+	s.Equal([]string{constants.PermissionTypeReadFiles}, suspended.Permissions.Permissions)
+	s.True(suspended.Permissions.Suspended)
+	s.True(addWhileSuspended.Rejected())
+	s.Equal("add_permission not allowed while user is suspended", errorMessage(addWhileSuspended.Error()))
+	s.True(selfReinstate.Rejected())
+	s.Equal("default-test-workflow-id cannot reinstate themselves", errorMessage(selfReinstate.Error()))
+	s.Nil(reinstate.Error())
+	s.Nil(addAfterReinstate.Error())
+	v, err := s.env.QueryWorkflow(constants.UserDetailsQueryHandlerName)
+	s.Nil(err)
+	details := messages.UserDetailsResponse{}
+	s.Nil(v.Get(&details))
+	s.Equal(constants.UserStatusActive, details.Status)
+	s.False(details.Permissions.Suspended)
+	s.Equal([]string{constants.PermissionTypeGrantPermissions}, details.AwaitingApproval.Permissions)
+}
+
+func (s *UnitTestSuite) Test_Orchestration_HandleBreakGlass() {
+	h, err := New(WithApprovalSLA(messages.ApprovalSLA{ApproverPool: "approvers"}))
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	s.env.OnActivity(new(activity_handler.Handler).SendNotifications, mock.Anything, messages.SendNotificationsRequest{
+		ApproverPool:     "approvers",
+		NotificationType: constants.NotificationTypeBreakGlassReview,
+		PermissionType:   constants.PermissionTypeReadFiles,
+		RequesterID:      "default-test-workflow-id",
+	}).Return(messages.SendNotificationsResponse{}, nil).Once()
+	s.env.OnActivity(new(activity_handler.Handler).VerifyApprover, mock.Anything, messages.VerifyApproverRequest{
+		ApproverID: "bobsaget@temporal.io",
+		Permission: constants.PermissionTypeReadFiles,
+	}).Return(messages.VerifyApproverResponse{Verified: true}, nil).Once()
+	unjustified := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.BreakGlassUpdateHandlerName, "1", unjustified,
+			messages.BreakGlassRequest{Justification: " ", Permission: constants.PermissionTypeReadFiles})
+	}, time.Second*1)
+	breakGlass := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.BreakGlassUpdateHandlerName, "2", breakGlass,
+			messages.BreakGlassRequest{Justification: "INC-1234", Permission: constants.PermissionTypeReadFiles})
+	}, time.Second*2)
+	during := messages.PermissionsGrantedResponse{}
+	s.env.RegisterDelayedCallback(func() {
+		v, err := s.env.QueryWorkflow(constants.PermissionsGrantedQueryHandlerName)
+		s.Nil(err)
+		s.Nil(v.Get(&during))
+	}, time.Second*3)
+	selfReview := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.AcknowledgeBreakGlassUpdateHandlerName, "4", selfReview,
+			messages.AcknowledgeBreakGlassRequest{ApproverID: "default-test-workflow-id", ID: "1"})
+	}, time.Second*4)
+	after := messages.UserDetailsResponse{}
+	s.env.RegisterDelayedCallback(func() {
+		v, err := s.env.QueryWorkflow(constants.UserDetailsQueryHandlerName)
+		s.Nil(err)
+		s.Nil(v.Get(&after))
+	}, time.Hour*2)
+	review := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.AcknowledgeBreakGlassUpdateHandlerName, "6", review,
+			messages.AcknowledgeBreakGlassRequest{ApproverID: "bobsaget@temporal.io", ID: "1", Notes: "justified"})
+	}, time.Hour*3)
+	s.executeCreated(h.Orchestration)
+	s.True(s.env.IsWorkflowCompleted())
+	s.True(unjustified.Rejected())
+	s.Equal("justification required and missing", errorMessage(unjustified.Error()))
+	s.Nil(breakGlass.Error())
+	s.Equal([]string{constants.PermissionTypeReadFiles}, during.Permissions)
+	s.Equal("default-test-workflow-id cannot review their own emergency access", errorMessage(selfReview.Error()))
+	s.Empty(after.Permissions.Permissions)
+	s.Len(after.BreakGlass, 1)
+	granted := breakGlass.Result().(messages.BreakGlassResponse)
+	s.Equal("1", granted.ID)
+	s.Equal(time.Hour, granted.ExpiresAt.Sub(after.BreakGlass[0].GrantedAt))
+	s.False(after.BreakGlass[0].Active)
+	s.Equal("INC-1234", after.BreakGlass[0].Justification)
+	s.Empty(after.BreakGlass[0].ReviewedBy)
+	s.Nil(review.Error())
+	v, err := s.env.QueryWorkflow(constants.UserDetailsQueryHandlerName)
+	s.Nil(err)
+	details := messages.UserDetailsResponse{}
+	s.Nil(v.Get(&details))
+	s.Equal("bobsaget@temporal.io", details.BreakGlass[0].ReviewedBy)
+	s.Equal("justified", details.BreakGlass[0].ReviewNotes)
+	v, err = s.env.QueryWorkflow(constants.AuditLogQueryHandlerName, messages.AuditLogRequest{Limit: 2})
+	s.Nil(err)
+	page := messages.AuditLogResponse{}
+	s.Nil(v.Get(&page))
+	s.Equal(constants.AcknowledgeBreakGlassUpdateHandlerName, page.Entries[0].Action)
+	s.Equal(constants.AuditActionBreakGlassExpired, page.Entries[1].Action)
+	s.Equal(constants.PermissionTypeReadFiles, page.Entries[1].Permission)
+}
+
+func (s *UnitTestSuite) Test_Orchestration_HandleBreakGlass_Revoked() {
+	h, err := New(WithApprovalSLA(messages.ApprovalSLA{ApproverPool: "approvers"}))
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	s.env.OnActivity(new(activity_handler.Handler).SendNotifications, mock.Anything, mock.Anything).
+		Return(messages.SendNotificationsResponse{}, nil).Once()
+	s.env.OnActivity(new(activity_handler.Handler).VerifyApprover, mock.Anything, messages.VerifyApproverRequest{
+		ApproverID: "bobsaget@temporal.io",
+		Permission: constants.PermissionTypeReadFiles,
+	}).Return(messages.VerifyApproverResponse{Verified: true}, nil).Once()
+	breakGlass := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.BreakGlassUpdateHandlerName, "1", breakGlass,
+			messages.BreakGlassRequest{Justification: "INC-1234", Permission: constants.PermissionTypeReadFiles})
+	}, time.Second*1)
+	revoke := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.RevokeUserPermissionUpdateHandlerName, "2", revoke,
+			messages.RevokeUserPermissionRequest{ApproverID: "bobsaget@temporal.io",
+				Permission: constants.PermissionTypeReadFiles, Reason: "incident closed"})
+	}, time.Second*2)
+	revoked := messages.UserDetailsResponse{}
+	s.env.RegisterDelayedCallback(func() {
+		v, err := s.env.QueryWorkflow(constants.UserDetailsQueryHandlerName)
+		s.Nil(err)
+		s.Nil(v.Get(&revoked))
+	}, time.Second*3)
+	s.executeCreated(h.Orchestration)
+	s.True(s.env.IsWorkflowCompleted())
+	s.Nil(breakGlass.Error())
+	s.Nil(revoke.Error())
+	s.Empty(revoked.Permissions.Permissions)
+	s.Len(revoked.BreakGlass, 1)
+	s.False(revoked.BreakGlass[0].Active)
+	// The review outlives the access
+	s.Empty(revoked.BreakGlass[0].ReviewedBy)
+}
+
+func (s *UnitTestSuite) Test_Orchestration_HandleDelegation() {
+	h, err := New()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	delegate := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.DelegateApprovalUpdateHandlerName, "1", delegate,
+			messages.DelegateApprovalRequest{DelegateID: "bobsaget@temporal.io", ExpiresAfter: time.Hour})
+	}, time.Second*1)
+	unbounded := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.DelegateApprovalUpdateHandlerName, "2", unbounded,
+			messages.DelegateApprovalRequest{DelegateID: "mallory@temporal.io"})
+	}, time.Second*2)
+	revocable := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.DelegateApprovalUpdateHandlerName, "3", revocable,
+			messages.DelegateApprovalRequest{DelegateID: "mallory@temporal.io", ExpiresAfter: time.Hour * 8})
+	}, time.Second*3)
+	delegated := messages.PermissionsGrantedResponse{}
+	s.env.RegisterDelayedCallback(func() {
+		v, err := s.env.QueryWorkflow(constants.PermissionsGrantedQueryHandlerName)
+		s.Nil(err)
+		s.Nil(v.Get(&delegated))
+	}, time.Second*4)
+	revoke := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.RevokeDelegationUpdateHandlerName, "5", revoke,
+			messages.RevokeDelegationRequest{DelegateID: "mallory@temporal.io"})
+	}, time.Minute*30)
+	expired := messages.PermissionsGrantedResponse{}
+	s.env.RegisterDelayedCallback(func() {
+		v, err := s.env.QueryWorkflow(constants.PermissionsGrantedQueryHandlerName)
+		s.Nil(err)
+		s.Nil(v.Get(&expired))
+	}, time.Hour*2)
+	s.executeCreated(h.Orchestration, constants.PermissionTypeGrantPermissions)
+	s.True(s.env.IsWorkflowCompleted())
+	s.Nil(delegate.Error())
+	s.True(unbounded.Rejected())
+	s.Equal("expiry required and missing", errorMessage(unbounded.Error()))
+	s.Nil(revocable.Error())
+	s.Len(delegated.Delegations, 2)
+	s.Equal("bobsaget@temporal.io", delegated.Delegations[0].DelegateID)
+	s.Equal(time.Hour, delegated.Delegations[0].ExpiresAt.Sub(delegated.Delegations[0].GrantedAt))
+	s.Nil(revoke.Error())
+	s.Empty(expired.Delegations)
+	v, err := s.env.QueryWorkflow(constants.AuditLogQueryHandlerName, messages.AuditLogRequest{Limit: 2})
+	s.Nil(err)
+	page := messages.AuditLogResponse{}
+	s.Nil(v.Get(&page))
+	s.Equal(constants.AuditActionDelegationExpired, page.Entries[0].Action)
+	s.Equal(constants.SystemActorID, page.Entries[0].Actor)
+	s.Equal("to bobsaget@temporal.io", page.Entries[0].Reason)
+	s.Equal(constants.RevokeDelegationUpdateHandlerName, page.Entries[1].Action)
+	s.Equal("default-test-workflow-id", page.Entries[1].Actor)
+}
+
+func (s *UnitTestSuite) Test_Orchestration_HandleDelegateApproval_Refused() {
+	h, err := New()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	notHolder := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.DelegateApprovalUpdateHandlerName, "1", notHolder,
+			messages.DelegateApprovalRequest{DelegateID: "bobsaget@temporal.io", ExpiresAfter: time.Hour})
+	}, time.Second*1)
+	self := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.DelegateApprovalUpdateHandlerName, "2", self,
+			messages.DelegateApprovalRequest{DelegateID: "default-test-workflow-id", ExpiresAfter: time.Hour})
+	}, time.Second*2)
+	revokeUnknown := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.RevokeDelegationUpdateHandlerName, "3", revokeUnknown,
+			messages.RevokeDelegationRequest{DelegateID: "bobsaget@temporal.io"})
+	}, time.Second*3)
+	s.executeCreated(h.Orchestration)
+	s.True(s.env.IsWorkflowCompleted())
+	s.Equal("only holders of grant_permissions may delegate approval authority", errorMessage(notHolder.Error()))
+	s.Equal("users cannot delegate to themselves", errorMessage(self.Error()))
+	s.Equal("delegation not found", errorMessage(revokeUnknown.Error()))
+}
+
+func (s *UnitTestSuite) Test_Orchestration_HandleApprovePermission_OnBehalfOf() {
+	h, err := New()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	s.env.OnActivity(new(activity_handler.Handler).VerifyApprover, mock.Anything, messages.VerifyApproverRequest{
+		ApproverID: "bobsaget@temporal.io",
+		Permission: constants.PermissionTypeReadFiles,
+	}).Return(messages.VerifyApproverResponse{OnBehalfOf: "admin@temporal.io", Verified: true}, nil).Once()
+	s.env.OnActivity(new(activity_handler.Handler).VerifyApprover, mock.Anything, messages.VerifyApproverRequest{
+		ApproverID: "bobsaget@temporal.io",
+		Permission: constants.PermissionTypeGrantPermissions,
+	}).Return(messages.VerifyApproverResponse{OnBehalfOf: "default-test-workflow-id", Verified: true}, nil).Once()
+	for i, permission := range []string{constants.PermissionTypeReadFiles, constants.PermissionTypeGrantPermissions} {
+		s.env.RegisterDelayedCallback(func() {
+			s.env.UpdateWorkflow(constants.AddUserPermissionUpdateHandlerName, fmt.Sprintf("add-%d", i),
+				&updateCallbacks{t: s.T()}, messages.AddUserPermissionRequest{Permission: permission})
+		}, time.Second*time.Duration(i+1))
+	}
+	approve := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.ApproveUserPermissionUpdateHandlerName, "3", approve,
+			messages.ApproveUserPermissionRequest{
+				ApproverID: "bobsaget@temporal.io",
+				Permission: constants.PermissionTypeReadFiles,
+			})
+	}, time.Second*3)
+	approveForDelegator := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.ApproveUserPermissionUpdateHandlerName, "4", approveForDelegator,
+			messages.ApproveUserPermissionRequest{
+				ApproverID: "bobsaget@temporal.io",
+				Permission: constants.PermissionTypeGrantPermissions,
+			})
+	}, time.Second*4)
+	s.executeCreated(h.Orchestration)
+	s.True(s.env.IsWorkflowCompleted())
+	s.Nil(approve.Error())
+	s.Equal("bobsaget@temporal.io cannot grant permission grant_permissions", errorMessage(approveForDelegator.Error()))
+	v, err := s.env.QueryWorkflow(constants.AuditLogQueryHandlerName, messages.AuditLogRequest{Limit: 2})
+	s.Nil(err)
+	page := messages.AuditLogResponse{}
+	s.Nil(v.Get(&page))
+	s.Equal("default-test-workflow-id", page.Entries[0].OnBehalfOf)
+	s.Equal(constants.AuditOutcomeFailed, page.Entries[0].Outcome)
+	s.Equal("bobsaget@temporal.io", page.Entries[1].Actor)
+	s.Equal("admin@temporal.io", page.Entries[1].OnBehalfOf)
+	s.Equal(constants.AuditOutcomeSucceeded, page.Entries[1].Outcome)
+}
+
+func (s *UnitTestSuite) Test_Orchestration_HandleRoles() {
+	h, err := New()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	auditors := messages.RoleDetailsResponse{
+		Name:        "auditors",
+		Permissions: []string{constants.PermissionTypeReadFiles, constants.PermissionTypeApproveReadFiles},
+	}
+	s.env.OnActivity(new(activity_handler.Handler).GetRole, mock.Anything, messages.GetRoleRequest{
+		Role: "auditors",
+	}).Return(messages.GetRoleResponse{Found: true, Role: auditors}, nil).Twice()
+	s.env.OnActivity(new(activity_handler.Handler).VerifyApprover, mock.Anything, messages.VerifyApproverRequest{
+		ApproverID: "bobsaget@temporal.io",
+		Permission: "role:auditors",
+	}).Return(messages.VerifyApproverResponse{Verified: true}, nil).Twice()
+	s.env.OnActivity(new(activity_handler.Handler).JoinRole, mock.Anything, messages.JoinRoleRequest{
+		Role:   "auditors",
+		UserID: "default-test-workflow-id",
+	}).Return(messages.JoinRoleResponse{Permissions: auditors.Permissions}, nil).Once()
+	s.env.OnActivity(new(activity_handler.Handler).LeaveRole, mock.Anything, messages.LeaveRoleRequest{
+		Role:   "auditors",
+		UserID: "default-test-workflow-id",
+	}).Return(messages.LeaveRoleResponse{}, nil).Once()
+	add := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.AddUserRoleUpdateHandlerName, "1", add,
+			messages.AddUserRoleRequest{Role: "auditors"})
+	}, time.Second*1)
+	approve := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.ApproveUserRoleUpdateHandlerName, "2", approve,
+			messages.ApproveUserRoleRequest{ApproverID: "bobsaget@temporal.io", Role: "auditors"})
+	}, time.Second*2)
+	joined := messages.PermissionsGrantedResponse{}
+	s.env.RegisterDelayedCallback(func() {
+		v, err := s.env.QueryWorkflow(constants.PermissionsGrantedQueryHandlerName)
+		s.Nil(err)
+		s.Nil(v.Get(&joined))
+	}, time.Second*3)
+	revokeInherited := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.RevokeUserPermissionUpdateHandlerName, "4", revokeInherited,
+			messages.RevokeUserPermissionRequest{
+				ApproverID: "bobsaget@temporal.io",
+				Permission: constants.PermissionTypeApproveReadFiles,
+			})
+	}, time.Second*4)
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(constants.RolePermissionsChangedSignalName, messages.RolePermissionsChangedSignal{
+			Permissions: []string{constants.PermissionTypeGrantPermissions},
+			Role:        "auditors",
+		})
+	}, time.Second*5)
+	redefined := messages.PermissionsGrantedResponse{}
+	s.env.RegisterDelayedCallback(func() {
+		v, err := s.env.QueryWorkflow(constants.PermissionsGrantedQueryHandlerName)
+		s.Nil(err)
+		s.Nil(v.Get(&redefined))
+	}, time.Second*6)
+	remove := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.RemoveUserRoleUpdateHandlerName, "7", remove,
+			messages.RemoveUserRoleRequest{ApproverID: "bobsaget@temporal.io", Role: "auditors"})
+	}, time.Second*7)
+	s.executeCreated(h.Orchestration, constants.PermissionTypeReadFiles)
+	s.True(s.env.IsWorkflowCompleted())
+	s.Nil(add.Error())
+	s.Nil(approve.Error())
+	s.Equal([]string{constants.PermissionTypeReadFiles}, joined.Direct)
+	s.Equal(auditors.Permissions, joined.Permissions)
+	s.Equal([]messages.RoleGrant{{Permissions: auditors.Permissions, Role: "auditors"}}, joined.Roles)
+	s.True(revokeInherited.Rejected())
+	s.Equal("permission approve:read_files is inherited from role auditors and cannot be revoked on its own",
+		errorMessage(revokeInherited.Error()))
+	s.Equal([]string{constants.PermissionTypeReadFiles, constants.PermissionTypeGrantPermissions},
+		redefined.Permissions)
+	s.Nil(remove.Error())
+	v, err := s.env.QueryWorkflow(constants.PermissionsGrantedQueryHandlerName)
+	s.Nil(err)
+	granted := messages.PermissionsGrantedResponse{}
+	s.Nil(v.Get(&granted))
+	s.Equal([]string{constants.PermissionTypeReadFiles}, granted.Permissions)
+	s.Empty(granted.Roles)
+}
+
+func (s *UnitTestSuite) Test_Orchestration_HandleAddRole_Refused() {
+	h, err := New(WithSeparationOfDutiesRules([]messages.SeparationOfDutiesRule{{
+		ConflictsWith: constants.PermissionTypeGrantPermissions,
+		Name:          "requesters cannot approve",
+		Permission:    constants.PermissionTypeReadFiles,
+	}}))
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	s.env.OnActivity(new(activity_handler.Handler).GetRole, mock.Anything, messages.GetRoleRequest{
+		Role: "admins",
+	}).Return(messages.GetRoleResponse{Found: true, Role: messages.RoleDetailsResponse{
+		Name:        "admins",
+		Permissions: []string{constants.PermissionTypeGrantPermissions},
+	}}, nil).Once()
+	s.env.OnActivity(new(activity_handler.Handler).GetRole, mock.Anything, messages.GetRoleRequest{
+		Role: "ghosts",
+	}).Return(messages.GetRoleResponse{Found: false}, nil).Once()
+	conflicting := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.AddUserRoleUpdateHandlerName, "1", conflicting,
+			messages.AddUserRoleRequest{Role: "admins"})
+	}, time.Second*1)
+	missing := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.AddUserRoleUpdateHandlerName, "2", missing,
+			messages.AddUserRoleRequest{Role: "ghosts"})
+	}, time.Second*2)
+	s.executeCreated(h.Orchestration, constants.PermissionTypeReadFiles)
+	s.True(s.env.IsWorkflowCompleted())
+	s.False(conflicting.Rejected())
+	s.Equal("grant_permissions conflicts with held permission read_files under separation of duties rule "+
+		"\"requesters cannot approve\"", errorMessage(conflicting.Error()))
+	s.Equal("role ghosts not found", errorMessage(missing.Error()))
+	v, err := s.env.QueryWorkflow(constants.UserDetailsQueryHandlerName)
+	s.Nil(err)
+	details := messages.UserDetailsResponse{}
+	s.Nil(v.Get(&details))
+	s.Empty(details.PendingRoles)
+}
+
+func (s *UnitTestSuite) Test_Orchestration_HandleRoleRedefined_SeparationOfDuties() {
+	h, err := New(WithSeparationOfDutiesRules([]messages.SeparationOfDutiesRule{{
+		ConflictsWith: constants.PermissionTypeGrantPermissions,
+		Name:          "requesters cannot approve",
+		Permission:    constants.PermissionTypeReadFiles,
+	}}))
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(constants.RolePermissionsChangedSignalName, messages.RolePermissionsChangedSignal{
+			Permissions: []string{constants.PermissionTypeApproveReadFiles, constants.PermissionTypeGrantPermissions},
+			Role:        "auditors",
+		})
+	}, time.Second*1)
+	s.env.SetContinuedExecutionRunID("previous-run")
+	s.env.ExecuteWorkflow(h.Orchestration, messages.UserAccountOrchestrationInput{
+		Permissions: []string{constants.PermissionTypeReadFiles},
+		Roles:       []messages.RoleGrant{{Role: "auditors"}},
+		Status:      constants.UserStatusActive,
+	})
+	s.True(s.env.IsWorkflowCompleted())
+	v, err := s.env.QueryWorkflow(constants.PermissionsGrantedQueryHandlerName)
+	s.Nil(err)
+	granted := messages.PermissionsGrantedResponse{}
+	s.Nil(v.Get(&granted))
+	s.Equal([]string{constants.PermissionTypeReadFiles, constants.PermissionTypeApproveReadFiles}, granted.Permissions)
+	v, err = s.env.QueryWorkflow(constants.AuditLogQueryHandlerName, messages.AuditLogRequest{Limit: 2})
+	s.Nil(err)
+	page := messages.AuditLogResponse{}
+	s.Nil(v.Get(&page))
+	// Newest first: the refused permission is recorded before the change itself
+	s.Len(page.Entries, 2)
+	s.Equal(constants.AuditOutcomeSucceeded, page.Entries[0].Outcome)
+	s.Equal(constants.AuditOutcomeFailed, page.Entries[1].Outcome)
+	s.Equal(constants.PermissionTypeGrantPermissions, page.Entries[1].Permission)
+	s.Equal("auditors", page.Entries[1].Role)
+	s.Equal("grant_permissions conflicts with held permission read_files under separation of duties rule "+
+		"\"requesters cannot approve\"", page.Entries[1].Reason)
+}
+
+func (s *UnitTestSuite) Test_Orchestration_HandleUndoDelete_Scheduled() {
+	h, err := New(WithDeletionPolicy(messages.DeletionPolicy{DefaultUndoWindow: time.Minute}))
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	scheduledAt := s.env.Now().Add(time.Hour * 2)
+	uc := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.DeleteUserAccountUpdateHandlerName, "1", uc,
+			messages.DeleteUserAccountRequest{DeletionScheduledAt: scheduledAt})
+	}, time.Second*1)
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.UndoDeleteUserAccountUpdateHandlerName, "2", uc,
+			messages.UndoDeleteUserAccountRequest{})
+	}, time.Hour)
+	details := messages.UserDetailsResponse{}
+	s.env.RegisterDelayedCallback(func() {
+		v, err := s.env.QueryWorkflow(constants.UserDetailsQueryHandlerName)
+		s.Nil(err)
+		s.Nil(v.Get(&details))
+		s.env.SetContinueAsNewSuggested(true)
+	}, time.Hour*3)
+	s.env.ExecuteWorkflow(h.Orchestration, messages.UserAccountOrchestrationInput{})
+	s.True(s.env.IsWorkflowCompleted())
+	s.True(workflow.IsContinueAsNewError(s.env.GetWorkflowError()))
+	s.Nil(uc.Error())
+	s.False(details.DeletionRequested)
 }
 
-func (s *UnitTestSuite) Test_Orchestration_ReplayHistory() {
-	oh, err := New()
+func (s *UnitTestSuite) Test_Orchestration_HandleUndoDelete_SurvivesContinueAsNew() {
+	h, err := New()
 	s.Nil(err)
-	r := worker.NewWorkflowReplayer()
-	r.RegisterWorkflow(oh.Orchestration)
-	err = r.ReplayWorkflowHistoryFromJSONFile(s.GetLogger(), "../fixtures/event_history.json")
+	s.env.SetTestTimeout(time.Second * 5)
+	started := s.env.Now()
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SetContinueAsNewSuggested(true)
+	}, time.Hour)
+	s.env.SetContinuedExecutionRunID("previous-run")
+	s.env.ExecuteWorkflow(h.Orchestration, messages.UserAccountOrchestrationInput{
+		DeletionRequested:    false,
+		DeletionRequestedAt:  started.Add(-time.Second * 30),
+		DeletionScheduledFor: started.Add(time.Second * 30),
+		DeletionUndoWindow:   time.Minute,
+	})
+	s.True(s.env.IsWorkflowCompleted())
+	var continueAsNew *workflow.ContinueAsNewError
+	s.True(errors.As(s.env.GetWorkflowError(), &continueAsNew))
+	snapshot := messages.UserAccountOrchestrationInput{}
+	s.Nil(converter.GetDefaultDataConverter().FromPayloads(continueAsNew.Input, &snapshot))
+	s.False(snapshot.DeletionRequested)
+}
+
+func (s *UnitTestSuite) Test_Orchestration_ApprovePermission_SurvivesContinueAsNew() {
+	h, err := New()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	// Continue-as-new is suggested while the approval is still waiting on VerifyApprover
+	s.env.OnActivity(new(activity_handler.Handler).VerifyApprover, mock.Anything, messages.VerifyApproverRequest{
+		ApproverID: "bobsaget@temporal.io",
+		Permission: constants.PermissionTypeReadFiles,
+	}).After(time.Minute).Return(messages.VerifyApproverResponse{Verified: true}, nil).Once()
+	add := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.AddUserPermissionUpdateHandlerName, "1", add,
+			messages.AddUserPermissionRequest{Permission: constants.PermissionTypeReadFiles})
+	}, time.Second*1)
+	approve := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.ApproveUserPermissionUpdateHandlerName, "2", approve,
+			messages.ApproveUserPermissionRequest{
+				ApproverID: "bobsaget@temporal.io",
+				Permission: constants.PermissionTypeReadFiles,
+			})
+	}, time.Second*2)
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SetContinueAsNewSuggested(true)
+	}, time.Second*3)
+	late := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.UpdateUserProfileUpdateHandlerName, "3", late,
+			messages.UpdateUserProfileRequest{Profile: messages.UserProfile{Department: "Finance"}})
+	}, time.Second*4)
+	s.executeCreated(h.Orchestration)
+	s.True(s.env.IsWorkflowCompleted())
+	s.Nil(add.Error())
+	s.Nil(approve.Error())
+	s.Equal(messages.ApproveUserPermissionResponse{Approvals: 1, Granted: true, RequiredApprovals: 1},
+		approve.Result())
+	s.True(late.Rejected())
+	var appErr *temporal.ApplicationError
+	s.True(errors.As(late.Error(), &appErr))
+	s.Equal(constants.DrainingErrorType, appErr.Type())
+	var continueAsNew *workflow.ContinueAsNewError
+	s.True(errors.As(s.env.GetWorkflowError(), &continueAsNew))
+	snapshot := messages.UserAccountOrchestrationInput{}
+	s.Nil(converter.GetDefaultDataConverter().FromPayloads(continueAsNew.Input, &snapshot))
+	s.Equal([]string{constants.PermissionTypeReadFiles}, snapshot.Permissions)
+	s.Empty(snapshot.AwaitingApproval)
+	s.Empty(snapshot.PendingApprovals)
+	s.Equal("", snapshot.Profile.Department)
+}
+
+func (s *UnitTestSuite) Test_Orchestration_ApprovalSLA_KeptByRequest() {
+	sla := messages.ApprovalSLA{ApproverPool: "approvers", RemindAfter: time.Hour * 24}
+	h, err := New(WithApprovalSLA(sla))
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	uc := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.AddUserPermissionUpdateHandlerName, "1", uc,
+			messages.AddUserPermissionRequest{
+				Permission: constants.PermissionTypeReadFiles,
+			})
+	}, time.Second*1)
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SetContinueAsNewSuggested(true)
+	}, time.Second*2)
+	s.executeCreated(h.Orchestration)
+	s.True(s.env.IsWorkflowCompleted())
+	s.Nil(uc.Error())
+	var continueAsNew *workflow.ContinueAsNewError
+	s.True(errors.As(s.env.GetWorkflowError(), &continueAsNew))
+	snapshot := messages.UserAccountOrchestrationInput{}
+	s.Nil(converter.GetDefaultDataConverter().FromPayloads(continueAsNew.Input, &snapshot))
+	s.Len(snapshot.PendingApprovals, 1)
+	s.Equal(sla, snapshot.PendingApprovals[0].SLA)
+}
+
+// The worker's SLA only applies to requests made under it: a request carried across continue-as-new keeps its own
+func (s *UnitTestSuite) Test_Orchestration_ApprovalSLA_CarriedAcrossRuns() {
+	h, err := New()
+	s.Nil(err)
+	s.env.SetContinuedExecutionRunID("previous-run")
+	s.env.SetTestTimeout(time.Second * 5)
+	s.env.OnActivity(new(activity_handler.Handler).SendNotifications, mock.Anything, messages.SendNotificationsRequest{
+		ApproverPool:     "approvers",
+		NotificationType: constants.NotificationTypeApprovalReminder,
+		PermissionType:   constants.PermissionTypeReadFiles,
+		RequesterID:      "default-test-workflow-id",
+	}).Return(messages.SendNotificationsResponse{}, nil).Once()
+	reminded := messages.UserDetailsResponse{}
+	s.env.RegisterDelayedCallback(func() {
+		v, err := s.env.QueryWorkflow(constants.UserDetailsQueryHandlerName)
+		s.Nil(err)
+		s.Nil(v.Get(&reminded))
+		s.env.SetContinueAsNewSuggested(true)
+	}, time.Hour*25)
+	s.env.ExecuteWorkflow(h.Orchestration, messages.UserAccountOrchestrationInput{
+		AwaitingApproval: []string{constants.PermissionTypeReadFiles},
+		PendingApprovals: []messages.PendingApproval{{
+			Permission:        constants.PermissionTypeReadFiles,
+			RequestedAt:       s.env.Now(),
+			RequiredApprovals: 1,
+			SLA:               messages.ApprovalSLA{ApproverPool: "approvers", RemindAfter: time.Hour * 24},
+		}},
+		SchemaVersion: user_account_state.SnapshotSchemaVersion,
+		Status:        constants.UserStatusActive,
+	})
+	s.True(s.env.IsWorkflowCompleted())
+	s.Len(reminded.PendingApprovals, 1)
+	s.True(reminded.PendingApprovals[0].Reminded)
+}
+
+func (s *UnitTestSuite) Test_Orchestration_Snapshot_RoundTrip() {
+	h, err := New()
+	s.Nil(err)
+	s.env.SetContinuedExecutionRunID("previous-run")
+	s.env.SetTestTimeout(time.Second * 5)
+	// Every field is set so that one Snapshot forgets shows up as a difference
+	at := time.Date(2030, time.January, 2, 3, 4, 5, 0, time.UTC)
+	snapshot := messages.UserAccountOrchestrationInput{
+		AuditLog: []messages.AuditEntry{{
+			Action:  constants.SuspendUserAccountUpdateHandlerName,
+			Actor:   "admin@temporal.io",
+			At:      at,
+			Outcome: constants.AuditOutcomeSucceeded,
+			Reason:  "leave",
+		}},
+		AuditLogCompacted: 3,
+		AwaitingApproval:  []string{constants.PermissionTypeGrantPermissions},
+		BreakGlass: []messages.BreakGlassAccess{{
+			ExpiresAt:     at,
+			GrantedAt:     at,
+			ID:            "1",
+			Justification: "incident",
+			Permission:    constants.PermissionTypeReadFiles,
+			RequestedBy:   "default-test-workflow-id",
+			ReviewedAt:    at,
+			ReviewedBy:    "admin@temporal.io",
+			ReviewNotes:   "ok",
+		}},
+		Delegations: []messages.Delegation{{DelegateID: "d@temporal.io", ExpiresAt: at, GrantedAt: at}},
+		PendingApprovals: []messages.PendingApproval{{
+			Approvals:         []string{"admin@temporal.io"},
+			ExpiresAfter:      time.Hour,
+			Permission:        constants.PermissionTypeGrantPermissions,
+			RequestedAt:       at,
+			RequiredApprovals: 2,
+			SLA:               messages.ApprovalSLA{ApproverPool: "approvers", RemindAfter: time.Hour * 24},
+		}},
+		PendingRoles:          []messages.PendingRoleAssignment{{RequestedAt: at, RequestedBy: "m@temporal.io", Role: "ops"}},
+		Permissions:           []string{constants.PermissionTypeReadFiles},
+		PermissionExpirations: []messages.PermissionExpiration{{ExpiresAt: at, Permission: constants.PermissionTypeReadFiles}},
+		Rejections: []messages.PermissionRejection{{
+			ApproverID: "admin@temporal.io",
+			Permission: "deploy",
+			Reason:     "not needed",
+			RejectedAt: at,
+		}},
+		Roles:                []messages.RoleGrant{{Permissions: []string{"view_reports"}, Role: "auditors"}},
+		SchemaVersion:        user_account_state.SnapshotSchemaVersion,
+		DeletionRequestedAt:  at,
+		DeletionScheduledAt:  at,
+		DeletionScheduledFor: at,
+		DeletionUndoWindow:   time.Minute,
+		Profile: messages.UserProfile{
+			Department:   "Finance",
+			DisplayName:  "Default",
+			Email:        "default@temporal.io",
+			EmployeeType: constants.EmployeeTypeEmployee,
+			ManagerID:    "m@temporal.io",
+		},
+		Status:               constants.UserStatusSuspended,
+		StatusBeforeDeletion: constants.UserStatusActive,
+		Suspension: messages.Suspension{
+			Reason:      "leave",
+			RequestedBy: "admin@temporal.io",
+			Suspended:   true,
+			SuspendedAt: at,
+		},
+	}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SetContinueAsNewSuggested(true)
+	}, time.Second*1)
+	fields := reflect.ValueOf(snapshot)
+	for i := 0; i < fields.NumField(); i++ {
+		// A requested deletion would start the deletion
+		if name := fields.Type().Field(i).Name; name != "DeletionRequested" {
+			s.False(fields.Field(i).IsZero(), name)
+		}
+	}
+	s.env.ExecuteWorkflow(h.Orchestration, snapshot)
+	s.True(s.env.IsWorkflowCompleted())
+	var continueAsNew *workflow.ContinueAsNewError
+	s.True(errors.As(s.env.GetWorkflowError(), &continueAsNew))
+	carried := messages.UserAccountOrchestrationInput{}
+	s.Nil(converter.GetDefaultDataConverter().FromPayloads(continueAsNew.Input, &carried))
+	s.Equal(snapshot, carried)
+}
+
+func (s *UnitTestSuite) Test_Orchestration_Snapshot_UpgradesLegacy() {
+	sla := messages.ApprovalSLA{ApproverPool: "approvers", RemindAfter: time.Hour * 24}
+	h, err := New(WithApprovalPolicies(map[string]messages.ApprovalPolicy{
+		constants.PermissionTypeGrantPermissions: {RequiredApprovals: 2},
+	}), WithApprovalSLA(sla))
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	started := s.env.Now()
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SetContinueAsNewSuggested(true)
+	}, time.Second*1)
+	s.env.SetContinuedExecutionRunID("previous-run")
+	s.env.ExecuteWorkflow(h.Orchestration, messages.UserAccountOrchestrationInput{
+		AwaitingApproval: []string{constants.PermissionTypeGrantPermissions},
+		Permissions:      []string{constants.PermissionTypeReadFiles, constants.PermissionTypeReadFiles},
+		Status:           constants.UserStatusActive,
+	})
+	s.True(s.env.IsWorkflowCompleted())
+	var continueAsNew *workflow.ContinueAsNewError
+	s.True(errors.As(s.env.GetWorkflowError(), &continueAsNew))
+	snapshot := messages.UserAccountOrchestrationInput{}
+	s.Nil(converter.GetDefaultDataConverter().FromPayloads(continueAsNew.Input, &snapshot))
+	s.Equal(user_account_state.SnapshotSchemaVersion, snapshot.SchemaVersion)
+	s.Equal([]string{constants.PermissionTypeReadFiles}, snapshot.Permissions)
+	s.Len(snapshot.PendingApprovals, 1)
+	s.Equal(constants.PermissionTypeGrantPermissions, snapshot.PendingApprovals[0].Permission)
+	s.Equal(2, snapshot.PendingApprovals[0].RequiredApprovals)
+	s.False(snapshot.PendingApprovals[0].RequestedAt.Before(started))
+	s.Equal(sla, snapshot.PendingApprovals[0].SLA)
+}
+
+func (s *UnitTestSuite) Test_Orchestration_Snapshot_NewerSchemaVersion() {
+	h, err := New()
+	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	s.env.ExecuteWorkflow(h.Orchestration, messages.UserAccountOrchestrationInput{
+		SchemaVersion: user_account_state.SnapshotSchemaVersion + 1,
+		Status:        constants.UserStatusActive,
+	})
+	s.True(s.env.IsWorkflowCompleted())
+	s.ErrorContains(s.env.GetWorkflowError(), "is newer than the supported version")
+}
+
+func (s *UnitTestSuite) Test_Orchestration_Validators_RejectBadRequests() {
+	h, err := New()
 	s.Nil(err)
+	s.env.SetTestTimeout(time.Second * 5)
+	emptyAdd := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.AddUserPermissionUpdateHandlerName, "1", emptyAdd,
+			messages.AddUserPermissionRequest{})
+	}, time.Second*1)
+	add := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.AddUserPermissionUpdateHandlerName, "2", add,
+			messages.AddUserPermissionRequest{
+				Permission: constants.PermissionTypeReadFiles,
+			})
+	}, time.Second*2)
+	duplicateAdd := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.AddUserPermissionUpdateHandlerName, "3", duplicateAdd,
+			messages.AddUserPermissionRequest{
+				Permission: constants.PermissionTypeReadFiles,
+			})
+	}, time.Second*3)
+	undoWithoutDelete := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.UndoDeleteUserAccountUpdateHandlerName, "4", undoWithoutDelete,
+			messages.UndoDeleteUserAccountRequest{})
+	}, time.Second*4)
+	deletion := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.DeleteUserAccountUpdateHandlerName, "5", deletion,
+			messages.DeleteUserAccountRequest{})
+	}, time.Second*5)
+	approveAfterDelete := &updateCallbacks{t: s.T()}
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(constants.ApproveUserPermissionUpdateHandlerName, "6", approveAfterDelete,
+			messages.ApproveUserPermissionRequest{
+				ApproverID: "bobsaget@temporal.io",
+				Permission: constants.PermissionTypeReadFiles,
+			})
+	}, time.Second*6)
+	s.executeCreated(h.Orchestration)
+	s.True(s.env.IsWorkflowCompleted())
+	s.Nil(add.Error())
+	s.Nil(deletion.Error())
+	s.True(emptyAdd.Rejected())
+	s.Equal("permission required and missing", errorMessage(emptyAdd.Error()))
+	s.True(duplicateAdd.Rejected())
+	s.Equal("permission read_files already awaiting approval", errorMessage(duplicateAdd.Error()))
+	s.True(undoWithoutDelete.Rejected())
+	s.Equal("deletion not requested", errorMessage(undoWithoutDelete.Error()))
+	s.True(approveAfterDelete.Rejected())
+	s.Equal("approve_permission not allowed while user is pending_deletion", errorMessage(approveAfterDelete.Error()))
+	var appErr *temporal.ApplicationError
+	s.True(errors.As(duplicateAdd.Error(), &appErr))
+	s.Equal(constants.InvalidRequestErrorType, appErr.Type())
+	s.True(errors.As(approveAfterDelete.Error(), &appErr))
+	s.Equal(constants.IllegalTransitionErrorType, appErr.Type())
 }
 
 // updateCallbacks are necessary for testing updates AND are an excellent affordance for debugging
 type updateCallbacks struct {
-	t   *testing.T
-	err error
+	t        *testing.T
+	err      error
+	rejected bool
+	result   interface{}
 }
 
 func (uc *updateCallbacks) Accept() {
@@ -224,6 +2259,7 @@ func (uc *updateCallbacks) Accept() {
 
 func (uc *updateCallbacks) Reject(err error) {
 	uc.err = err
+	uc.rejected = true
 	// uc.t.Logf("rejected err—%s", err.Error())
 }
 
@@ -235,9 +2271,29 @@ func (uc *updateCallbacks) Complete(success interface{}, err error) {
 		// uc.t.Logf("complete err—%s", err.Error())
 		return
 	}
+	uc.result = success
 	// uc.t.Logf("complete success %v", success)
 }
 
 func (uc *updateCallbacks) Error() error {
 	return uc.err
 }
+
+// Result returns the value the update completed with.
+func (uc *updateCallbacks) Result() interface{} {
+	return uc.result
+}
+
+// Rejected reports whether the update was refused by its validator rather than failing once accepted.
+func (uc *updateCallbacks) Rejected() bool {
+	return uc.rejected
+}
+
+// errorMessage returns the message of err without the type and retry details an ApplicationError adds to Error().
+func errorMessage(err error) string {
+	var appErr *temporal.ApplicationError
+	if errors.As(err, &appErr) {
+		return appErr.Message()
+	}
+	return err.Error()
+}
@@ -0,0 +1,221 @@
+package permission_catalog_state
+
+import (
+	"errors"
+	"fmt"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/constants"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/messages"
+	"go.temporal.io/sdk/log"
+	"go.temporal.io/sdk/workflow"
+	"strings"
+	"time"
+)
+
+type Option func(*PermissionCatalogState)
+
+type PermissionCatalogState struct {
+	ctx         workflow.Context
+	definitions []messages.PermissionDefinition
+	logger      log.Logger
+}
+
+func New(ctx workflow.Context, opts ...Option) (*PermissionCatalogState, error) {
+	state := &PermissionCatalogState{
+		ctx:         ctx,
+		definitions: make([]messages.PermissionDefinition, 0),
+	}
+	for _, o := range opts {
+		o(state)
+	}
+	if state.ctx == nil {
+		return nil, errors.New("context required and missing")
+	}
+	state.logger = workflow.GetLogger(state.ctx)
+	return state, nil
+}
+
+func WithSnapshot(input messages.PermissionCatalogOrchestrationInput) Option {
+	return func(state *PermissionCatalogState) {
+		for _, d := range input.Definitions {
+			state.upsert(normalize(d))
+		}
+	}
+}
+
+// DefaultDefinitions returns the permission types the catalog is seeded with when it is first started.
+func DefaultDefinitions() []messages.PermissionDefinition {
+	return []messages.PermissionDefinition{
+		{
+			AdminPermission:   constants.PermissionTypeGrantPermissions,
+			ApprovalPolicy:    messages.ApprovalPolicy{RequiredApprovals: 2},
+			ApproverAuthority: constants.PermissionTypeApproveGrantPermissions,
+			Description:       "Approve any permission for any user",
+			Name:              constants.PermissionTypeGrantPermissions,
+			RiskLevel:         constants.RiskLevelHigh,
+		},
+		{
+			AdminPermission:   constants.PermissionTypeGrantPermissions,
+			ApprovalPolicy:    messages.ApprovalPolicy{RequiredApprovals: 1},
+			ApproverAuthority: constants.PermissionTypeApproveReadFiles,
+			Description:       "Read shared files",
+			Name:              constants.PermissionTypeReadFiles,
+			RiskLevel:         constants.RiskLevelLow,
+		},
+		{
+			AdminPermission:   constants.PermissionTypeGrantPermissions,
+			ApprovalPolicy:    messages.ApprovalPolicy{RequiredApprovals: 2},
+			ApproverAuthority: constants.ApproverAuthorityPrefix + constants.PermissionTypeApproveGrantPermissions,
+			Description:       "Approve grant_permissions requests",
+			Name:              constants.PermissionTypeApproveGrantPermissions,
+			RiskLevel:         constants.RiskLevelHigh,
+		},
+		{
+			AdminPermission:   constants.PermissionTypeGrantPermissions,
+			ApprovalPolicy:    messages.ApprovalPolicy{RequiredApprovals: 1},
+			ApproverAuthority: constants.ApproverAuthorityPrefix + constants.PermissionTypeApproveReadFiles,
+			Description:       "Approve read_files requests",
+			Name:              constants.PermissionTypeApproveReadFiles,
+			RiskLevel:         constants.RiskLevelMedium,
+		},
+	}
+}
+
+// FallbackDefinition returns the definition that applies to name when the catalog does not define it, e.g. a role.
+func FallbackDefinition(name string) messages.PermissionDefinition {
+	return normalize(messages.PermissionDefinition{Name: name})
+}
+
+// normalize trims d and fills in what it leaves out: a single approver, who holds approve:<name> or the
+// grant_permissions super-permission.
+func normalize(d messages.PermissionDefinition) messages.PermissionDefinition {
+	d.AdminPermission = strings.TrimSpace(d.AdminPermission)
+	d.ApproverAuthority = strings.TrimSpace(d.ApproverAuthority)
+	d.Name = strings.TrimSpace(d.Name)
+	d.Description = strings.TrimSpace(d.Description)
+	if d.ApprovalPolicy.RequiredApprovals < 1 {
+		d.ApprovalPolicy.RequiredApprovals = 1
+	}
+	if d.AdminPermission == "" {
+		d.AdminPermission = constants.PermissionTypeGrantPermissions
+	}
+	if d.ApproverAuthority == "" {
+		d.ApproverAuthority = constants.ApproverAuthorityPrefix + d.Name
+	}
+	return d
+}
+
+// lookup returns the definition of name and whether the catalog has one.
+func (state *PermissionCatalogState) lookup(name string) (messages.PermissionDefinition, bool) {
+	for _, existing := range state.definitions {
+		if existing.Name == name {
+			return existing, true
+		}
+	}
+	return messages.PermissionDefinition{}, false
+}
+
+// resolve normalizes d, keeping the authority fields of the existing definition of the same name where d leaves them
+// out.
+func (state *PermissionCatalogState) resolve(d messages.PermissionDefinition) messages.PermissionDefinition {
+	existing, ok := state.lookup(strings.TrimSpace(d.Name))
+	if ok {
+		if strings.TrimSpace(d.AdminPermission) == "" {
+			d.AdminPermission = existing.AdminPermission
+		}
+		if strings.TrimSpace(d.ApproverAuthority) == "" {
+			d.ApproverAuthority = existing.ApproverAuthority
+		}
+	}
+	return normalize(d)
+}
+
+// relaxes reports whether d lowers the approvals the existing definition of the same name requires, or changes its risk
+// level.
+func (state *PermissionCatalogState) relaxes(d messages.PermissionDefinition) bool {
+	existing, ok := state.lookup(d.Name)
+	return ok && (d.ApprovalPolicy.RequiredApprovals < existing.ApprovalPolicy.RequiredApprovals ||
+		d.RiskLevel != existing.RiskLevel)
+}
+
+func (state *PermissionCatalogState) upsert(d messages.PermissionDefinition) {
+	for i, existing := range state.definitions {
+		if existing.Name == d.Name {
+			state.definitions[i] = d
+			return
+		}
+	}
+	state.definitions = append(state.definitions, d)
+}
+
+// Define adds a permission type to the catalog, or replaces the definition of an existing one. Who may approve an
+// existing permission type cannot be changed: anyone may define permissions, and approvers are verified against the
+// catalog. Lowering the approvals an existing permission type requires, or changing its risk level, needs req.ApproverID
+// to hold its admin permission.
+func (state *PermissionCatalogState) Define(ctx workflow.Context, req messages.DefinePermissionRequest) error {
+	err := state.ValidateDefine(req)
+	if err != nil {
+		return err
+	}
+	d := state.resolve(req.Definition)
+	if state.relaxes(d) {
+		actCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+			StartToCloseTimeout: 1 * time.Minute,
+		})
+		verified := messages.VerifyApproverResponse{}
+		err = workflow.ExecuteActivity(actCtx, "VerifyApprover", &messages.VerifyApproverRequest{
+			AdminOnly:  true,
+			ApproverID: req.ApproverID,
+			Permission: d.Name,
+		}).Get(actCtx, &verified)
+		if err != nil {
+			return err
+		}
+		if !verified.Verified {
+			return errors.New(fmt.Sprintf("%s cannot lower the approvals or change the risk level of permission %s",
+				req.ApproverID, d.Name))
+		}
+		// The permission may have been redefined while the approver was being verified
+		err = state.ValidateDefine(req)
+		if err != nil {
+			return err
+		}
+	}
+	state.upsert(state.resolve(req.Definition))
+	return nil
+}
+
+func (state *PermissionCatalogState) Definitions() messages.PermissionDefinitionsResponse {
+	return messages.PermissionDefinitionsResponse{Definitions: state.definitions}
+}
+
+func (state *PermissionCatalogState) Snapshot() messages.PermissionCatalogOrchestrationInput {
+	return messages.PermissionCatalogOrchestrationInput{Definitions: state.definitions}
+}
+
+// ValidateDefine reports why req would be refused without changing the catalog.
+func (state *PermissionCatalogState) ValidateDefine(req messages.DefinePermissionRequest) error {
+	d := state.resolve(req.Definition)
+	if d.Name == "" {
+		return errors.New("permission name required and missing")
+	}
+	existing, ok := state.lookup(d.Name)
+	if ok && (d.AdminPermission != existing.AdminPermission || d.ApproverAuthority != existing.ApproverAuthority) {
+		return errors.New(fmt.Sprintf("approver authority of permission %s cannot be changed", d.Name))
+	}
+	if req.ApproverID == "" && state.relaxes(d) {
+		return errors.New(fmt.Sprintf("approver required and missing to lower the approvals or change the risk level "+
+			"of permission %s", d.Name))
+	}
+	for _, name := range []string{d.Name, d.ApproverAuthority, d.AdminPermission} {
+		if strings.ContainsAny(name, " \t\n\"") {
+			return errors.New(fmt.Sprintf("permission name %q must not contain whitespace or quotes", name))
+		}
+	}
+	switch d.RiskLevel {
+	case constants.RiskLevelLow, constants.RiskLevelMedium, constants.RiskLevelHigh:
+	default:
+		return errors.New(fmt.Sprintf("risk level %q must be one of %s, %s or %s", d.RiskLevel,
+			constants.RiskLevelLow, constants.RiskLevelMedium, constants.RiskLevelHigh))
+	}
+	return nil
+}
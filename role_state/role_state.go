@@ -0,0 +1,239 @@
+package role_state
+
+import (
+	"errors"
+	"fmt"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/constants"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/messages"
+	"go.temporal.io/sdk/log"
+	"go.temporal.io/sdk/workflow"
+	"slices"
+	"strings"
+	"time"
+)
+
+type Option func(*RoleState)
+
+type RoleState struct {
+	ctx         workflow.Context
+	description string
+	logger      log.Logger
+	members     []string
+	name        string
+	permissions []string
+}
+
+func New(ctx workflow.Context, opts ...Option) (*RoleState, error) {
+	state := &RoleState{
+		ctx:         ctx,
+		members:     make([]string, 0),
+		permissions: make([]string, 0),
+	}
+	for _, o := range opts {
+		o(state)
+	}
+	if state.ctx == nil {
+		return nil, errors.New("context required and missing")
+	}
+	state.logger = workflow.GetLogger(state.ctx)
+	state.name = Name(workflow.GetInfo(state.ctx).WorkflowExecution.ID)
+	return state, nil
+}
+
+func WithSnapshot(input messages.RoleOrchestrationInput) Option {
+	return func(state *RoleState) {
+		state.description = input.Description
+		state.members = appendUnique(state.members, input.Members...)
+		state.permissions = appendUnique(state.permissions, input.Permissions...)
+	}
+}
+
+// Name returns the name of the role run under workflowID.
+func Name(workflowID string) string {
+	return strings.TrimPrefix(workflowID, constants.RoleWorkflowIDPrefix)
+}
+
+// WorkflowID returns the ID of the entity workflow of role. Role entities are prefixed so that they cannot collide
+// with user entities.
+func WorkflowID(role string) string {
+	return constants.RoleWorkflowIDPrefix + role
+}
+
+// added returns those of permissions that the role does not hold yet.
+func (state *RoleState) added(permissions []string) []string {
+	added := make([]string, 0)
+	for _, permission := range permissions {
+		if !slices.Contains(state.permissions, permission) {
+			added = appendUnique(added, permission)
+		}
+	}
+	return added
+}
+
+// checkWiden returns an error if adding the permission d defines would grant it to the role's members with fewer
+// approvals than its approval policy requires.
+func (state *RoleState) checkWiden(d messages.PermissionDefinition) error {
+	if len(state.members) > 0 && d.ApprovalPolicy.RequiredApprovals > 1 {
+		return errors.New(fmt.Sprintf("permission %s requires %d approvals and cannot be added to role %s while it "+
+			"has members", d.Name, d.ApprovalPolicy.RequiredApprovals, state.name))
+	}
+	return nil
+}
+
+// propagate tells every member about the role's permissions so that they can refresh what they inherit. Members that
+// cannot be reached, e.g. because they have since been deleted, are logged and skipped.
+func (state *RoleState) propagate(ctx workflow.Context) {
+	// Members may join or leave while signals are in flight
+	members := slices.Clone(state.members)
+	futures := make([]workflow.Future, 0, len(members))
+	for _, member := range members {
+		futures = append(futures, workflow.SignalExternalWorkflow(ctx, member, "",
+			constants.RolePermissionsChangedSignalName, messages.RolePermissionsChangedSignal{
+				Permissions: state.permissions,
+				Role:        state.name,
+			}))
+	}
+	for i, f := range futures {
+		err := f.Get(ctx, nil)
+		if err != nil {
+			state.logger.Error("unable to propagate role permissions", "Member", members[i], "Error", err)
+		}
+	}
+}
+
+// AddMember records req.UserID as a member of the role and returns the permissions the member inherits.
+func (state *RoleState) AddMember(req messages.AddRoleMemberRequest) (messages.AddRoleMemberResponse, error) {
+	err := state.ValidateAddMember(req)
+	if err != nil {
+		return messages.AddRoleMemberResponse{}, err
+	}
+	state.members = appendUnique(state.members, req.UserID)
+	return messages.AddRoleMemberResponse{Permissions: state.permissions}, nil
+}
+
+// Define replaces the role's description and permissions and propagates the permissions to every member. Each
+// permission must be in the permission catalog. Permissions added to the role reach every member without approval, so
+// req.ApproverID must hold the admin permission of each of them, and a role with members cannot be widened with a
+// permission whose approval policy asks for more than one approver.
+func (state *RoleState) Define(ctx workflow.Context, req messages.DefineRoleRequest) error {
+	err := state.ValidateDefine(req)
+	if err != nil {
+		return err
+	}
+	actCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 1 * time.Minute,
+	})
+	definitions := make(map[string]messages.PermissionDefinition)
+	for _, permission := range req.Permissions {
+		resp := messages.ValidatePermissionResponse{}
+		err = workflow.ExecuteActivity(actCtx, "ValidatePermission", &messages.ValidatePermissionRequest{
+			Permission: permission,
+		}).Get(actCtx, &resp)
+		if err != nil {
+			return err
+		}
+		if !resp.Valid {
+			return errors.New(fmt.Sprintf("%s is not a permission in the catalog", permission))
+		}
+		definitions[permission] = resp.Definition
+	}
+	for _, permission := range state.added(req.Permissions) {
+		err = state.checkWiden(definitions[permission])
+		if err != nil {
+			return err
+		}
+		verified := messages.VerifyApproverResponse{}
+		err = workflow.ExecuteActivity(actCtx, "VerifyApprover", &messages.VerifyApproverRequest{
+			AdminOnly:  true,
+			ApproverID: req.ApproverID,
+			Permission: permission,
+		}).Get(actCtx, &verified)
+		if err != nil {
+			return err
+		}
+		if !verified.Verified {
+			return errors.New(fmt.Sprintf("%s cannot add permission %s to role %s", req.ApproverID, permission,
+				state.name))
+		}
+	}
+	// Members may have joined, or permissions been added, while the activities were running
+	for _, permission := range state.added(req.Permissions) {
+		err = state.checkWiden(definitions[permission])
+		if err != nil {
+			return err
+		}
+	}
+	state.description = strings.TrimSpace(req.Description)
+	state.permissions = appendUnique(make([]string, 0), req.Permissions...)
+	state.propagate(ctx)
+	return nil
+}
+
+func (state *RoleState) Details() messages.RoleDetailsResponse {
+	return messages.RoleDetailsResponse{
+		Description: state.description,
+		Members:     state.members,
+		Name:        state.name,
+		Permissions: state.permissions,
+	}
+}
+
+// RemoveMember forgets req.UserID so that later changes to the role are no longer propagated to them. Removing a user
+// that is not a member succeeds so that retries are harmless.
+func (state *RoleState) RemoveMember(req messages.RemoveRoleMemberRequest) error {
+	err := state.ValidateRemoveMember(req)
+	if err != nil {
+		return err
+	}
+	state.members = slices.DeleteFunc(state.members, func(member string) bool { return member == req.UserID })
+	return nil
+}
+
+func (state *RoleState) Snapshot() messages.RoleOrchestrationInput {
+	return messages.RoleOrchestrationInput{
+		Description: state.description,
+		Members:     state.members,
+		Permissions: state.permissions,
+	}
+}
+
+func (state *RoleState) ValidateAddMember(req messages.AddRoleMemberRequest) error {
+	if req.UserID == "" {
+		return errors.New("user required and missing")
+	}
+	return nil
+}
+
+// ValidateDefine reports why req would be refused without changing the role. Permissions are checked against the
+// catalog, and the approver verified, only when the update runs.
+func (state *RoleState) ValidateDefine(req messages.DefineRoleRequest) error {
+	if state.name == "" || strings.ContainsAny(state.name, " \t\n\"") {
+		return errors.New(fmt.Sprintf("role name %q must not be empty or contain whitespace or quotes", state.name))
+	}
+	for _, permission := range req.Permissions {
+		if permission == "" {
+			return errors.New("permissions must not be empty")
+		}
+	}
+	if req.ApproverID == "" && len(state.added(req.Permissions)) > 0 {
+		return errors.New("approver required and missing to add permissions")
+	}
+	return nil
+}
+
+func (state *RoleState) ValidateRemoveMember(req messages.RemoveRoleMemberRequest) error {
+	if req.UserID == "" {
+		return errors.New("user required and missing")
+	}
+	return nil
+}
+
+// appendUnique appends each of additions to values unless values already holds it.
+func appendUnique(values []string, additions ...string) []string {
+	for _, addition := range additions {
+		if !slices.Contains(values, addition) {
+			values = append(values, addition)
+		}
+	}
+	return values
+}
@@ -5,29 +5,145 @@ import (
 	"fmt"
 	"github.com/temporal-sa/temporal-entity-lifecycle-go/constants"
 	"github.com/temporal-sa/temporal-entity-lifecycle-go/messages"
+	"github.com/temporal-sa/temporal-entity-lifecycle-go/role_state"
 	"go.temporal.io/sdk/log"
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
+	"net/mail"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 )
 
+const (
+	// auditLogRetention is how many of the most recent audit entries are carried across continue-as-new. Older entries
+	// are dropped and only counted.
+	auditLogRetention = 500
+	// breakGlassWindow is how long emergency access lasts before it is revoked.
+	breakGlassWindow = time.Hour
+	// defaultAuditLogPageSize applies when an audit log query does not set a limit.
+	defaultAuditLogPageSize = 50
+	// defaultUndoDeletionWindow applies when neither the request nor the deletion policy sets an undo window.
+	defaultUndoDeletionWindow = time.Second * 60
+	// maxDelegationWindow caps how long approval authority may be delegated for.
+	maxDelegationWindow = time.Hour * 24 * 30
+	// maxAuditLogPageSize caps the limit of an audit log query.
+	maxAuditLogPageSize = 500
+)
+
+// SnapshotSchemaVersion is the schema version of the snapshots Snapshot takes. Bump it whenever the meaning of a
+// snapshot changes, e.g. a field is added that older snapshots cannot simply leave empty, and append the upgrade from
+// the previous version to snapshotUpgrades.
+const SnapshotSchemaVersion = 2
+
+// snapshotUpgrades migrates a snapshot taken at schema version i to version i+1. Upgrades run in order when the state
+// is restored and must be deterministic: they may read the workflow clock and the worker's policies, but only
+// workflow.GetVersion may be called and only where it always was.
+var snapshotUpgrades = []func(state *UserAccountState, snapshot *messages.UserAccountOrchestrationInput){
+	(*UserAccountState).upgradeSnapshotV0,
+	(*UserAccountState).upgradeSnapshotV1,
+}
+
+// allowedStates lists the lifecycle states in which each update may be applied.
+var allowedStates = map[string][]string{
+	constants.AcknowledgeBreakGlassUpdateHandlerName: {constants.UserStatusActive, constants.UserStatusSuspended,
+		constants.UserStatusPendingDeletion},
+	constants.AddUserPermissionUpdateHandlerName:     {constants.UserStatusActive},
+	constants.AddUserRoleUpdateHandlerName:           {constants.UserStatusActive},
+	constants.ApproveUserPermissionUpdateHandlerName: {constants.UserStatusActive},
+	constants.ApproveUserRoleUpdateHandlerName:       {constants.UserStatusActive},
+	constants.BreakGlassUpdateHandlerName:            {constants.UserStatusActive},
+	constants.CreateUserAccountUpdateHandlerName:     {constants.UserStatusPending},
+	constants.DelegateApprovalUpdateHandlerName:      {constants.UserStatusActive},
+	constants.DeleteUserAccountUpdateHandlerName: {constants.UserStatusPending, constants.UserStatusActive,
+		constants.UserStatusSuspended},
+	constants.ReinstateUserAccountUpdateHandlerName: {constants.UserStatusSuspended},
+	constants.RejectUserPermissionUpdateHandlerName: {constants.UserStatusActive, constants.UserStatusSuspended},
+	constants.RemoveUserRoleUpdateHandlerName:       {constants.UserStatusActive, constants.UserStatusSuspended},
+	constants.RevokeDelegationUpdateHandlerName:     {constants.UserStatusActive, constants.UserStatusSuspended},
+	constants.RevokeUserPermissionUpdateHandlerName: {constants.UserStatusActive, constants.UserStatusSuspended},
+	constants.SuspendUserAccountUpdateHandlerName:   {constants.UserStatusActive},
+	constants.UpdateUserProfileUpdateHandlerName:    {constants.UserStatusActive, constants.UserStatusSuspended},
+	// A deletion scheduled for a future date may be undone before the user enters pending_deletion
+	constants.UndoDeleteUserAccountUpdateHandlerName: {constants.UserStatusPending, constants.UserStatusActive,
+		constants.UserStatusSuspended, constants.UserStatusPendingDeletion},
+}
+
+// employeeTypes lists the employee types a profile may carry.
+var employeeTypes = []string{
+	constants.EmployeeTypeContractor,
+	constants.EmployeeTypeEmployee,
+	constants.EmployeeTypeIntern,
+}
+
+// lifecycleTransitions lists the lifecycle states each lifecycle state may move to.
+var lifecycleTransitions = map[string][]string{
+	constants.UserStatusPending:   {constants.UserStatusActive, constants.UserStatusPendingDeletion},
+	constants.UserStatusActive:    {constants.UserStatusSuspended, constants.UserStatusPendingDeletion},
+	constants.UserStatusSuspended: {constants.UserStatusActive, constants.UserStatusPendingDeletion},
+	constants.UserStatusPendingDeletion: {constants.UserStatusPending, constants.UserStatusActive,
+		constants.UserStatusSuspended, constants.UserStatusDeleted},
+	constants.UserStatusDeleted: {},
+}
+
+// IllegalTransitionError is returned for an update the user's lifecycle state does not allow, e.g. adding a
+// permission to a user that was never created.
+type IllegalTransitionError struct {
+	Status string
+	Update string
+}
+
+func (e *IllegalTransitionError) Error() string {
+	return fmt.Sprintf("%s not allowed while user is %s", e.Update, e.Status)
+}
+
+// UserAlreadyExistsError is returned for a create update on a user that has already been created.
+type UserAlreadyExistsError struct {
+	Status string
+}
+
+func (e *UserAlreadyExistsError) Error() string {
+	return fmt.Sprintf("user already exists and is %s", e.Status)
+}
+
 type Option func(*UserAccountState)
 
 type UserAccountState struct {
+	approvalPolicies     map[string]messages.ApprovalPolicy
+	approvalSLA          messages.ApprovalSLA
+	auditLog             []messages.AuditEntry
+	auditLogCompacted    int
 	awaitingApproval     []string
+	breakGlass           []messages.BreakGlassAccess
 	ctx                  workflow.Context
-	created              bool
-	deleted              bool
+	delegations          []messages.Delegation
 	deletionRequested    bool
+	deletionPolicy       messages.DeletionPolicy
 	deletionRequestedAt  time.Time
+	deletionScheduledAt  time.Time
 	deletionScheduledFor time.Time
+	deletionUndoWindow   time.Duration
+	lifecycle            string
 	logger               log.Logger
+	pendingApprovals     map[string]messages.PendingApproval
+	pendingRoles         []messages.PendingRoleAssignment
+	permissionExpiration map[string]time.Time
 	permissionsGranted   []string
+	profile              messages.UserProfile
+	rejections           []messages.PermissionRejection
+	roles                []messages.RoleGrant
+	separationOfDuties   []messages.SeparationOfDutiesRule
+	snapshot             messages.UserAccountOrchestrationInput
+	statusBeforeDeletion string
+	suspension           messages.Suspension
 }
 
 func New(ctx workflow.Context, opts ...Option) (*UserAccountState, error) {
 	state := &UserAccountState{
-		ctx: ctx,
+		ctx:                  ctx,
+		pendingApprovals:     make(map[string]messages.PendingApproval),
+		permissionExpiration: make(map[string]time.Time),
 	}
 	for _, o := range opts {
 		o(state)
@@ -36,30 +152,202 @@ func New(ctx workflow.Context, opts ...Option) (*UserAccountState, error) {
 		return nil, errors.New("context required and missing")
 	}
 	state.logger = workflow.GetLogger(state.ctx)
+	state.restore(state.snapshot)
+	// Users only become active, and get their permissions, through the create update, so a new entity must start
+	// blank. Runs started before the state machine predate the rule and are left to replay as they ran.
+	continued := workflow.GetInfo(state.ctx).ContinuedExecutionRunID != ""
+	if !continued && (state.snapshot.SchemaVersion > 0 || workflow.GetVersion(state.ctx,
+		"user_lifecycle_state_machine", workflow.DefaultVersion, 1) != workflow.DefaultVersion) {
+		if state.lifecycle != constants.UserStatusPending {
+			return nil, errors.New(fmt.Sprintf("new users start %s, not %s", constants.UserStatusPending,
+				state.lifecycle))
+		}
+		if !state.blank() {
+			return nil, errors.New("new users start blank and are set up through the create update")
+		}
+	}
 	if len(state.permissionsGranted) > 0 {
 		err := state.refreshSearchAttributes()
 		if err != nil {
 			return nil, err
 		}
 	}
-	if !state.deletionRequestedAt.IsZero() {
-		state.RequestDeletion(messages.DeleteUserAccountRequest{})
+	err := state.refreshStatusSearchAttribute()
+	if err != nil {
+		return nil, err
+	}
+	if len(state.delegations) > 0 {
+		err = state.refreshDelegatesSearchAttribute()
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, d := range state.delegations {
+		state.scheduleDelegationExpiry(d)
+	}
+	for _, access := range state.breakGlass {
+		if access.Active {
+			state.scheduleBreakGlassExpiry(access.ID)
+		}
+	}
+	if state.profile.Department != "" || state.profile.ManagerID != "" {
+		err = state.refreshProfileSearchAttributes()
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, e := range state.PermissionExpirations() {
+		state.scheduleExpiration(e.Permission, e.ExpiresAt)
+	}
+	for _, permission := range state.awaitingApproval {
+		state.watchPendingApproval(permission)
+	}
+	if state.deletionRequested {
+		// Resume the original undo window rather than requesting deletion afresh, which would restart it from now
+		err = state.startDeletion()
+		if err != nil {
+			return nil, err
+		}
 	}
 	return state, nil
 }
 
+// WithApprovalPolicies sets how many distinct approvers each permission type requires. Permission types without a
+// policy require a single approver.
+func WithApprovalPolicies(policies map[string]messages.ApprovalPolicy) Option {
+	return func(state *UserAccountState) {
+		state.approvalPolicies = policies
+	}
+}
+
+// WithApprovalSLA sets how long a permission request may wait before the approver pool is reminded, the request is
+// escalated and finally auto-denied. A zero duration disables that stage.
+func WithApprovalSLA(sla messages.ApprovalSLA) Option {
+	return func(state *UserAccountState) {
+		state.approvalSLA = sla
+	}
+}
+
+// WithDeletionPolicy sets the default undo window for deletions and the bounds a per-request undo window must fall
+// within.
+func WithDeletionPolicy(policy messages.DeletionPolicy) Option {
+	return func(state *UserAccountState) {
+		state.deletionPolicy = policy
+	}
+}
+
+// WithSeparationOfDutiesRules sets pairs of permissions that may not be held by the same user. Rules apply in both
+// directions.
+func WithSeparationOfDutiesRules(rules []messages.SeparationOfDutiesRule) Option {
+	return func(state *UserAccountState) {
+		state.separationOfDuties = rules
+	}
+}
+
+// WithSnapshot restores the state from input, a snapshot taken by Snapshot before continuing as new or the input of a
+// new entity. Snapshots of an older schema version are upgraded first.
 func WithSnapshot(input messages.UserAccountOrchestrationInput) Option {
 	return func(state *UserAccountState) {
-		state.awaitingApproval = input.AwaitingApproval
-		state.permissionsGranted = input.Permissions
-		state.deletionRequestedAt = input.DeletionRequestedAt
+		state.snapshot = input
+	}
+}
+
+// restore upgrades snapshot to SnapshotSchemaVersion and restores the state from it. A snapshot of a newer schema
+// version was taken by a newer worker: failing the workflow task rather than the workflow leaves the entity for a
+// worker that understands it.
+func (state *UserAccountState) restore(snapshot messages.UserAccountOrchestrationInput) {
+	if snapshot.SchemaVersion > SnapshotSchemaVersion {
+		panic(fmt.Sprintf("snapshot schema version %d is newer than the supported version %d",
+			snapshot.SchemaVersion, SnapshotSchemaVersion))
+	}
+	for v := snapshot.SchemaVersion; v < SnapshotSchemaVersion; v++ {
+		snapshotUpgrades[v](state, &snapshot)
+	}
+	state.auditLog = snapshot.AuditLog
+	state.auditLogCompacted = snapshot.AuditLogCompacted
+	state.awaitingApproval = append(make([]string, 0), snapshot.AwaitingApproval...)
+	state.breakGlass = snapshot.BreakGlass
+	state.delegations = snapshot.Delegations
+	state.permissionsGranted = append(make([]string, 0), snapshot.Permissions...)
+	state.deletionRequested = snapshot.DeletionRequested
+	state.deletionRequestedAt = snapshot.DeletionRequestedAt
+	state.deletionScheduledAt = snapshot.DeletionScheduledAt
+	state.deletionScheduledFor = snapshot.DeletionScheduledFor
+	state.deletionUndoWindow = snapshot.DeletionUndoWindow
+	state.lifecycle = snapshot.Status
+	state.pendingRoles = snapshot.PendingRoles
+	state.profile = snapshot.Profile
+	state.rejections = snapshot.Rejections
+	state.roles = snapshot.Roles
+	state.statusBeforeDeletion = snapshot.StatusBeforeDeletion
+	state.suspension = snapshot.Suspension
+	for _, p := range snapshot.PendingApprovals {
+		state.pendingApprovals[p.Permission] = p
+	}
+	for _, e := range snapshot.PermissionExpirations {
+		state.permissionExpiration[e.Permission] = e.ExpiresAt
+	}
+	if state.lifecycle == "" {
+		// Snapshots always carry the lifecycle state, so this is a new entity
+		state.lifecycle = constants.UserStatusPending
+	}
+}
+
+// upgradeSnapshotV0 upgrades snapshots that predate schema versions, including the input of entities started without
+// one, which were patched up as they were restored.
+func (state *UserAccountState) upgradeSnapshotV0(snapshot *messages.UserAccountOrchestrationInput) {
+	// Snapshots taken before permissions were kept as sets may hold duplicates
+	snapshot.AwaitingApproval = appendUnique(make([]string, 0), snapshot.AwaitingApproval...)
+	snapshot.Permissions = appendUnique(make([]string, 0), snapshot.Permissions...)
+	v := workflow.GetVersion(state.ctx, "user_lifecycle_state_machine", workflow.DefaultVersion, 1)
+	if snapshot.Status == "" {
+		switch {
+		case v != workflow.DefaultVersion && workflow.GetInfo(state.ctx).ContinuedExecutionRunID == "":
+			snapshot.Status = constants.UserStatusPending
+		case snapshot.Suspension.Suspended:
+			// Users that predate the state machine, or were carried over in a snapshot without one, were usable
+			// without the create update
+			snapshot.Status = constants.UserStatusSuspended
+		default:
+			snapshot.Status = constants.UserStatusActive
+		}
+	}
+	for _, permission := range snapshot.AwaitingApproval {
+		if !slices.ContainsFunc(snapshot.PendingApprovals, func(p messages.PendingApproval) bool {
+			return p.Permission == permission
+		}) {
+			// Snapshots taken before approval SLAs existed carry no request time so the clock starts now
+			snapshot.PendingApprovals = append(snapshot.PendingApprovals, messages.PendingApproval{
+				Permission:        permission,
+				RequestedAt:       workflow.Now(state.ctx),
+				RequiredApprovals: state.requiredApprovals(permission),
+			})
+		}
+	}
+	if !snapshot.DeletionRequestedAt.IsZero() && snapshot.DeletionScheduledFor.IsZero() {
+		// Snapshots taken before the scheduled time was carried only hold the request time, and an undone deletion
+		// cannot be told apart from a pending one
+		if snapshot.DeletionUndoWindow <= 0 {
+			snapshot.DeletionUndoWindow = state.undoWindow(messages.DeleteUserAccountRequest{})
+		}
+		snapshot.DeletionRequested = true
+		snapshot.DeletionScheduledFor = snapshot.DeletionRequestedAt.Add(snapshot.DeletionUndoWindow)
+	}
+}
+
+// upgradeSnapshotV1 upgrades snapshots taken before each permission request carried its approval SLA. Their requests
+// were watched with the worker's SLA, which they keep from now on.
+func (state *UserAccountState) upgradeSnapshotV1(snapshot *messages.UserAccountOrchestrationInput) {
+	snapshot.PendingApprovals = append(make([]messages.PendingApproval, 0), snapshot.PendingApprovals...)
+	for i := range snapshot.PendingApprovals {
+		snapshot.PendingApprovals[i].SLA = state.approvalSLA
 	}
 }
 
 func (state *UserAccountState) refreshSearchAttributes() error {
 	var errs error
 	permissionsKey := temporal.NewSearchAttributeKeyKeywordList(constants.PermissionsSearchAttributeKey)
-	err := workflow.UpsertTypedSearchAttributes(state.ctx, permissionsKey.ValueSet(state.permissionsGranted))
+	err := workflow.UpsertTypedSearchAttributes(state.ctx, permissionsKey.ValueSet(state.effectivePermissions()))
 	if err != nil {
 		errs = errors.Join(errs, err)
 	}
@@ -71,129 +359,1724 @@ func (state *UserAccountState) refreshSearchAttributes() error {
 	return errs
 }
 
-func (state *UserAccountState) userHasPermissionPendingApproval(permission string) bool {
-	for _, pending := range state.awaitingApproval {
-		if permission == pending {
+// refreshBreakGlassSearchAttribute flags the user while they hold emergency access.
+func (state *UserAccountState) refreshBreakGlassSearchAttribute() error {
+	active := slices.ContainsFunc(state.breakGlass, func(access messages.BreakGlassAccess) bool { return access.Active })
+	key := temporal.NewSearchAttributeKeyBool(constants.BreakGlassActiveSearchAttributeKey)
+	return workflow.UpsertTypedSearchAttributes(state.ctx, key.ValueSet(active))
+}
+
+// refreshDelegatesSearchAttribute indexes the users the user has delegated their approval authority to so that
+// VerifyApprover can find the delegations of an approver.
+func (state *UserAccountState) refreshDelegatesSearchAttribute() error {
+	delegates := make([]string, 0, len(state.delegations))
+	for _, d := range state.delegations {
+		delegates = append(delegates, d.DelegateID)
+	}
+	delegatesKey := temporal.NewSearchAttributeKeyKeywordList(constants.DelegatesSearchAttributeKey)
+	return workflow.UpsertTypedSearchAttributes(state.ctx, delegatesKey.ValueSet(delegates))
+}
+
+// refreshProfileSearchAttributes indexes the user's department and manager so that users can be listed by either.
+func (state *UserAccountState) refreshProfileSearchAttributes() error {
+	v := workflow.GetVersion(state.ctx, "profile_search_attributes", workflow.DefaultVersion, 1)
+	if v == workflow.DefaultVersion {
+		return nil
+	}
+	departmentKey := temporal.NewSearchAttributeKeyKeyword(constants.DepartmentSearchAttributeKey)
+	department := departmentKey.ValueUnset()
+	if state.profile.Department != "" {
+		department = departmentKey.ValueSet(state.profile.Department)
+	}
+	managerKey := temporal.NewSearchAttributeKeyKeyword(constants.ManagerIDSearchAttributeKey)
+	manager := managerKey.ValueUnset()
+	if state.profile.ManagerID != "" {
+		manager = managerKey.ValueSet(state.profile.ManagerID)
+	}
+	return workflow.UpsertTypedSearchAttributes(state.ctx, department, manager)
+}
+
+// refreshScheduledDeletionSearchAttribute publishes when a pending deletion takes effect, or clears it, so that upcoming
+// deletions can be listed.
+func (state *UserAccountState) refreshScheduledDeletionSearchAttribute() error {
+	v := workflow.GetVersion(state.ctx, "scheduled_deletion_search_attribute", workflow.DefaultVersion, 1)
+	if v == workflow.DefaultVersion {
+		return nil
+	}
+	key := temporal.NewSearchAttributeKeyTime(constants.ScheduledDeletionSearchAttributeKey)
+	if state.deletionRequested {
+		return workflow.UpsertTypedSearchAttributes(state.ctx, key.ValueSet(state.deletionScheduledFor))
+	}
+	return workflow.UpsertTypedSearchAttributes(state.ctx, key.ValueUnset())
+}
+
+// refreshStatusSearchAttribute publishes the user's status so that, e.g., suspended users can be listed.
+func (state *UserAccountState) refreshStatusSearchAttribute() error {
+	v := workflow.GetVersion(state.ctx, "user_status_search_attribute", workflow.DefaultVersion, 1)
+	if v == workflow.DefaultVersion {
+		return nil
+	}
+	key := temporal.NewSearchAttributeKeyKeyword(constants.StatusSearchAttributeKey)
+	return workflow.UpsertTypedSearchAttributes(state.ctx, key.ValueSet(state.lifecycle))
+}
+
+// audit appends the outcome of action to the audit log. A failed action records err as its reason.
+func (state *UserAccountState) audit(action string, actor string, permission string, reason string, err error) {
+	entry := messages.AuditEntry{
+		Action:     action,
+		Actor:      actor,
+		At:         workflow.Now(state.ctx),
+		Outcome:    constants.AuditOutcomeSucceeded,
+		Permission: permission,
+		Reason:     reason,
+	}
+	if err != nil {
+		entry.Outcome = constants.AuditOutcomeFailed
+		entry.Reason = err.Error()
+	}
+	state.auditLog = append(state.auditLog, entry)
+}
+
+// auditRole appends the outcome of a role action to the audit log.
+func (state *UserAccountState) auditRole(action string, actor string, role string, err error) {
+	state.audit(action, actor, "", "", err)
+	state.auditLog[len(state.auditLog)-1].Role = role
+}
+
+// auditOnBehalfOf marks the latest audit entry as made by a delegate on behalf of delegatorID, if any.
+func (state *UserAccountState) auditOnBehalfOf(delegatorID string) {
+	state.auditLog[len(state.auditLog)-1].OnBehalfOf = delegatorID
+}
+
+// blank reports whether the user holds nothing beyond what a new entity starts with.
+func (state *UserAccountState) blank() bool {
+	return len(state.auditLog) == 0 && len(state.awaitingApproval) == 0 && len(state.breakGlass) == 0 &&
+		len(state.delegations) == 0 && len(state.pendingApprovals) == 0 && len(state.pendingRoles) == 0 &&
+		len(state.permissionExpiration) == 0 && len(state.permissionsGranted) == 0 && len(state.rejections) == 0 &&
+		len(state.roles) == 0 && !state.deletionRequested && state.deletionRequestedAt.IsZero() &&
+		state.deletionScheduledFor.IsZero() && state.profile == (messages.UserProfile{}) &&
+		state.suspension == (messages.Suspension{})
+}
+
+// breakGlassAccess returns the index of the emergency access with id, or -1 if there is none.
+func (state *UserAccountState) breakGlassAccess(id string) int {
+	return slices.IndexFunc(state.breakGlass, func(access messages.BreakGlassAccess) bool { return access.ID == id })
+}
+
+// checkAllowed returns an IllegalTransitionError unless update may be applied in the user's lifecycle state.
+func (state *UserAccountState) checkAllowed(update string) error {
+	if !slices.Contains(allowedStates[update], state.lifecycle) {
+		return &IllegalTransitionError{Status: state.lifecycle, Update: update}
+	}
+	return nil
+}
+
+// transition moves the user to the lifecycle state to and publishes it.
+func (state *UserAccountState) transition(to string) error {
+	if !slices.Contains(lifecycleTransitions[state.lifecycle], to) {
+		return errors.New(fmt.Sprintf("user cannot move from %s to %s", state.lifecycle, to))
+	}
+	state.lifecycle = to
+	return state.refreshStatusSearchAttribute()
+}
+
+// deletionDeferred reports whether a requested deletion is scheduled for a future date, which leaves the user in
+// their current state until that date. Entities that predate the state machine enter pending_deletion straight away.
+func (state *UserAccountState) deletionDeferred() bool {
+	v := workflow.GetVersion(state.ctx, "user_lifecycle_state_machine", workflow.DefaultVersion, 1)
+	return v != workflow.DefaultVersion && state.deletionScheduledAt.After(workflow.Now(state.ctx))
+}
+
+// enterPendingDeletion starts the undo window, remembering the state an undo returns the user to.
+func (state *UserAccountState) enterPendingDeletion() error {
+	state.statusBeforeDeletion = state.lifecycle
+	return state.transition(constants.UserStatusPendingDeletion)
+}
+
+// startDeletion moves the user to pending_deletion, now or once the scheduled date arrives, and schedules the
+// deletion itself.
+func (state *UserAccountState) startDeletion() error {
+	if state.lifecycle != constants.UserStatusPendingDeletion && !state.deletionDeferred() {
+		err := state.enterPendingDeletion()
+		if err != nil {
+			return err
+		}
+	}
+	state.scheduleDeletion()
+	return nil
+}
+
+func (state *UserAccountState) hasApproved(permission string, approverID string) bool {
+	for _, a := range state.pendingApprovals[permission].Approvals {
+		if a == approverID {
 			return true
 		}
 	}
 	return false
 }
 
-func (state *UserAccountState) AwaitingApproval() messages.AwaitingApprovalResponse {
-	return messages.AwaitingApprovalResponse{Permissions: state.awaitingApproval}
+// checkSeparationOfDuties returns an error naming the first rule that permission would violate if granted alongside
+// the permissions the user already holds.
+func (state *UserAccountState) checkSeparationOfDuties(permission string) error {
+	for _, rule := range state.separationOfDuties {
+		conflict := ""
+		if rule.Permission == permission {
+			conflict = rule.ConflictsWith
+		} else if rule.ConflictsWith == permission {
+			conflict = rule.Permission
+		}
+		if conflict != "" && slices.Contains(state.effectivePermissions(), conflict) {
+			return errors.New(fmt.Sprintf("%s conflicts with held permission %s under separation of duties rule %q",
+				permission, conflict, rule.Name))
+		}
+	}
+	return nil
 }
 
-func (state *UserAccountState) CreateUser(req messages.CreateUserAccountRequest) error {
-	if state.deleted || state.deletionRequested {
-		return errors.New("user deleted")
+// checkRoleSeparationOfDuties returns an error naming the first rule that inheriting permissions from role would
+// violate, either against the permissions the user already holds or among the role's own permissions.
+func (state *UserAccountState) checkRoleSeparationOfDuties(role string, permissions []string) error {
+	for _, permission := range permissions {
+		err := state.checkSeparationOfDuties(permission)
+		if err != nil {
+			return err
+		}
 	}
-	state.permissionsGranted = append(state.permissionsGranted, req.Permissions...)
-	state.created = true
-	return state.refreshSearchAttributes()
+	for _, rule := range state.separationOfDuties {
+		if slices.Contains(permissions, rule.Permission) && slices.Contains(permissions, rule.ConflictsWith) {
+			return errors.New(fmt.Sprintf("role %s bundles %s and %s, which conflict under separation of duties rule %q",
+				role, rule.Permission, rule.ConflictsWith, rule.Name))
+		}
+	}
+	return nil
 }
 
-func (state *UserAccountState) Deleted() bool {
-	return state.deleted
+// effectivePermissions returns the user's direct permissions followed by every permission inherited from their roles,
+// without duplicates.
+func (state *UserAccountState) effectivePermissions() []string {
+	effective := appendUnique(make([]string, 0, len(state.permissionsGranted)), state.permissionsGranted...)
+	for _, grant := range state.roles {
+		effective = appendUnique(effective, grant.Permissions...)
+	}
+	return effective
 }
 
-func (state *UserAccountState) DeletionRequestedAt() time.Time {
-	return state.deletionRequestedAt
+// delegationTo returns the index of the delegation to delegateID, or -1 if there is none.
+func (state *UserAccountState) delegationTo(delegateID string) int {
+	return slices.IndexFunc(state.delegations, func(d messages.Delegation) bool { return d.DelegateID == delegateID })
 }
 
-func (state *UserAccountState) Permissions() messages.PermissionsGrantedResponse {
-	return messages.PermissionsGrantedResponse{Permissions: state.permissionsGranted}
+// getRole asks the GetRole activity for the details of role. ctx must already carry activity options.
+func (state *UserAccountState) getRole(ctx workflow.Context, role string) (messages.RoleDetailsResponse, error) {
+	resp := messages.GetRoleResponse{}
+	err := workflow.ExecuteActivity(ctx, "GetRole", &messages.GetRoleRequest{Role: role}).Get(ctx, &resp)
+	if err != nil {
+		return messages.RoleDetailsResponse{}, err
+	}
+	if !resp.Found {
+		return messages.RoleDetailsResponse{}, errors.New(fmt.Sprintf("role %s not found", role))
+	}
+	return resp.Role, nil
+}
+
+// hasRole reports whether the user is a member of role.
+func (state *UserAccountState) hasRole(role string) bool {
+	return slices.ContainsFunc(state.roles, func(g messages.RoleGrant) bool { return g.Role == role })
 }
 
-func (state *UserAccountState) RequestAddPermission(req messages.AddUserPermissionRequest) error {
-	if state.deleted || state.deletionRequested {
-		return errors.New("user deleted")
+// hasRolePendingApproval reports whether the user has asked to join role and is awaiting approval.
+func (state *UserAccountState) hasRolePendingApproval(role string) bool {
+	return slices.ContainsFunc(state.pendingRoles, func(p messages.PendingRoleAssignment) bool { return p.Role == role })
+}
+
+// inheritedFrom returns the first of the user's roles that grants permission, or an empty string.
+func (state *UserAccountState) inheritedFrom(permission string) string {
+	for _, grant := range state.roles {
+		if slices.Contains(grant.Permissions, permission) {
+			return grant.Role
+		}
 	}
-	state.awaitingApproval = append(state.awaitingApproval, req.Permission)
-	return state.refreshSearchAttributes()
+	return ""
 }
 
-func (state *UserAccountState) RequestApprovePermission(ctx workflow.Context, req messages.ApproveUserPermissionRequest) error {
-	if state.deleted || state.deletionRequested {
-		return errors.New("user deleted")
+// leaveRoles removes the user from the members of every role they hold so that the roles stop propagating to them.
+// Roles that cannot be left are logged and skipped.
+func (state *UserAccountState) leaveRoles(ctx workflow.Context) {
+	actCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 1 * time.Minute,
+	})
+	for _, grant := range state.roles {
+		err := workflow.ExecuteActivity(actCtx, "LeaveRole", &messages.LeaveRoleRequest{
+			Role:   grant.Role,
+			UserID: workflow.GetInfo(ctx).WorkflowExecution.ID,
+		}).Get(actCtx, nil)
+		if err != nil {
+			state.logger.Error("unable to leave role", "Role", grant.Role, "Error", err)
+		}
 	}
-	if state.userHasPermissionPendingApproval(req.Permission) {
-		resp := messages.VerifyApproverResponse{}
-		actCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
-			StartToCloseTimeout: 1 * time.Minute,
+}
+
+func (state *UserAccountState) requiredApprovals(permission string) int {
+	if policy, ok := state.approvalPolicies[permission]; ok && policy.RequiredApprovals > 1 {
+		return policy.RequiredApprovals
+	}
+	return 1
+}
+
+// scheduleExpiration starts a durable timer that removes permission once expiresAt passes. The timer stands down if
+// the grant is revoked or re-approved with a different expiry in the meantime.
+func (state *UserAccountState) scheduleExpiration(permission string, expiresAt time.Time) {
+	workflow.Go(state.ctx, func(inner workflow.Context) {
+		stillScheduled := func() bool {
+			scheduled, ok := state.permissionExpiration[permission]
+			return ok && scheduled.Equal(expiresAt)
+		}
+		if remaining := expiresAt.Sub(workflow.Now(inner)); remaining > 0 {
+			ok, err := workflow.AwaitWithTimeout(inner, remaining, func() bool {
+				return !stillScheduled()
+			})
+			if err != nil {
+				state.logger.Info("timer cancelled", err)
+				return
+			}
+			if ok {
+				return
+			}
+		}
+		if !stillScheduled() {
+			return
+		}
+		state.permissionsGranted = without(state.permissionsGranted, permission)
+		delete(state.permissionExpiration, permission)
+		state.audit(constants.AuditActionPermissionExpired, constants.SystemActorID, permission, "", nil)
+		err := state.refreshSearchAttributes()
+		if err != nil {
+			state.logger.Error("unable to refresh search attributes", err)
+		}
+	})
+}
+
+// scheduleBreakGlassExpiry starts a durable timer that revokes the emergency access with id once breakGlassWindow has
+// passed. Access that is revoked early, or superseded by an approved grant, ends without waiting for the timer. Either
+// way the review stays open.
+func (state *UserAccountState) scheduleBreakGlassExpiry(id string) {
+	i := state.breakGlassAccess(id)
+	permission := state.breakGlass[i].Permission
+	workflow.Go(state.ctx, func(inner workflow.Context) {
+		ended := func() bool {
+			return !state.breakGlass[i].Active || !state.userHasPermission(permission)
+		}
+		if remaining := state.breakGlass[i].ExpiresAt.Sub(workflow.Now(inner)); remaining > 0 {
+			_, err := workflow.AwaitWithTimeout(inner, remaining, ended)
+			if err != nil {
+				state.logger.Info("timer cancelled", err)
+				return
+			}
+		}
+		if !ended() {
+			state.permissionsGranted = without(state.permissionsGranted, permission)
+			state.audit(constants.AuditActionBreakGlassExpired, constants.SystemActorID, permission, "", nil)
+		}
+		state.breakGlass[i].Active = false
+		err := errors.Join(state.refreshSearchAttributes(), state.refreshBreakGlassSearchAttribute())
+		if err != nil {
+			state.logger.Error("unable to refresh search attributes", err)
+		}
+	})
+}
+
+// scheduleDelegationExpiry starts a durable timer that ends delegation once it expires. The timer stands down if the
+// delegation is revoked or replaced in the meantime.
+func (state *UserAccountState) scheduleDelegationExpiry(delegation messages.Delegation) {
+	workflow.Go(state.ctx, func(inner workflow.Context) {
+		stillScheduled := func() bool {
+			i := state.delegationTo(delegation.DelegateID)
+			return i >= 0 && state.delegations[i].ExpiresAt.Equal(delegation.ExpiresAt)
+		}
+		if remaining := delegation.ExpiresAt.Sub(workflow.Now(inner)); remaining > 0 {
+			ok, err := workflow.AwaitWithTimeout(inner, remaining, func() bool {
+				return !stillScheduled()
+			})
+			if err != nil {
+				state.logger.Info("timer cancelled", err)
+				return
+			}
+			if ok {
+				return
+			}
+		}
+		if !stillScheduled() {
+			return
+		}
+		state.delegations = slices.DeleteFunc(state.delegations, func(d messages.Delegation) bool {
+			return d.DelegateID == delegation.DelegateID
 		})
-		err := workflow.ExecuteActivity(actCtx, "VerifyApprover", &messages.VerifyApproverRequest{
-			ApproverID: req.ApproverID,
-			Permission: req.Permission,
-		}).Get(actCtx, &resp)
+		state.audit(constants.AuditActionDelegationExpired, constants.SystemActorID, "",
+			"to "+delegation.DelegateID, nil)
+		err := state.refreshDelegatesSearchAttribute()
 		if err != nil {
-			return err
+			state.logger.Error("unable to refresh search attributes", err)
+		}
+	})
+}
+
+// watchPendingApproval walks a pending request through the approval SLA: remind the approver pool, escalate to the
+// escalation pool and finally auto-deny. Every stage waits on a durable timer measured from the original request time
+// so the schedule survives continue-as-new, and the watcher stands down as soon as the request is resolved.
+func (state *UserAccountState) watchPendingApproval(permission string) {
+	v := workflow.GetVersion(state.ctx, "approval_sla_timers", workflow.DefaultVersion, 1)
+	if v == workflow.DefaultVersion {
+		return
+	}
+	sla := state.pendingApprovals[permission].SLA
+	if sla.RemindAfter <= 0 && sla.EscalateAfter <= 0 && sla.DenyAfter <= 0 {
+		return
+	}
+	requestedAt := state.pendingApprovals[permission].RequestedAt
+	workflow.Go(state.ctx, func(inner workflow.Context) {
+		resolved := func() bool {
+			pending, ok := state.pendingApprovals[permission]
+			return !ok || !pending.RequestedAt.Equal(requestedAt)
+		}
+		resolvedBy := func(after time.Duration) bool {
+			remaining := requestedAt.Add(after).Sub(workflow.Now(inner))
+			if remaining <= 0 {
+				return resolved()
+			}
+			ok, err := workflow.AwaitWithTimeout(inner, remaining, resolved)
+			if err != nil {
+				state.logger.Info("timer cancelled", err)
+				return true
+			}
+			return ok
 		}
-		// **** uncomment for versioning & replay testing demo
-		//v := workflow.GetVersion(ctx, "add_send_notifications_activity", workflow.DefaultVersion, 0)
-		//if v != workflow.DefaultVersion {
-		//	err = workflow.ExecuteActivity(actCtx, "SendNotifications", &messages.SendNotificationsRequest{
-		//		ApproverID:     req.ApproverID,
-		//		PermissionType: req.Permission,
-		//		RequesterID:    workflow.GetInfo(state.ctx).WorkflowExecution.ID,
-		//	}).Get(actCtx, &resp)
-		//}
-		if resp.Verified {
-			state.permissionsGranted = append(state.permissionsGranted, req.Permission)
-			awaitingApproval := make([]string, 0)
-			for _, a := range state.awaitingApproval {
-				if a != req.Permission {
-					awaitingApproval = append(awaitingApproval, a)
-				}
-			}
-			state.awaitingApproval = awaitingApproval
+		notify := func(notificationType string, approverPool string) {
+			actCtx := workflow.WithActivityOptions(inner, workflow.ActivityOptions{
+				StartToCloseTimeout: 1 * time.Minute,
+			})
+			err := workflow.ExecuteActivity(actCtx, "SendNotifications", &messages.SendNotificationsRequest{
+				ApproverPool:     approverPool,
+				NotificationType: notificationType,
+				PermissionType:   permission,
+				RequesterID:      workflow.GetInfo(inner).WorkflowExecution.ID,
+			}).Get(actCtx, nil)
+			if err != nil {
+				state.logger.Error("unable to send notifications", err)
+			}
+			state.audit(notificationType, constants.SystemActorID, permission, approverPool, err)
+		}
+		if sla.RemindAfter > 0 && !state.pendingApprovals[permission].Reminded {
+			if resolvedBy(sla.RemindAfter) {
+				return
+			}
+			notify(constants.NotificationTypeApprovalReminder, sla.ApproverPool)
+			if resolved() {
+				return
+			}
+			pending := state.pendingApprovals[permission]
+			pending.Reminded = true
+			state.pendingApprovals[permission] = pending
+		}
+		if sla.EscalateAfter > 0 && !state.pendingApprovals[permission].Escalated {
+			if resolvedBy(sla.EscalateAfter) {
+				return
+			}
+			notify(constants.NotificationTypeApprovalEscalation, sla.EscalationApproverPool)
+			if resolved() {
+				return
+			}
+			pending := state.pendingApprovals[permission]
+			pending.Escalated = true
+			state.pendingApprovals[permission] = pending
+		}
+		if sla.DenyAfter > 0 {
+			if resolvedBy(sla.DenyAfter) {
+				return
+			}
+			state.awaitingApproval = without(state.awaitingApproval, permission)
+			delete(state.pendingApprovals, permission)
+			state.rejections = append(state.rejections, messages.PermissionRejection{
+				ApproverID: constants.SystemActorID,
+				Permission: permission,
+				Reason:     constants.ApprovalDeadlineExceededReason,
+				RejectedAt: workflow.Now(inner),
+			})
+			state.audit(constants.RejectUserPermissionUpdateHandlerName, constants.SystemActorID, permission,
+				constants.ApprovalDeadlineExceededReason, nil)
 			err := state.refreshSearchAttributes()
 			if err != nil {
 				state.logger.Error("unable to refresh search attributes", err)
 			}
-		} else {
-			return errors.New(fmt.Sprintf("%s cannot grant permission %s", req.ApproverID, req.Permission))
 		}
-	} else {
-		return errors.New("permission not found")
-	}
-	return nil
+	})
 }
 
-func (state *UserAccountState) RequestDeletion(_ messages.DeleteUserAccountRequest) {
-	undoDeletionWindow := time.Second * 60
-	state.deletionRequested = true
+// scheduleDeletion waits until deletionScheduledAt to move the user to pending_deletion, if they are not there
+// already, and then until deletionScheduledFor to mark them deleted unless the deletion is undone first.
+func (state *UserAccountState) scheduleDeletion() {
 	workflow.Go(state.ctx, func(inner workflow.Context) {
-		state.deletionRequestedAt = workflow.Now(inner)
-		state.deletionScheduledFor = state.deletionRequestedAt.Add(undoDeletionWindow)
-		ok, err := workflow.AwaitWithTimeout(inner, undoDeletionWindow, func() bool {
+		undone := func() bool {
 			// AwaitWithTimeout uses a durable timer under the hood
 			return !state.deletionRequested
-		})
+		}
+		if state.lifecycle != constants.UserStatusPendingDeletion {
+			ok, err := workflow.AwaitWithTimeout(inner, max(state.deletionScheduledAt.Sub(workflow.Now(inner)), 0), undone)
+			if err != nil {
+				state.logger.Info("timer cancelled", err)
+				return
+			}
+			if ok {
+				return
+			}
+			err = state.enterPendingDeletion()
+			if err != nil {
+				state.logger.Error("unable to start the undo window", err)
+				return
+			}
+		}
+		remaining := state.deletionScheduledFor.Sub(workflow.Now(inner))
+		ok, err := workflow.AwaitWithTimeout(inner, max(remaining, 0), undone)
 		if err != nil {
 			state.logger.Info("timer cancelled", err)
 		}
 		if !ok {
-			state.deleted = true
+			state.leaveRoles(inner)
+			err = state.transition(constants.UserStatusDeleted)
+			if err != nil {
+				state.logger.Error("unable to mark user deleted", err)
+			}
+			state.audit(constants.AuditActionDeletionCompleted, constants.SystemActorID, "", "", err)
 		}
 	})
 }
 
-func (state *UserAccountState) RequestUndoDeletion(_ messages.UndoDeleteUserAccountRequest) error {
-	if state.deleted {
-		return errors.New("already deleted")
+// undoWindow returns the undo window requested in req, falling back to the deletion policy's default.
+func (state *UserAccountState) undoWindow(req messages.DeleteUserAccountRequest) time.Duration {
+	if req.UndoWindow > 0 {
+		return req.UndoWindow
 	}
-	state.deletionRequested = false
-	return nil
+	if state.deletionPolicy.DefaultUndoWindow > 0 {
+		return state.deletionPolicy.DefaultUndoWindow
+	}
+	return defaultUndoDeletionWindow
 }
 
-func (state *UserAccountState) UserDetails() messages.UserDetailsResponse {
-	resp := messages.UserDetailsResponse{
-		AwaitingApproval:     state.AwaitingApproval(),
-		DeletionRequested:    state.deletionRequested,
-		DeletionRequestedAt:  state.deletionRequestedAt,
-		DeletionScheduledFor: state.deletionScheduledFor,
-		Permissions:          state.Permissions(),
+// requestSLA returns the approval SLA a new permission request is held to. The worker's SLA is recorded in history as
+// the request is made, so that a worker redeployed with a different SLA replays the request as it was made.
+func (state *UserAccountState) requestSLA(ctx workflow.Context) messages.ApprovalSLA {
+	v := workflow.GetVersion(ctx, "approval_sla_per_request", workflow.DefaultVersion, 1)
+	if v == workflow.DefaultVersion {
+		return state.approvalSLA
 	}
-	return resp
+	sla := messages.ApprovalSLA{}
+	err := workflow.SideEffect(ctx, func(ctx workflow.Context) interface{} {
+		return state.approvalSLA
+	}).Get(&sla)
+	if err != nil {
+		state.logger.Error("unable to record approval SLA", err)
+		return state.approvalSLA
+	}
+	return sla
+}
+
+// verifyApprover asks the VerifyApprover activity whether approverID holds the authority to grant or take away
+// permission, either their own or delegated to them. ctx must already carry activity options.
+func (state *UserAccountState) verifyApprover(ctx workflow.Context, approverID string, permission string) (messages.VerifyApproverResponse, error) {
+	resp := messages.VerifyApproverResponse{}
+	err := workflow.ExecuteActivity(ctx, "VerifyApprover", &messages.VerifyApproverRequest{
+		ApproverID: approverID,
+		Permission: permission,
+	}).Get(ctx, &resp)
+	if resp.OnBehalfOf == workflow.GetInfo(ctx).WorkflowExecution.ID {
+		// Delegated authority does not extend to the delegator's own account
+		resp.Verified = false
+	}
+	return resp, err
+}
+
+// verifyCampaignReviewer asks the VerifyCampaignReviewer activity whether the campaign named in req decided that
+// req.ApproverID revokes the grant. ctx must already carry activity options.
+func (state *UserAccountState) verifyCampaignReviewer(ctx workflow.Context, req messages.RevokeUserPermissionRequest) (bool, error) {
+	resp := messages.VerifyCampaignReviewerResponse{}
+	err := workflow.ExecuteActivity(ctx, "VerifyCampaignReviewer", &messages.VerifyCampaignReviewerRequest{
+		CampaignID: req.CampaignID,
+		Permission: req.Permission,
+		ReviewerID: req.ApproverID,
+		UserID:     workflow.GetInfo(ctx).WorkflowExecution.ID,
+	}).Get(ctx, &resp)
+	return resp.Verified, err
+}
+
+func (state *UserAccountState) userHasPermission(permission string) bool {
+	for _, granted := range state.permissionsGranted {
+		if permission == granted {
+			return true
+		}
+	}
+	return false
+}
+
+func (state *UserAccountState) userHasPermissionPendingApproval(permission string) bool {
+	for _, pending := range state.awaitingApproval {
+		if permission == pending {
+			return true
+		}
+	}
+	return false
+}
+
+// AuditLog returns a page of the audit log, newest entry first. NextOffset is 0 once the last page is reached.
+func (state *UserAccountState) AuditLog(req messages.AuditLogRequest) (messages.AuditLogResponse, error) {
+	if req.Offset < 0 || req.Limit < 0 {
+		return messages.AuditLogResponse{}, errors.New("offset and limit must not be negative")
+	}
+	limit := min(req.Limit, maxAuditLogPageSize)
+	if limit == 0 {
+		limit = defaultAuditLogPageSize
+	}
+	total := len(state.auditLog)
+	entries := make([]messages.AuditEntry, 0, min(limit, total))
+	for i := total - 1 - req.Offset; i >= 0 && len(entries) < limit; i-- {
+		entries = append(entries, state.auditLog[i])
+	}
+	resp := messages.AuditLogResponse{
+		Compacted: state.auditLogCompacted,
+		Entries:   entries,
+		Total:     total,
+	}
+	if next := req.Offset + len(entries); next < total {
+		resp.NextOffset = next
+	}
+	return resp, nil
+}
+
+// ApplyRolePermissions replaces the permissions inherited from sig.Role after the role was redefined. Users that are no
+// longer members of the role ignore it. Permissions that would violate a separation of duties rule, against the user's
+// other permissions or those of the role itself, are refused and the conflict is recorded in the audit log.
+func (state *UserAccountState) ApplyRolePermissions(sig messages.RolePermissionsChangedSignal) {
+	i := slices.IndexFunc(state.roles, func(g messages.RoleGrant) bool { return g.Role == sig.Role })
+	if i < 0 {
+		return
+	}
+	actor := role_state.WorkflowID(sig.Role)
+	state.roles[i].Permissions = make([]string, 0, len(sig.Permissions))
+	for _, permission := range sig.Permissions {
+		err := state.checkSeparationOfDuties(permission)
+		if err != nil {
+			state.audit(constants.AuditActionRolePermissionsChanged, actor, permission, "", err)
+			state.auditLog[len(state.auditLog)-1].Role = sig.Role
+			continue
+		}
+		state.roles[i].Permissions = append(state.roles[i].Permissions, permission)
+	}
+	state.auditRole(constants.AuditActionRolePermissionsChanged, actor, sig.Role, nil)
+	err := state.refreshSearchAttributes()
+	if err != nil {
+		state.logger.Error("unable to refresh search attributes", err)
+	}
+}
+
+func (state *UserAccountState) AwaitingApproval() messages.AwaitingApprovalResponse {
+	return messages.AwaitingApprovalResponse{Permissions: state.awaitingApproval}
+}
+
+func (state *UserAccountState) CreateUser(req messages.CreateUserAccountRequest) (err error) {
+	defer func() {
+		state.audit(constants.CreateUserAccountUpdateHandlerName, req.RequestedBy, "", "", err)
+	}()
+	err = state.ValidateCreateUser(req)
+	if err != nil {
+		return err
+	}
+	state.permissionsGranted = appendUnique(state.permissionsGranted, req.Permissions...)
+	state.profile = req.Profile
+	err = state.transition(constants.UserStatusActive)
+	if err != nil {
+		return err
+	}
+	if state.profile.Department != "" || state.profile.ManagerID != "" {
+		err = state.refreshProfileSearchAttributes()
+		if err != nil {
+			return err
+		}
+	}
+	return state.refreshSearchAttributes()
+}
+
+func (state *UserAccountState) Deleted() bool {
+	return state.lifecycle == constants.UserStatusDeleted
+}
+
+func (state *UserAccountState) DeletionRequestedAt() time.Time {
+	return state.deletionRequestedAt
+}
+
+// PendingApprovals returns the request details of every permission awaiting approval in the order they were requested.
+func (state *UserAccountState) PendingApprovals() []messages.PendingApproval {
+	pendingApprovals := make([]messages.PendingApproval, 0, len(state.awaitingApproval))
+	for _, permission := range state.awaitingApproval {
+		if pending, ok := state.pendingApprovals[permission]; ok {
+			pendingApprovals = append(pendingApprovals, pending)
+		}
+	}
+	return pendingApprovals
+}
+
+// PermissionExpirations returns the pending expiry of every time-bound grant ordered by permission so that callers
+// iterating over it, e.g. when scheduling timers, stay deterministic.
+func (state *UserAccountState) PermissionExpirations() []messages.PermissionExpiration {
+	permissions := make([]string, 0, len(state.permissionExpiration))
+	for p := range state.permissionExpiration {
+		permissions = append(permissions, p)
+	}
+	sort.Strings(permissions)
+	expirations := make([]messages.PermissionExpiration, 0, len(permissions))
+	for _, p := range permissions {
+		expirations = append(expirations, messages.PermissionExpiration{
+			ExpiresAt:  state.permissionExpiration[p],
+			Permission: p,
+		})
+	}
+	return expirations
+}
+
+// Permissions returns the user's effective permissions, those granted directly and those inherited from their roles.
+// A suspended user keeps their permissions but they are reported as suspended so that they cannot be used. The lifecycle
+// status is reported as well: only active users may use their permissions.
+func (state *UserAccountState) Permissions() messages.PermissionsGrantedResponse {
+	return messages.PermissionsGrantedResponse{
+		Delegations: state.delegations,
+		Direct:      state.permissionsGranted,
+		Permissions: state.effectivePermissions(),
+		Roles:       state.roles,
+		Status:      state.lifecycle,
+		Suspended:   state.suspension.Suspended,
+	}
+}
+
+// RequestAcknowledgeBreakGlass closes the review of emergency access once req.ApproverID is verified as an approver of
+// the permission that was used.
+func (state *UserAccountState) RequestAcknowledgeBreakGlass(ctx workflow.Context, req messages.AcknowledgeBreakGlassRequest) (err error) {
+	permission := ""
+	if i := state.breakGlassAccess(req.ID); i >= 0 {
+		permission = state.breakGlass[i].Permission
+	}
+	verified := messages.VerifyApproverResponse{}
+	defer func() {
+		state.audit(constants.AcknowledgeBreakGlassUpdateHandlerName, req.ApproverID, permission, req.Notes, err)
+		state.auditOnBehalfOf(verified.OnBehalfOf)
+	}()
+	err = state.ValidateAcknowledgeBreakGlass(ctx, req)
+	if err != nil {
+		return err
+	}
+	actCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 1 * time.Minute,
+	})
+	verified, err = state.verifyApprover(actCtx, req.ApproverID, permission)
+	if err != nil {
+		return err
+	}
+	if !verified.Verified {
+		return errors.New(fmt.Sprintf("%s cannot review emergency access to %s", req.ApproverID, permission))
+	}
+	// Another approver may have acknowledged it while the activity was running
+	err = state.ValidateAcknowledgeBreakGlass(ctx, req)
+	if err != nil {
+		return err
+	}
+	i := state.breakGlassAccess(req.ID)
+	state.breakGlass[i].ReviewedAt = workflow.Now(ctx)
+	state.breakGlass[i].ReviewedBy = req.ApproverID
+	state.breakGlass[i].ReviewNotes = req.Notes
+	return nil
+}
+
+// RequestAddPermission validates req.Permission against the permission catalog and queues it for approval. The
+// number of approvers required comes from the catalog's approval policy for the permission.
+func (state *UserAccountState) RequestAddPermission(ctx workflow.Context, req messages.AddUserPermissionRequest) (err error) {
+	defer func() {
+		// Users request permissions for themselves
+		state.audit(constants.AddUserPermissionUpdateHandlerName, workflow.GetInfo(ctx).WorkflowExecution.ID,
+			req.Permission, "", err)
+	}()
+	err = state.ValidateAddPermission(req)
+	if err != nil {
+		return err
+	}
+	requiredApprovals := state.requiredApprovals(req.Permission)
+	v := workflow.GetVersion(ctx, "validate_permission_with_catalog", workflow.DefaultVersion, 1)
+	if v != workflow.DefaultVersion {
+		actCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+			StartToCloseTimeout: 1 * time.Minute,
+		})
+		resp := messages.ValidatePermissionResponse{}
+		err = workflow.ExecuteActivity(actCtx, "ValidatePermission", &messages.ValidatePermissionRequest{
+			Permission: req.Permission,
+		}).Get(actCtx, &resp)
+		if err != nil {
+			return err
+		}
+		if !resp.Valid {
+			return errors.New(fmt.Sprintf("%s is not a permission in the catalog", req.Permission))
+		}
+		if resp.Definition.ApprovalPolicy.RequiredApprovals > 0 {
+			requiredApprovals = resp.Definition.ApprovalPolicy.RequiredApprovals
+		}
+		// The user may have changed while the catalog was consulted, e.g. been deleted or had the same permission
+		// requested again
+		err = state.ValidateAddPermission(req)
+		if err != nil {
+			return err
+		}
+	}
+	state.awaitingApproval = appendUnique(state.awaitingApproval, req.Permission)
+	state.pendingApprovals[req.Permission] = messages.PendingApproval{
+		Approvals:         make([]string, 0),
+		Permission:        req.Permission,
+		RequestedAt:       workflow.Now(state.ctx),
+		RequiredApprovals: requiredApprovals,
+		SLA:               state.requestSLA(ctx),
+	}
+	state.watchPendingApproval(req.Permission)
+	return state.refreshSearchAttributes()
+}
+
+// RequestAddRole queues req.Role for approval once the role is found and its permissions are checked against the
+// separation of duties rules.
+func (state *UserAccountState) RequestAddRole(ctx workflow.Context, req messages.AddUserRoleRequest) (err error) {
+	defer func() {
+		actor := req.RequestedBy
+		if actor == "" {
+			// Users may ask to join roles themselves
+			actor = workflow.GetInfo(ctx).WorkflowExecution.ID
+		}
+		state.auditRole(constants.AddUserRoleUpdateHandlerName, actor, req.Role, err)
+	}()
+	err = state.ValidateAddRole(req)
+	if err != nil {
+		return err
+	}
+	actCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 1 * time.Minute,
+	})
+	role, err := state.getRole(actCtx, req.Role)
+	if err != nil {
+		return err
+	}
+	err = state.checkRoleSeparationOfDuties(req.Role, role.Permissions)
+	if err != nil {
+		return err
+	}
+	// The user may have changed while the role was looked up
+	err = state.ValidateAddRole(req)
+	if err != nil {
+		return err
+	}
+	state.pendingRoles = append(state.pendingRoles, messages.PendingRoleAssignment{
+		RequestedAt: workflow.Now(ctx),
+		RequestedBy: req.RequestedBy,
+		Role:        req.Role,
+	})
+	return nil
+}
+
+// RequestApprovePermission records req.ApproverID's approval of a pending request and grants the permission once the
+// number of distinct approvers required by the permission's approval policy has been reached.
+func (state *UserAccountState) RequestApprovePermission(ctx workflow.Context, req messages.ApproveUserPermissionRequest) (approveResp messages.ApproveUserPermissionResponse, err error) {
+	verified := messages.VerifyApproverResponse{}
+	defer func() {
+		reason := ""
+		if err == nil {
+			reason = fmt.Sprintf("%d of %d approvals", approveResp.Approvals, approveResp.RequiredApprovals)
+		}
+		state.audit(constants.ApproveUserPermissionUpdateHandlerName, req.ApproverID, req.Permission, reason, err)
+		state.auditOnBehalfOf(verified.OnBehalfOf)
+	}()
+	err = state.ValidateApprovePermission(ctx, req)
+	if err != nil {
+		return messages.ApproveUserPermissionResponse{}, err
+	}
+	actCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 1 * time.Minute,
+	})
+	verified, err = state.verifyApprover(actCtx, req.ApproverID, req.Permission)
+	if err != nil {
+		return messages.ApproveUserPermissionResponse{}, err
+	}
+	// **** uncomment for versioning & replay testing demo
+	//v := workflow.GetVersion(ctx, "add_send_notifications_activity", workflow.DefaultVersion, 0)
+	//if v != workflow.DefaultVersion {
+	//	err = workflow.ExecuteActivity(actCtx, "SendNotifications", &messages.SendNotificationsRequest{
+	//		ApproverID:     req.ApproverID,
+	//		PermissionType: req.Permission,
+	//		RequesterID:    workflow.GetInfo(state.ctx).WorkflowExecution.ID,
+	//	}).Get(actCtx, &verified)
+	//}
+	if !verified.Verified {
+		return messages.ApproveUserPermissionResponse{},
+			errors.New(fmt.Sprintf("%s cannot grant permission %s", req.ApproverID, req.Permission))
+	}
+	// Delegates exercise their delegator's authority, so approvals are counted by whose authority was exercised
+	approverID := req.ApproverID
+	if verified.OnBehalfOf != "" {
+		approverID = verified.OnBehalfOf
+	}
+	// The request may have been resolved, or approved with the same authority, while the activity was running
+	if !state.userHasPermissionPendingApproval(req.Permission) {
+		return messages.ApproveUserPermissionResponse{}, errors.New("permission not found")
+	}
+	if state.hasApproved(req.Permission, approverID) {
+		return messages.ApproveUserPermissionResponse{},
+			errors.New(fmt.Sprintf("%s has already approved permission %s", approverID, req.Permission))
+	}
+	err = state.checkSeparationOfDuties(req.Permission)
+	if err != nil {
+		return messages.ApproveUserPermissionResponse{}, err
+	}
+	pending := state.pendingApprovals[req.Permission]
+	pending.Approvals = append(pending.Approvals, approverID)
+	// Each approver may bound the grant, and the shortest bound any of them asked for applies
+	if req.ExpiresAfter > 0 && (pending.ExpiresAfter <= 0 || req.ExpiresAfter < pending.ExpiresAfter) {
+		pending.ExpiresAfter = req.ExpiresAfter
+	}
+	if pending.RequiredApprovals < 1 {
+		pending.RequiredApprovals = state.requiredApprovals(req.Permission)
+	}
+	state.pendingApprovals[req.Permission] = pending
+	approveResp = messages.ApproveUserPermissionResponse{
+		Approvals:         len(pending.Approvals),
+		RequiredApprovals: pending.RequiredApprovals,
+	}
+	if len(pending.Approvals) < pending.RequiredApprovals {
+		return approveResp, nil
+	}
+	state.permissionsGranted = appendUnique(state.permissionsGranted, req.Permission)
+	state.awaitingApproval = without(state.awaitingApproval, req.Permission)
+	delete(state.pendingApprovals, req.Permission)
+	for i := range state.breakGlass {
+		if state.breakGlass[i].Active && state.breakGlass[i].Permission == req.Permission {
+			// The approved grant outlasts emergency access to the same permission
+			state.breakGlass[i].Active = false
+		}
+	}
+	if pending.ExpiresAfter > 0 {
+		expiresAt := workflow.Now(ctx).Add(pending.ExpiresAfter)
+		state.permissionExpiration[req.Permission] = expiresAt
+		state.scheduleExpiration(req.Permission, expiresAt)
+	} else {
+		delete(state.permissionExpiration, req.Permission)
+	}
+	err = state.refreshSearchAttributes()
+	if err != nil {
+		state.logger.Error("unable to refresh search attributes", err)
+	}
+	approveResp.Granted = true
+	return approveResp, nil
+}
+
+// RequestApproveRole makes the user a member of a requested role once req.ApproverID is verified as an approver of the
+// role, i.e. holds approve:role:<name> or grant_permissions, and the user inherits the role's permissions.
+func (state *UserAccountState) RequestApproveRole(ctx workflow.Context, req messages.ApproveUserRoleRequest) (err error) {
+	verified := messages.VerifyApproverResponse{}
+	defer func() {
+		state.auditRole(constants.ApproveUserRoleUpdateHandlerName, req.ApproverID, req.Role, err)
+		state.auditOnBehalfOf(verified.OnBehalfOf)
+	}()
+	err = state.ValidateApproveRole(ctx, req)
+	if err != nil {
+		return err
+	}
+	actCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 1 * time.Minute,
+	})
+	verified, err = state.verifyApprover(actCtx, req.ApproverID, role_state.WorkflowID(req.Role))
+	if err != nil {
+		return err
+	}
+	if !verified.Verified {
+		return errors.New(fmt.Sprintf("%s cannot grant role %s", req.ApproverID, req.Role))
+	}
+	role, err := state.getRole(actCtx, req.Role)
+	if err != nil {
+		return err
+	}
+	err = state.checkRoleSeparationOfDuties(req.Role, role.Permissions)
+	if err != nil {
+		return err
+	}
+	// The request may have been withdrawn, or the user suspended, while the activities were running
+	err = state.ValidateApproveRole(ctx, req)
+	if err != nil {
+		return err
+	}
+	joined := messages.JoinRoleResponse{}
+	err = workflow.ExecuteActivity(actCtx, "JoinRole", &messages.JoinRoleRequest{
+		Role:   req.Role,
+		UserID: workflow.GetInfo(ctx).WorkflowExecution.ID,
+	}).Get(actCtx, &joined)
+	if err != nil {
+		return err
+	}
+	state.pendingRoles = slices.DeleteFunc(state.pendingRoles, func(p messages.PendingRoleAssignment) bool {
+		return p.Role == req.Role
+	})
+	state.roles = append(state.roles, messages.RoleGrant{Permissions: joined.Permissions, Role: req.Role})
+	err = state.refreshSearchAttributes()
+	if err != nil {
+		state.logger.Error("unable to refresh search attributes", err)
+	}
+	return nil
+}
+
+// RequestBreakGlass grants req.Permission at once, without approval, for breakGlassWindow and opens a review that an
+// approver of the permission must acknowledge afterwards.
+func (state *UserAccountState) RequestBreakGlass(ctx workflow.Context, req messages.BreakGlassRequest) (resp messages.BreakGlassResponse, err error) {
+	actor := req.RequestedBy
+	if actor == "" {
+		// On-call engineers break the glass for themselves
+		actor = workflow.GetInfo(ctx).WorkflowExecution.ID
+	}
+	defer func() {
+		state.audit(constants.BreakGlassUpdateHandlerName, actor, req.Permission, req.Justification, err)
+	}()
+	err = state.ValidateBreakGlass(req)
+	if err != nil {
+		return messages.BreakGlassResponse{}, err
+	}
+	actCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 1 * time.Minute,
+	})
+	valid := messages.ValidatePermissionResponse{}
+	err = workflow.ExecuteActivity(actCtx, "ValidatePermission", &messages.ValidatePermissionRequest{
+		Permission: req.Permission,
+	}).Get(actCtx, &valid)
+	if err != nil {
+		return messages.BreakGlassResponse{}, err
+	}
+	if !valid.Valid {
+		return messages.BreakGlassResponse{}, errors.New(fmt.Sprintf("%s is not a permission in the catalog",
+			req.Permission))
+	}
+	// The user may have changed while the catalog was consulted
+	err = state.ValidateBreakGlass(req)
+	if err != nil {
+		return messages.BreakGlassResponse{}, err
+	}
+	access := messages.BreakGlassAccess{
+		Active:        true,
+		ExpiresAt:     workflow.Now(ctx).Add(breakGlassWindow),
+		GrantedAt:     workflow.Now(ctx),
+		ID:            fmt.Sprintf("%d", len(state.breakGlass)+1),
+		Justification: strings.TrimSpace(req.Justification),
+		Permission:    req.Permission,
+		RequestedBy:   actor,
+	}
+	state.breakGlass = append(state.breakGlass, access)
+	state.permissionsGranted = appendUnique(state.permissionsGranted, req.Permission)
+	state.scheduleBreakGlassExpiry(access.ID)
+	err = errors.Join(state.refreshSearchAttributes(), state.refreshBreakGlassSearchAttribute())
+	if err != nil {
+		state.logger.Error("unable to refresh search attributes", err)
+	}
+	err = workflow.ExecuteActivity(actCtx, "SendNotifications", &messages.SendNotificationsRequest{
+		ApproverPool:     state.approvalSLA.ApproverPool,
+		NotificationType: constants.NotificationTypeBreakGlassReview,
+		PermissionType:   req.Permission,
+		RequesterID:      workflow.GetInfo(ctx).WorkflowExecution.ID,
+	}).Get(actCtx, nil)
+	if err != nil {
+		// The review is open regardless and shows on the user
+		state.logger.Error("unable to send notifications", err)
+	}
+	return messages.BreakGlassResponse{ExpiresAt: access.ExpiresAt, ID: access.ID}, nil
+}
+
+// RequestDelegateApproval lets req.DelegateID approve on the user's behalf until req.ExpiresAfter has passed.
+// Delegating again to the same user replaces the earlier delegation.
+func (state *UserAccountState) RequestDelegateApproval(ctx workflow.Context, req messages.DelegateApprovalRequest) (err error) {
+	delegation := messages.Delegation{
+		DelegateID: req.DelegateID,
+		ExpiresAt:  workflow.Now(ctx).Add(req.ExpiresAfter),
+		GrantedAt:  workflow.Now(ctx),
+	}
+	defer func() {
+		actor := req.RequestedBy
+		if actor == "" {
+			// Users delegate their own authority
+			actor = workflow.GetInfo(ctx).WorkflowExecution.ID
+		}
+		state.audit(constants.DelegateApprovalUpdateHandlerName, actor, "",
+			fmt.Sprintf("to %s until %s", req.DelegateID, delegation.ExpiresAt.Format(time.RFC3339)), err)
+	}()
+	err = state.ValidateDelegateApproval(ctx, req)
+	if err != nil {
+		return err
+	}
+	if i := state.delegationTo(req.DelegateID); i >= 0 {
+		state.delegations[i] = delegation
+	} else {
+		state.delegations = append(state.delegations, delegation)
+	}
+	state.scheduleDelegationExpiry(delegation)
+	return state.refreshDelegatesSearchAttribute()
+}
+
+// RequestDeletion starts the undo window after which the user is deleted, either now or, when
+// req.DeletionScheduledAt is set, at that future time. req.UndoWindow, when set, replaces the deletion policy's default
+// window.
+func (state *UserAccountState) RequestDeletion(req messages.DeleteUserAccountRequest) (err error) {
+	defer func() {
+		state.audit(constants.DeleteUserAccountUpdateHandlerName, req.RequestedBy, "", "", err)
+	}()
+	err = state.ValidateDeletion(req)
+	if err != nil {
+		return err
+	}
+	state.deletionRequested = true
+	state.deletionRequestedAt = workflow.Now(state.ctx)
+	state.deletionScheduledAt = req.DeletionScheduledAt
+	state.deletionUndoWindow = state.undoWindow(req)
+	state.deletionScheduledFor = state.deletionRequestedAt.Add(state.deletionUndoWindow)
+	if state.deletionScheduledAt.After(state.deletionRequestedAt) {
+		state.deletionScheduledFor = state.deletionScheduledAt.Add(state.deletionUndoWindow)
+	}
+	err = state.startDeletion()
+	if err != nil {
+		return err
+	}
+	err = state.refreshScheduledDeletionSearchAttribute()
+	if err != nil {
+		state.logger.Error("unable to refresh search attributes", err)
+	}
+	return nil
+}
+
+// RequestReinstate lifts a suspension once req.ApproverID is verified as an approver of grant_permissions.
+func (state *UserAccountState) RequestReinstate(ctx workflow.Context, req messages.ReinstateUserAccountRequest) (err error) {
+	verified := messages.VerifyApproverResponse{}
+	defer func() {
+		state.audit(constants.ReinstateUserAccountUpdateHandlerName, req.ApproverID, "", "", err)
+		state.auditOnBehalfOf(verified.OnBehalfOf)
+	}()
+	err = state.ValidateReinstate(ctx, req)
+	if err != nil {
+		return err
+	}
+	actCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 1 * time.Minute,
+	})
+	verified, err = state.verifyApprover(actCtx, req.ApproverID, constants.PermissionTypeGrantPermissions)
+	if err != nil {
+		return err
+	}
+	if !verified.Verified {
+		return errors.New(fmt.Sprintf("%s cannot reinstate users", req.ApproverID))
+	}
+	// The user may have been reinstated or deleted while the activity was running
+	err = state.checkAllowed(constants.ReinstateUserAccountUpdateHandlerName)
+	if err != nil {
+		return err
+	}
+	state.suspension = messages.Suspension{}
+	return state.transition(constants.UserStatusActive)
+}
+
+func (state *UserAccountState) RequestRejectPermission(ctx workflow.Context, req messages.RejectUserPermissionRequest) (err error) {
+	verified := messages.VerifyApproverResponse{}
+	defer func() {
+		state.audit(constants.RejectUserPermissionUpdateHandlerName, req.ApproverID, req.Permission, req.Reason, err)
+		state.auditOnBehalfOf(verified.OnBehalfOf)
+	}()
+	err = state.ValidateRejectPermission(req)
+	if err != nil {
+		return err
+	}
+	actCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 1 * time.Minute,
+	})
+	verified, err = state.verifyApprover(actCtx, req.ApproverID, req.Permission)
+	if err != nil {
+		return err
+	}
+	if !verified.Verified {
+		return errors.New(fmt.Sprintf("%s cannot reject permission %s", req.ApproverID, req.Permission))
+	}
+	// The request may have been resolved while the activity was running
+	if !state.userHasPermissionPendingApproval(req.Permission) {
+		return errors.New("permission not found")
+	}
+	state.awaitingApproval = without(state.awaitingApproval, req.Permission)
+	delete(state.pendingApprovals, req.Permission)
+	state.rejections = append(state.rejections, messages.PermissionRejection{
+		ApproverID: req.ApproverID,
+		Permission: req.Permission,
+		Reason:     req.Reason,
+		RejectedAt: workflow.Now(ctx),
+	})
+	err = state.refreshSearchAttributes()
+	if err != nil {
+		state.logger.Error("unable to refresh search attributes", err)
+	}
+	return nil
+}
+
+// RequestRemoveRole takes req.Role away from the user, or withdraws the request for it, once req.ApproverID is
+// verified as an approver of the role.
+func (state *UserAccountState) RequestRemoveRole(ctx workflow.Context, req messages.RemoveUserRoleRequest) (err error) {
+	verified := messages.VerifyApproverResponse{}
+	defer func() {
+		state.auditRole(constants.RemoveUserRoleUpdateHandlerName, req.ApproverID, req.Role, err)
+		state.auditOnBehalfOf(verified.OnBehalfOf)
+	}()
+	err = state.ValidateRemoveRole(req)
+	if err != nil {
+		return err
+	}
+	actCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 1 * time.Minute,
+	})
+	verified, err = state.verifyApprover(actCtx, req.ApproverID, role_state.WorkflowID(req.Role))
+	if err != nil {
+		return err
+	}
+	if !verified.Verified {
+		return errors.New(fmt.Sprintf("%s cannot remove role %s", req.ApproverID, req.Role))
+	}
+	if state.hasRole(req.Role) {
+		err = workflow.ExecuteActivity(actCtx, "LeaveRole", &messages.LeaveRoleRequest{
+			Role:   req.Role,
+			UserID: workflow.GetInfo(ctx).WorkflowExecution.ID,
+		}).Get(actCtx, nil)
+		if err != nil {
+			return err
+		}
+	}
+	state.pendingRoles = slices.DeleteFunc(state.pendingRoles, func(p messages.PendingRoleAssignment) bool {
+		return p.Role == req.Role
+	})
+	state.roles = slices.DeleteFunc(state.roles, func(g messages.RoleGrant) bool { return g.Role == req.Role })
+	err = state.refreshSearchAttributes()
+	if err != nil {
+		state.logger.Error("unable to refresh search attributes", err)
+	}
+	return nil
+}
+
+// RequestRevokeDelegation ends the delegation to req.DelegateID before it expires.
+func (state *UserAccountState) RequestRevokeDelegation(ctx workflow.Context, req messages.RevokeDelegationRequest) (err error) {
+	defer func() {
+		actor := req.RequestedBy
+		if actor == "" {
+			actor = workflow.GetInfo(ctx).WorkflowExecution.ID
+		}
+		state.audit(constants.RevokeDelegationUpdateHandlerName, actor, "", "to "+req.DelegateID, err)
+	}()
+	err = state.ValidateRevokeDelegation(req)
+	if err != nil {
+		return err
+	}
+	state.delegations = slices.DeleteFunc(state.delegations, func(d messages.Delegation) bool {
+		return d.DelegateID == req.DelegateID
+	})
+	return state.refreshDelegatesSearchAttribute()
+}
+
+// RequestRevokePermission takes away a directly granted permission. Revocations decided in a recertification campaign
+// name the campaign in req.CampaignID and are verified against the campaign's decision rather than the approver's
+// authority.
+func (state *UserAccountState) RequestRevokePermission(ctx workflow.Context, req messages.RevokeUserPermissionRequest) (err error) {
+	verified := messages.VerifyApproverResponse{}
+	defer func() {
+		state.audit(constants.RevokeUserPermissionUpdateHandlerName, req.ApproverID, req.Permission, req.Reason, err)
+		state.auditOnBehalfOf(verified.OnBehalfOf)
+	}()
+	err = state.ValidateRevokePermission(req)
+	if err != nil {
+		return err
+	}
+	actCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 1 * time.Minute,
+	})
+	if req.CampaignID == "" {
+		verified, err = state.verifyApprover(actCtx, req.ApproverID, req.Permission)
+	} else {
+		verified.Verified, err = state.verifyCampaignReviewer(actCtx, req)
+	}
+	if err != nil {
+		return err
+	}
+	if !verified.Verified {
+		return errors.New(fmt.Sprintf("%s cannot revoke permission %s", req.ApproverID, req.Permission))
+	}
+	// The user may have changed, e.g. been deleted or had the permission revoked, while the approver was verified
+	err = state.ValidateRevokePermission(req)
+	if err != nil {
+		return err
+	}
+	state.permissionsGranted = without(state.permissionsGranted, req.Permission)
+	delete(state.permissionExpiration, req.Permission)
+	for i := range state.breakGlass {
+		if state.breakGlass[i].Active && state.breakGlass[i].Permission == req.Permission {
+			// Revoking the permission ends emergency access to it as well
+			state.breakGlass[i].Active = false
+		}
+	}
+	err = errors.Join(state.refreshSearchAttributes(), state.refreshBreakGlassSearchAttribute())
+	if err != nil {
+		state.logger.Error("unable to refresh search attributes", err)
+	}
+	return nil
+}
+
+// RequestSuspend locks the user out, e.g. during a security incident, without taking away their permissions.
+func (state *UserAccountState) RequestSuspend(req messages.SuspendUserAccountRequest) (err error) {
+	defer func() {
+		state.audit(constants.SuspendUserAccountUpdateHandlerName, req.RequestedBy, "", req.Reason, err)
+	}()
+	err = state.ValidateSuspend(req)
+	if err != nil {
+		return err
+	}
+	state.suspension = messages.Suspension{
+		Reason:      req.Reason,
+		RequestedBy: req.RequestedBy,
+		Suspended:   true,
+		SuspendedAt: workflow.Now(state.ctx),
+	}
+	return state.transition(constants.UserStatusSuspended)
+}
+
+// RequestUpdateProfile replaces the user's profile with req.Profile.
+func (state *UserAccountState) RequestUpdateProfile(req messages.UpdateUserProfileRequest) (err error) {
+	defer func() {
+		state.audit(constants.UpdateUserProfileUpdateHandlerName, req.RequestedBy, "", "", err)
+	}()
+	err = state.ValidateUpdateProfile(req)
+	if err != nil {
+		return err
+	}
+	state.profile = req.Profile
+	return state.refreshProfileSearchAttributes()
+}
+
+func (state *UserAccountState) RequestUndoDeletion(req messages.UndoDeleteUserAccountRequest) (err error) {
+	defer func() {
+		state.audit(constants.UndoDeleteUserAccountUpdateHandlerName, req.RequestedBy, "", "", err)
+	}()
+	err = state.ValidateUndoDeletion(req)
+	if err != nil {
+		return err
+	}
+	state.deletionRequested = false
+	if state.lifecycle == constants.UserStatusPendingDeletion {
+		restored := state.statusBeforeDeletion
+		if restored == "" {
+			restored = constants.UserStatusActive
+		}
+		err = state.transition(restored)
+		if err != nil {
+			return err
+		}
+	}
+	err = state.refreshScheduledDeletionSearchAttribute()
+	if err != nil {
+		state.logger.Error("unable to refresh search attributes", err)
+	}
+	return nil
+}
+
+// Snapshot captures the state carried across continue-as-new. Only the most recent auditLogRetention audit entries are
+// kept.
+func (state *UserAccountState) Snapshot() messages.UserAccountOrchestrationInput {
+	auditLog := state.auditLog
+	compacted := state.auditLogCompacted
+	if dropped := len(auditLog) - auditLogRetention; dropped > 0 {
+		auditLog = auditLog[dropped:]
+		compacted += dropped
+	}
+	return messages.UserAccountOrchestrationInput{
+		AuditLog:              auditLog,
+		AuditLogCompacted:     compacted,
+		AwaitingApproval:      state.awaitingApproval,
+		BreakGlass:            state.breakGlass,
+		Delegations:           state.delegations,
+		DeletionRequested:     state.deletionRequested,
+		DeletionRequestedAt:   state.deletionRequestedAt,
+		DeletionScheduledAt:   state.deletionScheduledAt,
+		DeletionScheduledFor:  state.deletionScheduledFor,
+		DeletionUndoWindow:    state.deletionUndoWindow,
+		PendingApprovals:      state.PendingApprovals(),
+		PendingRoles:          state.pendingRoles,
+		PermissionExpirations: state.PermissionExpirations(),
+		Permissions:           state.permissionsGranted,
+		Profile:               state.profile,
+		Rejections:            state.rejections,
+		Roles:                 state.roles,
+		SchemaVersion:         SnapshotSchemaVersion,
+		Status:                state.lifecycle,
+		StatusBeforeDeletion:  state.statusBeforeDeletion,
+		Suspension:            state.suspension,
+	}
+}
+
+func (state *UserAccountState) UserDetails() messages.UserDetailsResponse {
+	resp := messages.UserDetailsResponse{
+		AwaitingApproval:      state.AwaitingApproval(),
+		BreakGlass:            state.breakGlass,
+		DeletionRequested:     state.deletionRequested,
+		DeletionRequestedAt:   state.deletionRequestedAt,
+		DeletionScheduledAt:   state.deletionScheduledAt,
+		DeletionScheduledFor:  state.deletionScheduledFor,
+		DeletionUndoWindow:    state.deletionUndoWindow,
+		PendingApprovals:      state.PendingApprovals(),
+		PendingRoles:          state.pendingRoles,
+		PermissionExpirations: state.PermissionExpirations(),
+		Permissions:           state.Permissions(),
+		Profile:               state.profile,
+		Rejections:            state.rejections,
+		Status:                state.lifecycle,
+		Suspension:            state.suspension,
+	}
+	return resp
+}
+
+// ValidateAcknowledgeBreakGlass covers everything RequestAcknowledgeBreakGlass checks before asking VerifyApprover.
+// Whoever broke the glass cannot review it.
+func (state *UserAccountState) ValidateAcknowledgeBreakGlass(ctx workflow.Context, req messages.AcknowledgeBreakGlassRequest) error {
+	if req.ApproverID == "" {
+		return errors.New("approver required and missing")
+	}
+	err := state.checkAllowed(constants.AcknowledgeBreakGlassUpdateHandlerName)
+	if err != nil {
+		return err
+	}
+	i := state.breakGlassAccess(req.ID)
+	if i < 0 {
+		return errors.New("emergency access not found")
+	}
+	if state.breakGlass[i].ReviewedBy != "" {
+		return errors.New(fmt.Sprintf("emergency access %s already reviewed by %s", req.ID,
+			state.breakGlass[i].ReviewedBy))
+	}
+	if req.ApproverID == workflow.GetInfo(ctx).WorkflowExecution.ID || req.ApproverID == state.breakGlass[i].RequestedBy {
+		return errors.New(fmt.Sprintf("%s cannot review their own emergency access", req.ApproverID))
+	}
+	return nil
+}
+
+// ValidateAddPermission reports why req would be refused without changing any state. Like the other Validate methods
+// it backs an update validator, so a refused request is rejected before it is written to history, and it is checked
+// again when the update runs.
+func (state *UserAccountState) ValidateAddPermission(req messages.AddUserPermissionRequest) error {
+	if req.Permission == "" {
+		return errors.New("permission required and missing")
+	}
+	err := state.checkAllowed(constants.AddUserPermissionUpdateHandlerName)
+	if err != nil {
+		return err
+	}
+	err = state.checkSeparationOfDuties(req.Permission)
+	if err != nil {
+		return err
+	}
+	if state.userHasPermissionPendingApproval(req.Permission) {
+		return errors.New(fmt.Sprintf("permission %s already awaiting approval", req.Permission))
+	}
+	if state.userHasPermission(req.Permission) {
+		return errors.New(fmt.Sprintf("permission %s already granted", req.Permission))
+	}
+	if role := state.inheritedFrom(req.Permission); role != "" {
+		return errors.New(fmt.Sprintf("permission %s already inherited from role %s", req.Permission, role))
+	}
+	return nil
+}
+
+func (state *UserAccountState) ValidateAddRole(req messages.AddUserRoleRequest) error {
+	if req.Role == "" {
+		return errors.New("role required and missing")
+	}
+	err := state.checkAllowed(constants.AddUserRoleUpdateHandlerName)
+	if err != nil {
+		return err
+	}
+	if state.hasRolePendingApproval(req.Role) {
+		return errors.New(fmt.Sprintf("role %s already awaiting approval", req.Role))
+	}
+	if state.hasRole(req.Role) {
+		return errors.New(fmt.Sprintf("role %s already granted", req.Role))
+	}
+	return nil
+}
+
+// ValidateApprovePermission covers everything RequestApprovePermission checks before asking VerifyApprover.
+func (state *UserAccountState) ValidateApprovePermission(ctx workflow.Context, req messages.ApproveUserPermissionRequest) error {
+	if req.ApproverID == "" {
+		return errors.New("approver required and missing")
+	}
+	if req.Permission == "" {
+		return errors.New("permission required and missing")
+	}
+	err := state.checkAllowed(constants.ApproveUserPermissionUpdateHandlerName)
+	if err != nil {
+		return err
+	}
+	if req.ExpiresAfter < 0 {
+		return errors.New("expiry must not be negative")
+	}
+	if req.ApproverID == workflow.GetInfo(ctx).WorkflowExecution.ID {
+		return errors.New(fmt.Sprintf("%s cannot approve their own permission request", req.ApproverID))
+	}
+	if !state.userHasPermissionPendingApproval(req.Permission) {
+		return errors.New("permission not found")
+	}
+	if state.hasApproved(req.Permission, req.ApproverID) {
+		return errors.New(fmt.Sprintf("%s has already approved permission %s", req.ApproverID, req.Permission))
+	}
+	return state.checkSeparationOfDuties(req.Permission)
+}
+
+// ValidateApproveRole covers everything RequestApproveRole checks before asking VerifyApprover.
+func (state *UserAccountState) ValidateApproveRole(ctx workflow.Context, req messages.ApproveUserRoleRequest) error {
+	if req.ApproverID == "" {
+		return errors.New("approver required and missing")
+	}
+	err := state.checkAllowed(constants.ApproveUserRoleUpdateHandlerName)
+	if err != nil {
+		return err
+	}
+	if req.ApproverID == workflow.GetInfo(ctx).WorkflowExecution.ID {
+		return errors.New(fmt.Sprintf("%s cannot approve their own role request", req.ApproverID))
+	}
+	if !state.hasRolePendingApproval(req.Role) {
+		return errors.New("role not found")
+	}
+	return nil
+}
+
+// ValidateBreakGlass refuses emergency access without a justification or to a permission the user already holds.
+// Separation of duties rules apply as they do to approved grants.
+func (state *UserAccountState) ValidateBreakGlass(req messages.BreakGlassRequest) error {
+	if req.Permission == "" {
+		return errors.New("permission required and missing")
+	}
+	if strings.TrimSpace(req.Justification) == "" {
+		return errors.New("justification required and missing")
+	}
+	err := state.checkAllowed(constants.BreakGlassUpdateHandlerName)
+	if err != nil {
+		return err
+	}
+	if state.userHasPermission(req.Permission) {
+		return errors.New(fmt.Sprintf("permission %s already granted", req.Permission))
+	}
+	if role := state.inheritedFrom(req.Permission); role != "" {
+		return errors.New(fmt.Sprintf("permission %s already inherited from role %s", req.Permission, role))
+	}
+	return state.checkSeparationOfDuties(req.Permission)
+}
+
+func (state *UserAccountState) ValidateCreateUser(req messages.CreateUserAccountRequest) error {
+	if state.lifecycle != constants.UserStatusPending {
+		return &UserAlreadyExistsError{Status: state.lifecycle}
+	}
+	err := state.checkAllowed(constants.CreateUserAccountUpdateHandlerName)
+	if err != nil {
+		return err
+	}
+	for _, permission := range req.Permissions {
+		if permission == "" {
+			return errors.New("permissions must not be empty")
+		}
+	}
+	return state.validateProfile(req.Profile)
+}
+
+// ValidateDelegateApproval checks that the user holds grant_permissions, the authority that is delegated, and that the
+// delegation is bounded.
+func (state *UserAccountState) ValidateDelegateApproval(ctx workflow.Context, req messages.DelegateApprovalRequest) error {
+	if req.DelegateID == "" {
+		return errors.New("delegate required and missing")
+	}
+	err := state.checkAllowed(constants.DelegateApprovalUpdateHandlerName)
+	if err != nil {
+		return err
+	}
+	if req.DelegateID == workflow.GetInfo(ctx).WorkflowExecution.ID {
+		return errors.New("users cannot delegate to themselves")
+	}
+	if !slices.Contains(state.effectivePermissions(), constants.PermissionTypeGrantPermissions) {
+		return errors.New(fmt.Sprintf("only holders of %s may delegate approval authority",
+			constants.PermissionTypeGrantPermissions))
+	}
+	if req.ExpiresAfter <= 0 {
+		return errors.New("expiry required and missing")
+	}
+	if req.ExpiresAfter > maxDelegationWindow {
+		return errors.New(fmt.Sprintf("delegation of %s is longer than the maximum of %s", req.ExpiresAfter,
+			maxDelegationWindow))
+	}
+	return nil
+}
+
+func (state *UserAccountState) ValidateDeletion(req messages.DeleteUserAccountRequest) error {
+	err := state.checkAllowed(constants.DeleteUserAccountUpdateHandlerName)
+	if err != nil {
+		return err
+	}
+	if state.deletionRequested {
+		return errors.New("deletion already requested")
+	}
+	if req.UndoWindow < 0 {
+		return errors.New("undo window must not be negative")
+	}
+	if !req.DeletionScheduledAt.IsZero() && req.DeletionScheduledAt.Before(workflow.Now(state.ctx)) {
+		return errors.New("scheduled deletion must not be in the past")
+	}
+	if req.UndoWindow > 0 && req.UndoWindow < state.deletionPolicy.MinUndoWindow {
+		return errors.New(fmt.Sprintf("undo window %s is shorter than the minimum of %s", req.UndoWindow,
+			state.deletionPolicy.MinUndoWindow))
+	}
+	if state.deletionPolicy.MaxUndoWindow > 0 && req.UndoWindow > state.deletionPolicy.MaxUndoWindow {
+		return errors.New(fmt.Sprintf("undo window %s is longer than the maximum of %s", req.UndoWindow,
+			state.deletionPolicy.MaxUndoWindow))
+	}
+	return nil
+}
+
+func (state *UserAccountState) ValidateRejectPermission(req messages.RejectUserPermissionRequest) error {
+	if req.ApproverID == "" {
+		return errors.New("approver required and missing")
+	}
+	err := state.checkAllowed(constants.RejectUserPermissionUpdateHandlerName)
+	if err != nil {
+		return err
+	}
+	if !state.userHasPermissionPendingApproval(req.Permission) {
+		return errors.New("permission not found")
+	}
+	return nil
+}
+
+// ValidateReinstate covers everything RequestReinstate checks before asking VerifyApprover.
+func (state *UserAccountState) ValidateReinstate(ctx workflow.Context, req messages.ReinstateUserAccountRequest) error {
+	if req.ApproverID == "" {
+		return errors.New("approver required and missing")
+	}
+	err := state.checkAllowed(constants.ReinstateUserAccountUpdateHandlerName)
+	if err != nil {
+		return err
+	}
+	if req.ApproverID == workflow.GetInfo(ctx).WorkflowExecution.ID {
+		return errors.New(fmt.Sprintf("%s cannot reinstate themselves", req.ApproverID))
+	}
+	return nil
+}
+
+func (state *UserAccountState) ValidateRemoveRole(req messages.RemoveUserRoleRequest) error {
+	if req.ApproverID == "" {
+		return errors.New("approver required and missing")
+	}
+	err := state.checkAllowed(constants.RemoveUserRoleUpdateHandlerName)
+	if err != nil {
+		return err
+	}
+	if !state.hasRole(req.Role) && !state.hasRolePendingApproval(req.Role) {
+		return errors.New("role not found")
+	}
+	return nil
+}
+
+func (state *UserAccountState) ValidateRevokeDelegation(req messages.RevokeDelegationRequest) error {
+	if req.DelegateID == "" {
+		return errors.New("delegate required and missing")
+	}
+	err := state.checkAllowed(constants.RevokeDelegationUpdateHandlerName)
+	if err != nil {
+		return err
+	}
+	if state.delegationTo(req.DelegateID) < 0 {
+		return errors.New("delegation not found")
+	}
+	return nil
+}
+
+func (state *UserAccountState) ValidateRevokePermission(req messages.RevokeUserPermissionRequest) error {
+	if req.ApproverID == "" {
+		return errors.New("approver required and missing")
+	}
+	err := state.checkAllowed(constants.RevokeUserPermissionUpdateHandlerName)
+	if err != nil {
+		return err
+	}
+	if !state.userHasPermission(req.Permission) {
+		if role := state.inheritedFrom(req.Permission); role != "" {
+			return errors.New(fmt.Sprintf("permission %s is inherited from role %s and cannot be revoked on its own",
+				req.Permission, role))
+		}
+		return errors.New("permission not found")
+	}
+	return nil
+}
+
+func (state *UserAccountState) ValidateSuspend(_ messages.SuspendUserAccountRequest) error {
+	return state.checkAllowed(constants.SuspendUserAccountUpdateHandlerName)
+}
+
+func (state *UserAccountState) ValidateUndoDeletion(_ messages.UndoDeleteUserAccountRequest) error {
+	err := state.checkAllowed(constants.UndoDeleteUserAccountUpdateHandlerName)
+	if err != nil {
+		return err
+	}
+	if !state.deletionRequested {
+		return errors.New("deletion not requested")
+	}
+	return nil
+}
+
+func (state *UserAccountState) ValidateUpdateProfile(req messages.UpdateUserProfileRequest) error {
+	err := state.checkAllowed(constants.UpdateUserProfileUpdateHandlerName)
+	if err != nil {
+		return err
+	}
+	return state.validateProfile(req.Profile)
+}
+
+// validateProfile checks the fields of profile that are set. Every field is optional.
+func (state *UserAccountState) validateProfile(profile messages.UserProfile) error {
+	if profile.Email != "" {
+		address, err := mail.ParseAddress(profile.Email)
+		if err != nil || address.Address != profile.Email {
+			return errors.New(fmt.Sprintf("%s is not a valid email address", profile.Email))
+		}
+	}
+	if profile.EmployeeType != "" && !slices.Contains(employeeTypes, profile.EmployeeType) {
+		return errors.New(fmt.Sprintf("employee type %s is not one of %s", profile.EmployeeType,
+			strings.Join(employeeTypes, ", ")))
+	}
+	if profile.ManagerID != "" && profile.ManagerID == workflow.GetInfo(state.ctx).WorkflowExecution.ID {
+		return errors.New("users cannot manage themselves")
+	}
+	return nil
+}
+
+// appendUnique appends each of additions to values unless values already holds it, keeping permission lists sets.
+func appendUnique(values []string, additions ...string) []string {
+	for _, addition := range additions {
+		if !slices.Contains(values, addition) {
+			values = append(values, addition)
+		}
+	}
+	return values
+}
+
+// without returns a copy of values with every occurrence of value removed.
+func without(values []string, value string) []string {
+	filtered := make([]string, 0)
+	for _, v := range values {
+		if v != value {
+			filtered = append(filtered, v)
+		}
+	}
+	return filtered
 }